@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq"
+)
+
+// Every value starts with the producer's wall clock as 19 digits of unix nanos and a '|'
+const stampLen = 20
+
+func stampValue(now time.Time, size int) string {
+	var b strings.Builder
+	b.Grow(size)
+	fmt.Fprintf(&b, "%019d|", now.UnixNano())
+	for b.Len() < size {
+		b.WriteByte('x')
+	}
+	return b.String()
+}
+
+func parseStamp(v string) (time.Time, bool) {
+	if len(v) < stampLen || v[stampLen-1] != '|' {
+		return time.Time{}, false
+	}
+	ns, err := strconv.ParseInt(v[:stampLen-1], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ns), true
+}
+
+// pacer hands out evenly spaced send slots across all producers; a zero rate never waits
+type pacer struct {
+	start    time.Time
+	interval time.Duration
+}
+
+func (p pacer) wait(ctx context.Context, n int64) error {
+	if p.interval <= 0 {
+		return nil
+	}
+	d := time.Until(p.start.Add(time.Duration(n) * p.interval))
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// counters are shared by every worker goroutine
+type counters struct {
+	seq          atomic.Int64
+	produced     atomic.Int64
+	produceErrs  atomic.Int64
+	consumed     atomic.Int64
+	redelivered  atomic.Int64
+	stale        atomic.Int64
+	streamErrs   atomic.Int64
+	acked        atomic.Int64
+	ackErrs      atomic.Int64
+	lastConsumed atomic.Int64 // unix nanos
+}
+
+// samples are kept per goroutine and merged once everyone is done
+type samples struct {
+	mu                 sync.Mutex
+	produce, e2e, acks []time.Duration
+}
+
+func (s *samples) merge(produce, e2e, acks []time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.produce = append(s.produce, produce...)
+	s.e2e = append(s.e2e, e2e...)
+	s.acks = append(s.acks, acks...)
+}
+
+func runBench(ctx context.Context, cfg config, stderr io.Writer) (*report, error) {
+	c, err := driftq.Dial(ctx, driftq.Config{
+		BaseURL:   cfg.URL,
+		Timeout:   cfg.Timeout,
+		UserAgent: "driftq-bench/" + driftq.Version,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	if cfg.Partitions > 0 {
+		if _, err := c.Admin().CreateTopic(ctx, cfg.Topic, cfg.Partitions); err != nil && !errors.Is(err, driftq.ErrTopicExists) {
+			return nil, fmt.Errorf("create topic %s: %w", cfg.Topic, err)
+		}
+	}
+
+	var (
+		cnt  counters
+		smp  samples
+		wg   sync.WaitGroup
+		host = hostname()
+	)
+
+	start := time.Now()
+
+	// Values stamped before this run are leftovers and don't count, unless we're only consuming
+	since := start
+	if cfg.Producers == 0 {
+		since = time.Time{}
+	}
+
+	// Consumers first so they're attached before the first message lands
+	cctx, stopConsumers := context.WithCancel(ctx)
+	defer stopConsumers()
+	for i := range cfg.Consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			consumer(ctx, cctx, c, cfg, fmt.Sprintf("%s-bench-c%d", host, i), since, &cnt, &smp)
+		}()
+	}
+
+	pctx, stopProducers := context.WithCancel(ctx)
+	if cfg.Messages == 0 {
+		pctx, stopProducers = context.WithTimeout(ctx, cfg.Duration)
+	}
+	defer stopProducers()
+
+	var p pacer
+	if cfg.Rate > 0 {
+		p = pacer{start: time.Now(), interval: time.Duration(float64(time.Second) / cfg.Rate)}
+	}
+
+	var pwg sync.WaitGroup
+	for range cfg.Producers {
+		pwg.Add(1)
+		go func() {
+			defer pwg.Done()
+			producer(ctx, pctx, c, cfg, p, &cnt, &smp)
+		}()
+	}
+	pwg.Wait()
+	produceElapsed := time.Since(start)
+
+	switch {
+	case cfg.Consumers == 0:
+	case cfg.Producers == 0:
+		// consume-only: read --messages, or whatever arrives within --duration
+		want := func() int64 { return cfg.Messages }
+		if cfg.Messages == 0 {
+			want = func() int64 { return math.MaxInt64 }
+		}
+		waitConsumed(pctx, want, &cnt)
+	default:
+		dctx, cancel := context.WithTimeout(ctx, cfg.Drain)
+		if !waitConsumed(dctx, cnt.produced.Load, &cnt) && ctx.Err() == nil {
+			fmt.Fprintf(stderr, "driftq-bench: drain window closed with %d of %d messages consumed\n",
+				cnt.consumed.Load(), cnt.produced.Load())
+		}
+		cancel()
+	}
+	stopConsumers()
+	wg.Wait()
+
+	consumeElapsed := time.Duration(0)
+	if last := cnt.lastConsumed.Load(); last > 0 {
+		consumeElapsed = time.Unix(0, last).Sub(start)
+	}
+
+	return newReport(cfg, start, time.Since(start), produceElapsed, consumeElapsed, &cnt, &smp), nil
+}
+
+// waitConsumed polls until consumers have seen want() messages; false if ctx ran out first
+func waitConsumed(ctx context.Context, want func() int64, cnt *counters) bool {
+	tick := time.NewTicker(5 * time.Millisecond)
+	defer tick.Stop()
+	for cnt.consumed.Load() < want() {
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// producer sends until pctx is done or the message budget is used up.
+// In-flight produces use ctx so the end of --duration doesn't count as an error
+func producer(ctx, pctx context.Context, c *driftq.Client, cfg config, p pacer, cnt *counters, smp *samples) {
+	var lat []time.Duration
+	defer func() { smp.merge(lat, nil, nil) }()
+
+	for {
+		n := cnt.seq.Add(1) - 1
+		if cfg.Messages > 0 && n >= cfg.Messages {
+			return
+		}
+		if p.wait(pctx, n) != nil || pctx.Err() != nil {
+			return
+		}
+
+		req := driftq.ProduceRequest{Topic: cfg.Topic}
+		if cfg.Keys > 0 {
+			req.Key = "k-" + strconv.FormatInt(n%int64(cfg.Keys), 10)
+		}
+
+		sent := time.Now()
+		req.Value = stampValue(sent, cfg.Size)
+		if _, err := c.Produce(ctx, req); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			cnt.produceErrs.Add(1)
+			continue
+		}
+		lat = append(lat, time.Since(sent))
+		cnt.produced.Add(1)
+	}
+}
+
+// consumer reads and acks until cctx is done, reopening the stream if it drops
+func consumer(ctx, cctx context.Context, c *driftq.Client, cfg config, owner string, since time.Time, cnt *counters, smp *samples) {
+	var e2e, acks []time.Duration
+	defer func() { smp.merge(nil, e2e, acks) }()
+
+	opt := driftq.ConsumeOptions{Topic: cfg.Topic, Group: cfg.Group, Owner: owner, LeaseMS: cfg.Lease.Milliseconds()}
+
+	for cctx.Err() == nil {
+		msgs, errs, err := c.ConsumeStream(cctx, opt)
+		if err != nil {
+			if cctx.Err() != nil {
+				return
+			}
+			cnt.streamErrs.Add(1)
+			sleepCtx(cctx, 100*time.Millisecond)
+			continue
+		}
+
+		for m := range msgs {
+			now := time.Now()
+			ts, ok := parseStamp(m.Value)
+			switch {
+			case !ok || ts.Before(since):
+				// left over from an earlier run (or not ours): ack it out of the way, don't count it
+				cnt.stale.Add(1)
+			case m.Attempts > 1:
+				cnt.redelivered.Add(1)
+			default:
+				e2e = append(e2e, now.Sub(ts))
+				cnt.consumed.Add(1)
+				cnt.lastConsumed.Store(now.UnixNano())
+			}
+
+			ackStart := time.Now()
+			err := c.Ack(ctx, driftq.AckRequest{Topic: cfg.Topic, Group: cfg.Group, Owner: owner, Partition: m.Partition, Offset: m.Offset})
+			if err != nil {
+				cnt.ackErrs.Add(1)
+				continue
+			}
+			acks = append(acks, time.Since(ackStart))
+			cnt.acked.Add(1)
+		}
+
+		if err, ok := <-errs; ok && err != nil && cctx.Err() == nil {
+			cnt.streamErrs.Add(1)
+		}
+	}
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+func hostname() string {
+	h, err := os.Hostname()
+	if err != nil || h == "" {
+		return "driftq"
+	}
+	return h
+}
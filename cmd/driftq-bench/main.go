@@ -0,0 +1,123 @@
+// Command driftq-bench is a load generator for sizing brokers and comparing SDK versions.
+//
+// It runs N producers and M consumers against one topic and reports throughput,
+// produce latency, end-to-end latency (the producer's timestamp is embedded in
+// each value) and ack latency, plus error counts, as a table or JSON.
+//
+//	driftq-bench --url http://localhost:8080 --producers 4 --consumers 4 --size 512 --rate 2000 --duration 30s
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// config is echoed back in the JSON report so runs can be compared
+type config struct {
+	URL        string        `json:"url"`
+	Topic      string        `json:"topic"`
+	Partitions int           `json:"partitions"`
+	Group      string        `json:"group"`
+	Producers  int           `json:"producers"`
+	Consumers  int           `json:"consumers"`
+	Size       int           `json:"size_bytes"`
+	Keys       int           `json:"key_cardinality"`
+	Rate       float64       `json:"rate"`
+	Messages   int64         `json:"messages"`
+	Duration   time.Duration `json:"duration_ns"`
+	Drain      time.Duration `json:"drain_ns"`
+	Lease      time.Duration `json:"lease_ns"`
+	Timeout    time.Duration `json:"timeout_ns"`
+	Output     string        `json:"-"`
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	cfg, err := parseFlags(args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintln(stderr, "driftq-bench:", err)
+		return 2
+	}
+
+	rep, err := runBench(ctx, cfg, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, "driftq-bench:", err)
+		return 1
+	}
+
+	if cfg.Output == "json" {
+		err = rep.writeJSON(stdout)
+	} else {
+		err = rep.writeTable(stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "driftq-bench:", err)
+		return 1
+	}
+	return 0
+}
+
+func parseFlags(args []string, stderr io.Writer) (config, error) {
+	url := os.Getenv("DRIFTQ_URL")
+	if url == "" {
+		url = "http://localhost:8080"
+	}
+
+	var c config
+	fs := flag.NewFlagSet("driftq-bench", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&c.URL, "url", url, "broker base URL (env DRIFTQ_URL)")
+	fs.StringVar(&c.Topic, "topic", "driftq-bench", "topic to produce to and consume from")
+	fs.IntVar(&c.Partitions, "partitions", 0, "create the topic with this many partitions first (0 = use it as is)")
+	fs.StringVar(&c.Group, "group", "driftq-bench", "consumer group")
+	fs.IntVar(&c.Producers, "producers", 1, "concurrent producers")
+	fs.IntVar(&c.Consumers, "consumers", 1, "concurrent consumers (0 = produce only)")
+	fs.IntVar(&c.Size, "size", 256, "message value size in bytes")
+	fs.IntVar(&c.Keys, "keys", 0, "distinct message keys (0 = no key)")
+	fs.Float64Var(&c.Rate, "rate", 0, "target produce rate in msg/s across all producers (0 = as fast as possible)")
+	fs.Int64Var(&c.Messages, "messages", 0, "stop producing after this many messages (0 = run for --duration)")
+	fs.DurationVar(&c.Duration, "duration", 10*time.Second, "how long to produce when --messages is 0")
+	fs.DurationVar(&c.Drain, "drain", 10*time.Second, "how long consumers keep reading after producers finish")
+	fs.DurationVar(&c.Lease, "lease", 0, "consumer lease (0 = server default)")
+	fs.DurationVar(&c.Timeout, "timeout", 10*time.Second, "per-request timeout")
+	fs.StringVar(&c.Output, "o", "table", "output: table or json")
+
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+	if fs.NArg() > 0 {
+		return c, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	switch {
+	case c.Producers < 0 || c.Consumers < 0:
+		return c, errors.New("--producers and --consumers must be >= 0")
+	case c.Producers == 0 && c.Consumers == 0:
+		return c, errors.New("need at least one producer or consumer")
+	case c.Size < stampLen:
+		return c, fmt.Errorf("--size must be at least %d (the embedded timestamp)", stampLen)
+	case c.Keys < 0 || c.Rate < 0 || c.Messages < 0:
+		return c, errors.New("--keys, --rate and --messages must be >= 0")
+	case c.Messages == 0 && c.Duration <= 0:
+		return c, errors.New("need --messages or a positive --duration")
+	case c.Output != "table" && c.Output != "json":
+		return c, fmt.Errorf("unknown output %q (want table or json)", c.Output)
+	}
+	return c, nil
+}
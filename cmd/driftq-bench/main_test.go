@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq/driftqtest"
+)
+
+func bench(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var out, errb bytes.Buffer
+	code := run(ctx, args, &out, &errb)
+	return code, out.String(), errb.String()
+}
+
+func TestBench_ProduceConsumeJSON(t *testing.T) {
+	srv := driftqtest.NewServer(driftqtest.Options{})
+	defer srv.Close()
+
+	// A leftover from an earlier run must be acked out of the way but not counted
+	srv.CreateTopic("bench", 2)
+	if _, err := srv.Publish(driftqtest.Message{Topic: "bench", Value: stampValue(time.Now().Add(-time.Hour), 64)}); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out, stderr := bench(t, "--url", srv.URL, "--topic", "bench", "--producers", "3", "--consumers", "2",
+		"--messages", "60", "--size", "64", "--keys", "4", "-o", "json")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+
+	var r report
+	if err := json.Unmarshal([]byte(out), &r); err != nil {
+		t.Fatalf("decode report: %v\n%s", err, out)
+	}
+
+	if r.Produce.Sent != 60 || r.Produce.Errors != 0 || r.Produce.Latency.Count != 60 {
+		t.Fatalf("unexpected produce stats %+v", r.Produce)
+	}
+	if r.Consume.Received != 60 || r.Consume.Stale != 1 || r.Consume.EndToEnd.Count != 60 {
+		t.Fatalf("unexpected consume stats %+v", r.Consume)
+	}
+	if r.Ack.Acked != 61 || r.Ack.Errors != 0 {
+		t.Fatalf("unexpected ack stats %+v", r.Ack)
+	}
+	if e := r.Consume.EndToEnd; e.P50 > e.P99 || e.P99 > e.Max || e.Max <= 0 {
+		t.Fatalf("percentiles out of order %+v", e)
+	}
+
+	keys := map[string]bool{}
+	for _, m := range srv.Messages("bench") {
+		if len(m.Value) != 64 {
+			t.Fatalf("expected 64 byte values, got %d", len(m.Value))
+		}
+		keys[m.Key] = true
+	}
+	if len(keys) != 5 { // k-0..k-3 plus the leftover's empty key
+		t.Fatalf("expected 4 distinct keys plus the leftover, got %v", keys)
+	}
+}
+
+func TestBench_RateAndTable(t *testing.T) {
+	srv := driftqtest.NewServer(driftqtest.Options{AutoCreateTopics: true})
+	defer srv.Close()
+
+	start := time.Now()
+	code, out, stderr := bench(t, "--url", srv.URL, "--consumers", "0", "--messages", "20", "--rate", "100")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	// 20 slots at 10ms apart: the last one can't go before ~190ms
+	if el := time.Since(start); el < 150*time.Millisecond {
+		t.Fatalf("rate limit not applied: finished in %v", el)
+	}
+
+	for _, want := range []string{"producers=1 consumers=0", "rate=100/s", "produce", "end-to-end", "p99.9"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("table missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestBench_Flags(t *testing.T) {
+	for _, args := range [][]string{
+		{"--producers", "0", "--consumers", "0"},
+		{"--size", "4"},
+		{"-o", "yaml"},
+		{"extra"},
+	} {
+		if code, _, _ := bench(t, args...); code != 2 {
+			t.Fatalf("%v: expected usage exit 2, got %d", args, code)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	ds := make([]time.Duration, 1000)
+	for i := range ds {
+		ds[len(ds)-1-i] = time.Duration(i+1) * time.Millisecond
+	}
+	l := summarize(ds)
+	if l.P50 != 500 || l.P90 != 900 || l.P99 != 990 || l.P999 != 999 || l.Max != 1000 || l.Mean != 500.5 {
+		t.Fatalf("unexpected summary %+v", l)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// latency is a percentile summary in milliseconds
+type latency struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean_ms"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+	P999  float64 `json:"p999_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+func summarize(ds []time.Duration) latency {
+	if len(ds) == 0 {
+		return latency{}
+	}
+	slices.Sort(ds)
+
+	var sum time.Duration
+	for _, d := range ds {
+		sum += d
+	}
+	return latency{
+		Count: len(ds),
+		Mean:  ms(sum / time.Duration(len(ds))),
+		P50:   ms(percentile(ds, 0.50)),
+		P90:   ms(percentile(ds, 0.90)),
+		P99:   ms(percentile(ds, 0.99)),
+		P999:  ms(percentile(ds, 0.999)),
+		Max:   ms(ds[len(ds)-1]),
+	}
+}
+
+// percentile uses nearest rank on an already sorted slice
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(0, min(i, len(sorted)-1))]
+}
+
+func ms(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+type produceStats struct {
+	Sent       int64   `json:"sent"`
+	Errors     int64   `json:"errors"`
+	ErrorRate  float64 `json:"error_rate"`
+	MsgsPerSec float64 `json:"msgs_per_sec"`
+	MBPerSec   float64 `json:"mb_per_sec"`
+	Latency    latency `json:"latency"`
+}
+
+type consumeStats struct {
+	Received     int64   `json:"received"`
+	Redelivered  int64   `json:"redelivered"`
+	Stale        int64   `json:"stale"`
+	StreamErrors int64   `json:"stream_errors"`
+	MsgsPerSec   float64 `json:"msgs_per_sec"`
+	EndToEnd     latency `json:"end_to_end"`
+}
+
+type ackStats struct {
+	Acked     int64   `json:"acked"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	Latency   latency `json:"latency"`
+}
+
+type report struct {
+	Config    config       `json:"config"`
+	StartedAt time.Time    `json:"started_at"`
+	ElapsedS  float64      `json:"elapsed_s"`
+	Produce   produceStats `json:"produce"`
+	Consume   consumeStats `json:"consume"`
+	Ack       ackStats     `json:"ack"`
+}
+
+func newReport(cfg config, start time.Time, elapsed, produceElapsed, consumeElapsed time.Duration, cnt *counters, smp *samples) *report {
+	r := &report{Config: cfg, StartedAt: start, ElapsedS: elapsed.Seconds()}
+
+	sent, perr := cnt.produced.Load(), cnt.produceErrs.Load()
+	r.Produce = produceStats{
+		Sent:      sent,
+		Errors:    perr,
+		ErrorRate: rate(perr, sent+perr),
+		Latency:   summarize(smp.produce),
+	}
+	if s := produceElapsed.Seconds(); s > 0 && sent > 0 {
+		r.Produce.MsgsPerSec = float64(sent) / s
+		r.Produce.MBPerSec = float64(sent) * float64(cfg.Size) / s / 1e6
+	}
+
+	r.Consume = consumeStats{
+		Received:     cnt.consumed.Load(),
+		Redelivered:  cnt.redelivered.Load(),
+		Stale:        cnt.stale.Load(),
+		StreamErrors: cnt.streamErrs.Load(),
+		EndToEnd:     summarize(smp.e2e),
+	}
+	if s := consumeElapsed.Seconds(); s > 0 {
+		r.Consume.MsgsPerSec = float64(r.Consume.Received) / s
+	}
+
+	acked, aerr := cnt.acked.Load(), cnt.ackErrs.Load()
+	r.Ack = ackStats{
+		Acked:     acked,
+		Errors:    aerr,
+		ErrorRate: rate(aerr, acked+aerr),
+		Latency:   summarize(smp.acks),
+	}
+	return r
+}
+
+func rate(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+func (r *report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func (r *report) writeTable(w io.Writer) error {
+	c := r.Config
+	fmt.Fprintf(w, "driftq-bench %s topic=%s producers=%d consumers=%d size=%dB keys=%d rate=%s elapsed=%.2fs\n\n",
+		c.URL, c.Topic, c.Producers, c.Consumers, c.Size, c.Keys, rateLabel(c.Rate), r.ElapsedS)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "\tcount\tmsg/s\terrors\tmean\tp50\tp90\tp99\tp99.9\tmax\t")
+	row := func(name string, n int64, msgsPerSec float64, errs string, l latency) {
+		fmt.Fprintf(tw, "%s\t%d\t%.0f\t%s\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t\n",
+			name, n, msgsPerSec, errs, l.Mean, l.P50, l.P90, l.P99, l.P999, l.Max)
+	}
+	row("produce", r.Produce.Sent, r.Produce.MsgsPerSec, errLabel(r.Produce.Errors, r.Produce.ErrorRate), r.Produce.Latency)
+	row("end-to-end", r.Consume.Received, r.Consume.MsgsPerSec, fmt.Sprint(r.Consume.StreamErrors), r.Consume.EndToEnd)
+	row("ack", r.Ack.Acked, 0, errLabel(r.Ack.Errors, r.Ack.ErrorRate), r.Ack.Latency)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\nlatencies in ms; %.2f MB/s produced; %d redelivered, %d stale (ignored)\n",
+		r.Produce.MBPerSec, r.Consume.Redelivered, r.Consume.Stale)
+	return err
+}
+
+func rateLabel(r float64) string {
+	if r == 0 {
+		return "max"
+	}
+	return fmt.Sprintf("%g/s", r)
+}
+
+func errLabel(n int64, rate float64) string {
+	if n == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%d (%.2f%%)", n, rate*100)
+}
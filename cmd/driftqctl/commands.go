@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq"
+)
+
+// emit writes v as indented JSON, one compact NDJSON line, or via pretty
+func (e *env) emit(v any, pretty func(w io.Writer)) error {
+	switch e.settings.Output {
+	case "json":
+		enc := json.NewEncoder(e.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "ndjson":
+		return json.NewEncoder(e.stdout).Encode(v)
+	default:
+		pretty(e.stdout)
+		return nil
+	}
+}
+
+// ---- produce ----
+
+func runProduce(ctx context.Context, e *env, args []string) error {
+	fs := e.newFlagSet("produce", "produce --topic T [flags] [value...]  (no values: one message per stdin line)")
+	topic := fs.String("topic", "", "topic (required)")
+	key := fs.String("key", "", "message key")
+	file := fs.String("file", "", `read one message per line from this file ("-" = stdin)`)
+	idem := fs.String("idempotency-key", "", "idempotency key; with several messages each gets <key>-<n>")
+	runID := fs.String("run-id", "", "envelope run_id")
+	stepID := fs.String("step-id", "", "envelope step_id")
+	parentStep := fs.String("parent-step-id", "", "envelope parent_step_id")
+	tenant := fs.String("tenant", "", "envelope tenant_id")
+	target := fs.String("target-topic", "", "envelope target_topic")
+	deadline := fs.String("deadline", "", "envelope deadline: a duration from now (30s) or RFC3339 time")
+	partition := fs.Int("partition", -1, "envelope partition_override (-1 = none)")
+	maxAttempts := fs.Int("max-attempts", 0, "envelope retry_policy.max_attempts")
+	backoffDur := fs.Duration("backoff", 0, "envelope retry_policy.backoff_ms")
+	maxBackoff := fs.Duration("max-backoff", 0, "envelope retry_policy.max_backoff_ms")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(*topic) == "" {
+		return usageErrorf("produce: --topic is required")
+	}
+
+	env := &driftq.Envelope{
+		RunID:        *runID,
+		StepID:       *stepID,
+		ParentStepID: *parentStep,
+		TenantID:     *tenant,
+		TargetTopic:  *target,
+	}
+	if *deadline != "" {
+		t, err := parseDeadline(*deadline, time.Now())
+		if err != nil {
+			return usageErrorf("produce: --deadline: %v", err)
+		}
+		env.Deadline = &t
+	}
+	if *partition >= 0 {
+		env.PartitionOverride = partition
+	}
+	if *maxAttempts > 0 || *backoffDur > 0 || *maxBackoff > 0 {
+		env.RetryPolicy = &driftq.RetryPolicy{
+			MaxAttempts:  *maxAttempts,
+			BackoffMs:    backoffDur.Milliseconds(),
+			MaxBackoffMs: maxBackoff.Milliseconds(),
+		}
+	}
+
+	values := fs.Args()
+	if len(values) == 0 {
+		var r io.Reader = e.stdin
+		if *file != "" && *file != "-" {
+			f, err := os.Open(*file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+
+		lines, err := readLines(r)
+		if err != nil {
+			return err
+		}
+		values = lines
+	} else if *file != "" {
+		return usageErrorf("produce: pass values as args or --file, not both")
+	}
+
+	c, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for i, v := range values {
+		me := *env
+		switch {
+		case *idem != "" && len(values) == 1:
+			me.IdempotencyKey = *idem
+		case *idem != "":
+			me.IdempotencyKey = *idem + "-" + strconv.Itoa(i)
+		}
+
+		req := driftq.ProduceRequest{Topic: *topic, Key: *key, Value: v}
+		if !envelopeEmpty(me) {
+			req.Envelope = &me
+		}
+		if _, err := c.Produce(ctx, req); err != nil {
+			return fmt.Errorf("message %d of %d: %w", i+1, len(values), err)
+		}
+	}
+
+	out := struct {
+		Topic    string `json:"topic"`
+		Produced int    `json:"produced"`
+	}{*topic, len(values)}
+	return e.emit(out, func(w io.Writer) {
+		fmt.Fprintf(w, "produced %d message(s) to %s\n", out.Produced, out.Topic)
+	})
+}
+
+func envelopeEmpty(e driftq.Envelope) bool {
+	return e.RunID == "" && e.StepID == "" && e.ParentStepID == "" && e.TenantID == "" &&
+		e.IdempotencyKey == "" && e.TargetTopic == "" && e.Deadline == nil &&
+		e.PartitionOverride == nil && e.RetryPolicy == nil
+}
+
+func parseDeadline(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(d).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var out []string
+	for sc.Scan() {
+		if line := sc.Text(); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out, sc.Err()
+}
+
+// ---- consume / tail ----
+
+func runConsume(ctx context.Context, e *env, args []string) error {
+	return consume(ctx, e, "consume", true, args)
+}
+
+func runTail(ctx context.Context, e *env, args []string) error {
+	return consume(ctx, e, "tail", false, args)
+}
+
+func consume(ctx context.Context, e *env, name string, ackByDefault bool, args []string) error {
+	fs := e.newFlagSet(name, name+" --topic T [flags]")
+	topic := fs.String("topic", "", "topic (required)")
+	group := fs.String("group", "driftqctl", "consumer group")
+	owner := fs.String("owner", defaultOwner(), "owner id for leases")
+	lease := fs.Duration("lease", 0, "lease duration (0 = server default)")
+	maxN := fs.Int("max", 0, "stop after this many messages (0 = until interrupted or the stream ends)")
+	ack := fs.Bool("ack", ackByDefault, "ack each message after printing it")
+	noAck := fs.Bool("no-ack", !ackByDefault, "don't ack (overrides --ack)")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(*topic) == "" {
+		return usageErrorf("%s: --topic is required", name)
+	}
+	doAck := *ack && !*noAck
+
+	c, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	opt := driftq.ConsumeOptions{Topic: *topic, Group: *group, Owner: *owner, LeaseMS: lease.Milliseconds()}
+	msgs, errs, err := c.ConsumeStream(sctx, opt)
+	if err != nil {
+		return err
+	}
+
+	var (
+		all  []driftq.ConsumeMessage
+		seen int
+	)
+	for m := range msgs {
+		seen++
+		switch e.settings.Output {
+		case "json":
+			all = append(all, m)
+		case "ndjson":
+			if err := json.NewEncoder(e.stdout).Encode(m); err != nil {
+				return err
+			}
+		default:
+			printMessage(e.stdout, *topic, m)
+		}
+
+		if doAck {
+			err := c.Ack(ctx, driftq.AckRequest{Topic: *topic, Group: *group, Owner: *owner, Partition: m.Partition, Offset: m.Offset})
+			if err != nil {
+				return fmt.Errorf("ack %d@%d: %w", m.Partition, m.Offset, err)
+			}
+		}
+
+		if *maxN > 0 && seen >= *maxN {
+			cancel()
+			break
+		}
+	}
+
+	if err, ok := <-errs; ok && err != nil && sctx.Err() == nil {
+		return err
+	}
+
+	if e.settings.Output == "json" {
+		if all == nil {
+			all = []driftq.ConsumeMessage{}
+		}
+		return e.emit(all, nil)
+	}
+	return nil
+}
+
+func printMessage(w io.Writer, topic string, m driftq.ConsumeMessage) {
+	fmt.Fprintf(w, "%s/%d@%d attempts=%d", topic, m.Partition, m.Offset, m.Attempts)
+	if m.Key != "" {
+		fmt.Fprintf(w, " key=%q", m.Key)
+	}
+	if m.Routing != nil && m.Routing.Label != "" {
+		fmt.Fprintf(w, " label=%q", m.Routing.Label)
+	}
+	if m.LastError != "" {
+		fmt.Fprintf(w, " last_error=%q", m.LastError)
+	}
+	fmt.Fprintf(w, " value=%q\n", m.Value)
+}
+
+func defaultOwner() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "driftqctl"
+	}
+	return host + "-" + strconv.Itoa(os.Getpid())
+}
+
+// ---- ack / nack ----
+
+type settleFlags struct {
+	topic, group, owner string
+	partition           int
+	offset              int64
+}
+
+func (f *settleFlags) register(e *env, name string) *flag.FlagSet {
+	fs := e.newFlagSet(name, name+" --topic T --group G --owner O --partition P --offset N")
+	fs.StringVar(&f.topic, "topic", "", "topic (required)")
+	fs.StringVar(&f.group, "group", "driftqctl", "consumer group")
+	fs.StringVar(&f.owner, "owner", "", "owner that holds the lease (required)")
+	fs.IntVar(&f.partition, "partition", 0, "partition")
+	fs.Int64Var(&f.offset, "offset", -1, "offset (required)")
+	return fs
+}
+
+func (f *settleFlags) check(name string) error {
+	if f.topic == "" || f.owner == "" || f.offset < 0 {
+		return usageErrorf("%s: --topic, --owner and --offset are required", name)
+	}
+	return nil
+}
+
+func runAck(ctx context.Context, e *env, args []string) error {
+	var f settleFlags
+	fs := f.register(e, "ack")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+	if err := f.check("ack"); err != nil {
+		return err
+	}
+
+	c, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	req := driftq.AckRequest{Topic: f.topic, Group: f.group, Owner: f.owner, Partition: f.partition, Offset: f.offset}
+	if err := c.Ack(ctx, req); err != nil {
+		return err
+	}
+	return e.emit(map[string]any{"status": "acked", "request": req}, func(w io.Writer) {
+		fmt.Fprintf(w, "acked %s/%d@%d\n", f.topic, f.partition, f.offset)
+	})
+}
+
+func runNack(ctx context.Context, e *env, args []string) error {
+	var f settleFlags
+	fs := f.register(e, "nack")
+	reason := fs.String("reason", "", "nack reason")
+	delay := fs.Duration("delay", 0, "redelivery delay")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+	if err := f.check("nack"); err != nil {
+		return err
+	}
+
+	c, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	req := driftq.NackRequest{
+		Topic: f.topic, Group: f.group, Owner: f.owner, Partition: f.partition, Offset: f.offset,
+		Reason: *reason, DelayMS: delay.Milliseconds(),
+	}
+	if err := c.Nack(ctx, req); err != nil {
+		return err
+	}
+	return e.emit(map[string]any{"status": "nacked", "request": req}, func(w io.Writer) {
+		fmt.Fprintf(w, "nacked %s/%d@%d\n", f.topic, f.partition, f.offset)
+	})
+}
+
+// ---- topics ----
+
+func runTopics(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("topics: want list, create or describe")
+	}
+
+	switch args[0] {
+	case "list":
+		return topicsList(ctx, e, args[1:])
+	case "create":
+		return topicsCreate(ctx, e, args[1:])
+	case "describe":
+		return topicsDescribe(ctx, e, args[1:])
+	default:
+		return usageErrorf("topics: unknown subcommand %q (want list, create or describe)", args[0])
+	}
+}
+
+func topicsList(ctx context.Context, e *env, args []string) error {
+	fs := e.newFlagSet("topics list", "topics list")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+
+	c, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	out, err := c.Admin().ListTopics(ctx)
+	if err != nil {
+		return err
+	}
+	return e.emit(out, func(w io.Writer) {
+		for _, t := range out.Topics {
+			fmt.Fprintln(w, t.Name)
+		}
+	})
+}
+
+func topicsCreate(ctx context.Context, e *env, args []string) error {
+	fs := e.newFlagSet("topics create", "topics create NAME [flags]")
+	partitions := fs.Int("partitions", 0, "partition count (0 = server default)")
+	retention := fs.Duration("retention", 0, "retention (0 = server default)")
+	maxBytes := fs.Int64("max-message-bytes", 0, "max message size (0 = server default)")
+	lease := fs.Duration("lease", 0, "default lease (0 = server default)")
+	name, err := parseWithName(e, fs, args, "topics create")
+	if err != nil {
+		return err
+	}
+
+	c, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	req := driftq.TopicsCreateRequest{Name: name, Partitions: *partitions}
+	if *retention > 0 || *maxBytes > 0 || *lease > 0 {
+		req.Config = &driftq.TopicConfig{
+			RetentionMs:     retention.Milliseconds(),
+			MaxMessageBytes: *maxBytes,
+			DefaultLeaseMs:  lease.Milliseconds(),
+		}
+	}
+
+	out, err := c.Admin().CreateTopicWithConfig(ctx, req)
+	if err != nil {
+		return err
+	}
+	return e.emit(out, func(w io.Writer) {
+		fmt.Fprintf(w, "created %s (%d partitions)\n", out.Name, out.Partitions)
+	})
+}
+
+func topicsDescribe(ctx context.Context, e *env, args []string) error {
+	fs := e.newFlagSet("topics describe", "topics describe NAME")
+	name, err := parseWithName(e, fs, args, "topics describe")
+	if err != nil {
+		return err
+	}
+
+	c, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	d, err := c.Admin().DescribeTopic(ctx, name)
+	if err != nil {
+		return err
+	}
+	return e.emit(d, func(w io.Writer) {
+		fmt.Fprintf(w, "topic:       %s\npartitions:  %d\n", d.Name, d.Partitions)
+		if d.Config.RetentionMs > 0 {
+			fmt.Fprintf(w, "retention:   %s\n", time.Duration(d.Config.RetentionMs)*time.Millisecond)
+		}
+		if d.Config.MaxMessageBytes > 0 {
+			fmt.Fprintf(w, "max bytes:   %d\n", d.Config.MaxMessageBytes)
+		}
+		if d.Config.DefaultLeaseMs > 0 {
+			fmt.Fprintf(w, "lease:       %s\n", time.Duration(d.Config.DefaultLeaseMs)*time.Millisecond)
+		}
+		for _, p := range d.PartitionInfo {
+			fmt.Fprintf(w, "  partition %d  high_watermark=%d\n", p.Partition, p.HighWatermark)
+		}
+	})
+}
+
+// parseWithName accepts the NAME positional before or after the flags
+func parseWithName(e *env, fs *flag.FlagSet, args []string, cmd string) (string, error) {
+	var name string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if err := e.parse(fs, args); err != nil {
+		return "", err
+	}
+	if name == "" && fs.NArg() > 0 {
+		name = fs.Arg(0)
+	}
+	if strings.TrimSpace(name) == "" {
+		return "", usageErrorf("%s: NAME is required", cmd)
+	}
+	return name, nil
+}
+
+// ---- health / version ----
+
+func runHealth(ctx context.Context, e *env, args []string) error {
+	fs := e.newFlagSet("health", "health")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+
+	c, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	h, err := c.Healthz(ctx)
+	if err != nil {
+		return err
+	}
+	return e.emit(h, func(w io.Writer) { fmt.Fprintln(w, h.Status) })
+}
+
+func runVersion(ctx context.Context, e *env, args []string) error {
+	fs := e.newFlagSet("version", "version")
+	if err := e.parse(fs, args); err != nil {
+		return err
+	}
+
+	c, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	v, err := c.Version(ctx)
+	if err != nil {
+		return err
+	}
+	return e.emit(v, func(w io.Writer) {
+		fmt.Fprintf(w, "broker %s (commit %s, wal %t), driftqctl %s\n", v.Version, v.Commit, v.WalEnabled, driftq.Version)
+	})
+}
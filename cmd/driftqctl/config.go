@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// settings are resolved defaults < config file < env < flags
+type settings struct {
+	URL       string        `json:"url"`
+	Timeout   time.Duration `json:"-"`
+	UserAgent string        `json:"user_agent"`
+	Output    string        `json:"output"`
+
+	TimeoutRaw string `json:"timeout"` // config files carry durations as strings ("10s")
+}
+
+const (
+	envURL       = "DRIFTQ_URL"
+	envTimeout   = "DRIFTQ_TIMEOUT"
+	envUserAgent = "DRIFTQ_USER_AGENT"
+	envOutput    = "DRIFTQ_OUTPUT"
+	envConfig    = "DRIFTQ_CONFIG"
+)
+
+func defaultSettings() settings {
+	return settings{URL: "http://localhost:8080", Timeout: 10 * time.Second, Output: "pretty"}
+}
+
+// loadSettings resolves everything except flags. configPath comes from a pre-scan of the args
+func loadSettings(configPath string) (settings, error) {
+	s := defaultSettings()
+
+	explicit := configPath != ""
+	if !explicit {
+		configPath = os.Getenv(envConfig)
+		explicit = configPath != ""
+	}
+	if !explicit {
+		if dir, err := os.UserConfigDir(); err == nil {
+			configPath = filepath.Join(dir, "driftq", "config.yaml")
+		}
+	}
+
+	if configPath != "" {
+		if err := s.mergeFile(configPath); err != nil {
+			if explicit || !errors.Is(err, fs.ErrNotExist) {
+				return s, err
+			}
+		}
+	}
+
+	if v := os.Getenv(envURL); v != "" {
+		s.URL = v
+	}
+	if v := os.Getenv(envTimeout); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return s, fmt.Errorf("%s: %w", envTimeout, err)
+		}
+		s.Timeout = d
+	}
+	if v := os.Getenv(envUserAgent); v != "" {
+		s.UserAgent = v
+	}
+	if v := os.Getenv(envOutput); v != "" {
+		s.Output = v
+	}
+	return s, nil
+}
+
+// mergeFile reads a YAML or JSON config file:
+//
+//	url: http://localhost:8080
+//	timeout: 10s
+//	user_agent: ops-laptop
+//	output: json
+func (s *settings) mergeFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var raw any
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	jb, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	var f settings
+	if err := json.Unmarshal(jb, &f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if f.URL != "" {
+		s.URL = f.URL
+	}
+	if f.TimeoutRaw != "" {
+		d, err := time.ParseDuration(f.TimeoutRaw)
+		if err != nil {
+			return fmt.Errorf("%s: timeout: %w", path, err)
+		}
+		s.Timeout = d
+	}
+	if f.UserAgent != "" {
+		s.UserAgent = f.UserAgent
+	}
+	if f.Output != "" {
+		s.Output = f.Output
+	}
+	return nil
+}
+
+// register adds the connection flags to fs, defaulting to the already-resolved values
+func (s *settings) register(fs *flag.FlagSet) {
+	fs.StringVar(&s.URL, "url", s.URL, "broker base URL (env "+envURL+")")
+	fs.DurationVar(&s.Timeout, "timeout", s.Timeout, "per-request timeout (env "+envTimeout+")")
+	fs.StringVar(&s.UserAgent, "user-agent", s.UserAgent, "User-Agent header (env "+envUserAgent+")")
+	fs.StringVar(&s.Output, "o", s.Output, "output: pretty, json or ndjson (env "+envOutput+")")
+	fs.String("config", "", "config file, YAML or JSON (env "+envConfig+")")
+}
+
+func (s settings) validate() error {
+	switch s.Output {
+	case "pretty", "json", "ndjson":
+		return nil
+	default:
+		return usageErrorf("unknown output %q (want pretty, json or ndjson)", s.Output)
+	}
+}
+
+// scanConfigFlag finds --config/-config before flags are parsed
+func scanConfigFlag(args []string) string {
+	for i, a := range args {
+		name, val, hasVal := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		if !strings.HasPrefix(a, "-") || name != "config" {
+			continue
+		}
+		if hasVal {
+			return val
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
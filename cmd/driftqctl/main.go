@@ -0,0 +1,211 @@
+// Command driftqctl talks to a DriftQ broker: produce, consume/tail, ack/nack, topics, health and version.
+//
+// Connection settings come from flags, then DRIFTQ_* env vars, then a config file
+// (--config, $DRIFTQ_CONFIG or <user config dir>/driftq/config.yaml).
+//
+// Exit codes:
+//
+//	0  ok
+//	1  other error
+//	2  usage error
+//	3  not found (topic, message)
+//	4  conflict (topic exists, lease held)
+//	5  broker unavailable (network error, 429, 5xx)
+//	6  timed out
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq"
+)
+
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 2
+	exitNotFound    = 3
+	exitConflict    = 4
+	exitUnavailable = 5
+	exitTimeout     = 6
+)
+
+const usage = `usage: driftqctl [connection flags] <command> [flags] [args]
+
+commands:
+  produce   produce messages from args, stdin lines or --file
+  consume   stream messages (acks by default; --no-ack to only look)
+  tail      consume without acking
+  ack       ack a message by partition/offset
+  nack      nack a message by partition/offset
+  topics    list | create | describe
+  health    broker health
+  version   broker version
+
+connection flags (any command): --url, --timeout, --user-agent, --config, -o
+run "driftqctl <command> -h" for command flags
+`
+
+type usageError struct{ msg string }
+
+func (e *usageError) Error() string { return e.msg }
+
+func usageErrorf(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// env is what a command gets to work with; tests swap the streams
+type env struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+	settings       settings
+}
+
+type command func(ctx context.Context, e *env, args []string) error
+
+var commands = map[string]command{
+	"produce": runProduce,
+	"consume": runConsume,
+	"tail":    runTail,
+	"ack":     runAck,
+	"nack":    runNack,
+	"topics":  runTopics,
+	"health":  runHealth,
+	"version": runVersion,
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	s, err := loadSettings(scanConfigFlag(args))
+	if err != nil {
+		fmt.Fprintln(stderr, "driftqctl:", err)
+		return exitUsage
+	}
+
+	e := &env{stdin: stdin, stdout: stdout, stderr: stderr, settings: s}
+
+	global := flag.NewFlagSet("driftqctl", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() { fmt.Fprint(stderr, usage) }
+	e.settings.register(global)
+
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	rest := global.Args()
+	if len(rest) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	cmd, ok := commands[rest[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "driftqctl: unknown command %q\n\n%s", rest[0], usage)
+		return exitUsage
+	}
+
+	err = cmd(ctx, e, rest[1:])
+	if err == nil {
+		return exitOK
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+
+	fmt.Fprintln(stderr, "driftqctl:", err)
+	return exitCode(err)
+}
+
+func exitCode(err error) int {
+	var ue *usageError
+	if errors.As(err, &ue) {
+		return exitUsage
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return exitTimeout
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return exitTimeout
+	}
+
+	if errors.Is(err, driftq.ErrTopicNotFound) || errors.Is(err, driftq.ErrGroupNotFound) {
+		return exitNotFound
+	}
+	if errors.Is(err, driftq.ErrTopicExists) || errors.Is(err, driftq.ErrConflict) {
+		return exitConflict
+	}
+
+	var ae *driftq.APIError
+	if errors.As(err, &ae) {
+		switch {
+		case ae.Status == http.StatusNotFound:
+			return exitNotFound
+		case ae.Status == http.StatusConflict:
+			return exitConflict
+		case ae.Status == http.StatusTooManyRequests || ae.Status >= 500:
+			return exitUnavailable
+		}
+		return exitError
+	}
+
+	if errors.As(err, &ne) {
+		return exitUnavailable
+	}
+	var oe *net.OpError
+	if errors.As(err, &oe) {
+		return exitUnavailable
+	}
+	return exitError
+}
+
+// newFlagSet makes a subcommand flag set that also takes the connection flags
+func (e *env) newFlagSet(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: driftqctl %s\n\nflags:\n", synopsis)
+		fs.PrintDefaults()
+	}
+	e.settings.register(fs)
+	return fs
+}
+
+// parse parses fs and turns flag errors into usage errors
+func (e *env) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &usageError{msg: err.Error()}
+	}
+	return e.settings.validate()
+}
+
+func (e *env) dial(ctx context.Context) (*driftq.Client, error) {
+	return driftq.Dial(ctx, driftq.Config{
+		BaseURL:   e.settings.URL,
+		Timeout:   e.settings.Timeout,
+		UserAgent: e.settings.UserAgent,
+	})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq"
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq/driftqtest"
+)
+
+type result struct {
+	code           int
+	stdout, stderr string
+}
+
+func ctl(t *testing.T, stdin string, args ...string) result {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var out, errb bytes.Buffer
+	code := run(ctx, args, strings.NewReader(stdin), &out, &errb)
+	return result{code, out.String(), errb.String()}
+}
+
+func isolateEnv(t *testing.T) {
+	t.Helper()
+
+	for _, k := range []string{envURL, envTimeout, envUserAgent, envOutput, envConfig} {
+		t.Setenv(k, "")
+	}
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+}
+
+func TestDriftqctl_ProduceConsumeTail(t *testing.T) {
+	isolateEnv(t)
+
+	srv := driftqtest.NewServer(driftqtest.Options{})
+	defer srv.Close()
+	srv.CreateTopic("demo", 1)
+	url := "--url=" + srv.URL
+
+	r := ctl(t, "", url, "produce", "--topic", "demo", "--key", "k", "--idempotency-key", "id", "--tenant", "acme", "one", "two")
+	if r.code != exitOK || r.stdout != "produced 2 message(s) to demo\n" {
+		t.Fatalf("produce args: %+v", r)
+	}
+
+	r = ctl(t, "three\n\nfour\n", url, "-o", "json", "produce", "--topic", "demo")
+	if r.code != exitOK || !strings.Contains(r.stdout, `"produced": 2`) {
+		t.Fatalf("produce stdin: %+v", r)
+	}
+
+	msgs := srv.Messages("demo")
+	if len(msgs) != 4 || msgs[1].Envelope.IdempotencyKey != "id-1" || msgs[0].Envelope.TenantID != "acme" || msgs[2].Envelope != nil {
+		t.Fatalf("unexpected stored messages %#v", msgs)
+	}
+
+	// tail looks without acking
+	r = ctl(t, "", url, "tail", "--topic", "demo", "--group", "g", "--owner", "o1", "--max", "2")
+	if r.code != exitOK || strings.Count(r.stdout, "\n") != 2 || !strings.Contains(r.stdout, `demo/0@0 attempts=1 key="k" value="one"`) {
+		t.Fatalf("tail: %+v", r)
+	}
+	if n := len(srv.Acked("demo", "g")); n != 0 {
+		t.Fatalf("tail acked %d messages", n)
+	}
+
+	// consume acks; ndjson output decodes back into ConsumeMessage
+	r = ctl(t, "", url, "-o", "ndjson", "consume", "--topic", "demo", "--group", "g2", "--owner", "o2", "--max", "4")
+	if r.code != exitOK {
+		t.Fatalf("consume: %+v", r)
+	}
+	dec := json.NewDecoder(strings.NewReader(r.stdout))
+	var got []string
+	for dec.More() {
+		var m driftq.ConsumeMessage
+		if err := dec.Decode(&m); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		got = append(got, m.Value)
+	}
+	if strings.Join(got, ",") != "one,two,three,four" || len(srv.Acked("demo", "g2")) != 4 {
+		t.Fatalf("consume got %v, acked %d", got, len(srv.Acked("demo", "g2")))
+	}
+
+	// nack the leased message from the tail above, by hand
+	r = ctl(t, "", url, "nack", "--topic", "demo", "--group", "g", "--owner", "o1", "--offset", "0", "--reason", "manual")
+	if r.code != exitOK || len(srv.Nacked("demo", "g")) != 1 {
+		t.Fatalf("nack: %+v", r)
+	}
+}
+
+func TestDriftqctl_TopicsAndExitCodes(t *testing.T) {
+	isolateEnv(t)
+
+	srv := driftqtest.NewServer(driftqtest.Options{Version: "v9"})
+	defer srv.Close()
+	url := "--url=" + srv.URL
+
+	if r := ctl(t, "", url, "topics", "create", "orders", "--partitions", "3", "--retention", "1h"); r.code != exitOK {
+		t.Fatalf("create: %+v", r)
+	}
+	if r := ctl(t, "", url, "topics", "create", "orders"); r.code != exitConflict {
+		t.Fatalf("create existing: expected %d, got %+v", exitConflict, r)
+	}
+
+	r := ctl(t, "", url, "-o", "json", "topics", "describe", "orders")
+	var d driftq.TopicDescription
+	if r.code != exitOK || json.Unmarshal([]byte(r.stdout), &d) != nil || d.Partitions != 3 || d.Config.RetentionMs != 3600000 {
+		t.Fatalf("describe: %+v", r)
+	}
+
+	if r := ctl(t, "", url, "topics", "describe", "missing"); r.code != exitNotFound {
+		t.Fatalf("describe missing: expected %d, got %+v", exitNotFound, r)
+	}
+	if r := ctl(t, "", url, "topics", "list"); r.code != exitOK || r.stdout != "orders\n" {
+		t.Fatalf("list: %+v", r)
+	}
+	if r := ctl(t, "", url, "version"); r.code != exitOK || !strings.HasPrefix(r.stdout, "broker v9") {
+		t.Fatalf("version: %+v", r)
+	}
+
+	srv.FailNext("/v1/healthz", 503, 3)
+	if r := ctl(t, "", url, "health"); r.code != exitUnavailable {
+		t.Fatalf("health: expected %d, got %+v", exitUnavailable, r)
+	}
+
+	if r := ctl(t, "", url, "produce"); r.code != exitUsage {
+		t.Fatalf("missing topic: expected %d, got %+v", exitUsage, r)
+	}
+	if r := ctl(t, "", "bogus"); r.code != exitUsage {
+		t.Fatalf("unknown command: expected %d, got %+v", exitUsage, r)
+	}
+}
+
+func TestDriftqctl_SettingsPrecedence(t *testing.T) {
+	isolateEnv(t)
+
+	srv := driftqtest.NewServer(driftqtest.Options{Version: "from-file"})
+	defer srv.Close()
+
+	cfg := filepath.Join(t.TempDir(), "driftq.yaml")
+	if err := os.WriteFile(cfg, []byte("url: "+srv.URL+"\noutput: json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Config file supplies url and output
+	r := ctl(t, "", "--config", cfg, "version")
+	if r.code != exitOK || !strings.Contains(r.stdout, `"version": "from-file"`) {
+		t.Fatalf("config file: %+v", r)
+	}
+
+	// Env beats the file, flags beat env
+	t.Setenv(envOutput, "pretty")
+	if r := ctl(t, "", "--config", cfg, "version"); r.code != exitOK || !strings.HasPrefix(r.stdout, "broker from-file") {
+		t.Fatalf("env override: %+v", r)
+	}
+	if r := ctl(t, "", "--config", cfg, "version", "-o", "ndjson"); r.code != exitOK || !strings.HasPrefix(r.stdout, `{"version":"from-file"`) {
+		t.Fatalf("flag override: %+v", r)
+	}
+
+	t.Setenv(envConfig, filepath.Join(t.TempDir(), "missing.yaml"))
+	if r := ctl(t, "", "version"); r.code != exitUsage {
+		t.Fatalf("missing explicit config: expected %d, got %+v", exitUsage, r)
+	}
+}
@@ -2,13 +2,24 @@ package driftq
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 type Admin struct{ c *Client }
 
 func (c *Client) Admin() *Admin { return &Admin{c: c} }
 
+// Status mappings for calls on one topic: 404 => ErrTopicNotFound, 409 => ErrTopicExists (create) or ErrConflict.
+// List calls return the plain status error
+var (
+	topicErrs       = map[int]error{http.StatusNotFound: ErrTopicNotFound, http.StatusConflict: ErrConflict}
+	topicCreateErrs = map[int]error{http.StatusNotFound: ErrTopicNotFound, http.StatusConflict: ErrTopicExists}
+	groupErrs       = map[int]error{http.StatusNotFound: ErrGroupNotFound, http.StatusConflict: ErrConflict}
+)
+
 func (a *Admin) ListTopics(ctx context.Context) (TopicsListResponse, error) {
 	var out TopicsListResponse
 	err := a.c.doJSON(ctx, http.MethodGet, "/v1/topics", nil, nil, &out)
@@ -17,8 +28,176 @@ func (a *Admin) ListTopics(ctx context.Context) (TopicsListResponse, error) {
 
 // partitions: pass 0 to let server default to 1
 func (a *Admin) CreateTopic(ctx context.Context, name string, partitions int) (TopicsCreateResponse, error) {
-	in := TopicsCreateRequest{Name: name, Partitions: partitions}
+	return a.CreateTopicWithConfig(ctx, TopicsCreateRequest{Name: name, Partitions: partitions})
+}
+
+// CreateTopicWithConfig is CreateTopic with per-topic settings (req.Config may be nil)
+func (a *Admin) CreateTopicWithConfig(ctx context.Context, in TopicsCreateRequest) (TopicsCreateResponse, error) {
 	var out TopicsCreateResponse
 	err := a.c.doJSON(ctx, http.MethodPost, "/v1/topics", nil, in, &out)
+	return out, mapStatusErr(err, topicCreateErrs)
+}
+
+// DescribeTopic returns partitions, config and per-partition high-water marks.
+// Older servers may return less; missing fields are left zero
+func (a *Admin) DescribeTopic(ctx context.Context, name string) (TopicDescription, error) {
+	p, err := topicPath(name)
+	if err != nil {
+		return TopicDescription{}, err
+	}
+
+	var out TopicDescription
+	err = a.c.doJSON(ctx, http.MethodGet, p, nil, nil, &out)
+	if err == nil && out.Name == "" {
+		out.Name = name
+	}
+	return out, mapStatusErr(err, topicErrs)
+}
+
+func (a *Admin) DeleteTopic(ctx context.Context, name string) error {
+	p, err := topicPath(name)
+	if err != nil {
+		return err
+	}
+
+	err = a.c.doJSON(ctx, http.MethodDelete, p, nil, nil, nil)
+	return mapStatusErr(err, topicErrs)
+}
+
+// UpdateTopicConfig changes only the fields set in upd and returns the topic as the server reports it
+func (a *Admin) UpdateTopicConfig(ctx context.Context, name string, upd TopicConfigUpdate) (TopicDescription, error) {
+	p, err := topicPath(name)
+	if err != nil {
+		return TopicDescription{}, err
+	}
+
+	var out TopicDescription
+	err = a.c.doJSON(ctx, http.MethodPatch, p+"/config", nil, upd, &out)
+	if err == nil && out.Name == "" {
+		out.Name = name
+	}
+	return out, mapStatusErr(err, topicErrs)
+}
+
+// AddPartitions grows the topic to total partitions (partitions can't be removed; shrinking is a 409)
+func (a *Admin) AddPartitions(ctx context.Context, name string, total int) (TopicDescription, error) {
+	p, err := topicPath(name)
+	if err != nil {
+		return TopicDescription{}, err
+	}
+
+	if total <= 0 {
+		return TopicDescription{}, errors.New("partitions must be > 0")
+	}
+
+	var out TopicDescription
+	err = a.c.doJSON(ctx, http.MethodPost, p+"/partitions", nil, TopicsAddPartitionsRequest{Partitions: total}, &out)
+	if err == nil && out.Name == "" {
+		out.Name = name
+	}
+	return out, mapStatusErr(err, topicErrs)
+}
+
+// ---- Consumer groups ----
+
+func (a *Admin) ListGroups(ctx context.Context) (GroupsListResponse, error) {
+	var out GroupsListResponse
+	err := a.c.doJSON(ctx, http.MethodGet, "/v1/groups", nil, nil, &out)
 	return out, err
 }
+
+// DescribeGroup returns members, per-partition committed offsets, in-flight leases and lag.
+// topic is optional and narrows the result to one topic. Lag is derived from the high-water mark
+// when the server does not report it
+func (a *Admin) DescribeGroup(ctx context.Context, group, topic string) (GroupDescription, error) {
+	p, err := groupPath(group)
+	if err != nil {
+		return GroupDescription{}, err
+	}
+
+	var q url.Values
+	if t := strings.TrimSpace(topic); t != "" {
+		q = url.Values{"topic": {t}}
+	}
+
+	var out GroupDescription
+	if err := a.c.doJSON(ctx, http.MethodGet, p, q, nil, &out); err != nil {
+		return GroupDescription{}, mapStatusErr(err, groupErrs)
+	}
+
+	if out.Name == "" {
+		out.Name = group
+	}
+	for i := range out.Partitions {
+		gp := &out.Partitions[i]
+		if gp.Lag == 0 && gp.HighWatermark > gp.CommittedOffset {
+			gp.Lag = gp.HighWatermark - gp.CommittedOffset
+		}
+	}
+	return out, nil
+}
+
+// ResetGroupOffsets moves the group's committed offsets; with req.DryRun the server only reports the plan
+func (a *Admin) ResetGroupOffsets(ctx context.Context, group string, req GroupOffsetsResetRequest) (GroupOffsetsResetResponse, error) {
+	p, err := groupPath(group)
+	if err != nil {
+		return GroupOffsetsResetResponse{}, err
+	}
+
+	if strings.TrimSpace(req.Topic) == "" {
+		return GroupOffsetsResetResponse{}, errors.New("topic is required")
+	}
+
+	switch req.To {
+	case ResetToEarliest, ResetToLatest:
+	case ResetToTimestamp:
+		if req.TimestampMs <= 0 {
+			return GroupOffsetsResetResponse{}, errors.New("timestamp_ms is required when resetting to a timestamp")
+		}
+	case ResetToOffset:
+		if req.Offset < 0 {
+			return GroupOffsetsResetResponse{}, errors.New("offset must be >= 0")
+		}
+	default:
+		return GroupOffsetsResetResponse{}, errors.New("to must be earliest, latest, timestamp or offset")
+	}
+
+	var out GroupOffsetsResetResponse
+	err = a.c.doJSON(ctx, http.MethodPost, p+"/offsets/reset", nil, req, &out)
+	if err == nil {
+		if out.Group == "" {
+			out.Group = group
+		}
+		if out.Topic == "" {
+			out.Topic = req.Topic
+		}
+	}
+	return out, mapStatusErr(err, groupErrs)
+}
+
+// DeleteGroup removes the group and its committed offsets (409 => ErrConflict while it has members)
+func (a *Admin) DeleteGroup(ctx context.Context, group string) error {
+	p, err := groupPath(group)
+	if err != nil {
+		return err
+	}
+
+	err = a.c.doJSON(ctx, http.MethodDelete, p, nil, nil, nil)
+	return mapStatusErr(err, groupErrs)
+}
+
+func groupPath(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("group name is required")
+	}
+	return "/v1/groups/" + url.PathEscape(name), nil
+}
+
+func topicPath(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("topic name is required")
+	}
+	return "/v1/topics/" + url.PathEscape(name), nil
+}
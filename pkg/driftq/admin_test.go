@@ -0,0 +1,235 @@
+package driftq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTopicDescription_TolerantDecoding(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want TopicDescription
+	}{
+		{"bare name", `"demo"`, TopicDescription{Name: "demo"}},
+		{"thin object", `{"name":"demo","partitions":3}`, TopicDescription{Name: "demo", Partitions: 3}},
+		{
+			"rich object",
+			`{"name":"demo","partitions":[{"partition":0,"high_watermark":10},{"partition":1,"high_watermark":7}],"config":{"retention_ms":60000,"default_lease_ms":30000}}`,
+			TopicDescription{
+				Name:          "demo",
+				Partitions:    2,
+				Config:        TopicConfig{RetentionMs: 60000, DefaultLeaseMs: 30000},
+				PartitionInfo: []PartitionInfo{{0, 10}, {1, 7}},
+			},
+		},
+		{
+			"wrapped, flat config, alternate keys",
+			`{"topic":{"name":"demo","retention_ms":5,"max_message_bytes":1024,"partitions":[{"id":0,"hwm":3},{"id":1,"end_offset":4}]}}`,
+			TopicDescription{
+				Name:          "demo",
+				Partitions:    2,
+				Config:        TopicConfig{RetentionMs: 5, MaxMessageBytes: 1024},
+				PartitionInfo: []PartitionInfo{{0, 3}, {1, 4}},
+			},
+		},
+		{"partition id list", `{"name":"demo","partitions":[0,1,2]}`, TopicDescription{Name: "demo", Partitions: 3, PartitionInfo: []PartitionInfo{{0, 0}, {1, 0}, {2, 0}}}},
+	}
+
+	for _, tc := range cases {
+		var got TopicDescription
+		if err := json.Unmarshal([]byte(tc.in), &got); err != nil {
+			t.Fatalf("%s: Unmarshal: %v", tc.name, err)
+		}
+
+		gb, _ := json.Marshal(got)
+		wb, _ := json.Marshal(tc.want)
+		if string(gb) != string(wb) {
+			t.Fatalf("%s:\n got %s\nwant %s", tc.name, gb, wb)
+		}
+	}
+}
+
+func TestAdmin_TopicCallsAndErrorMapping(t *testing.T) {
+	var lastMethod, lastPath, lastBody string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		lastMethod, lastPath, lastBody = r.Method, r.URL.EscapedPath(), string(b)
+
+		switch {
+		case r.URL.Path == "/v1/topics" && r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(ErrorResponse{Error: "ALREADY_EXISTS", Message: "topic exists"})
+
+		case r.URL.Path == "/v1/topics/missing":
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(ErrorResponse{Error: "NOT_FOUND", Message: "no such topic"})
+
+		case r.URL.Path == "/v1/topics/a b" && r.Method == http.MethodGet:
+			_, _ = w.Write([]byte(`{"partitions":2}`))
+
+		case r.URL.Path == "/v1/topics/demo/config":
+			_, _ = w.Write([]byte(`{"name":"demo","config":{"retention_ms":1000}}`))
+
+		case r.URL.Path == "/v1/topics/demo/partitions":
+			_, _ = w.Write([]byte(`{"name":"demo","partitions":4}`))
+
+		case r.URL.Path == "/v1/topics/demo" && r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL, Retry: RetryConfig{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	a := c.Admin()
+	ctx := context.Background()
+
+	_, err = a.CreateTopicWithConfig(ctx, TopicsCreateRequest{Name: "demo", Partitions: 2, Config: &TopicConfig{RetentionMs: 1000}})
+	var ae *APIError
+	if !errors.Is(err, ErrTopicExists) || !errors.As(err, &ae) || ae.Status != http.StatusConflict {
+		t.Fatalf("create: expected ErrTopicExists wrapping a 409 APIError, got %v", err)
+	}
+	if lastBody != `{"name":"demo","partitions":2,"config":{"retention_ms":1000}}` {
+		t.Fatalf("create: unexpected body %s", lastBody)
+	}
+
+	if _, err := a.DescribeTopic(ctx, "missing"); !errors.Is(err, ErrTopicNotFound) {
+		t.Fatalf("describe: expected ErrTopicNotFound, got %v", err)
+	}
+
+	d, err := a.DescribeTopic(ctx, "a b")
+	if err != nil || d.Name != "a b" || d.Partitions != 2 || lastPath != "/v1/topics/a%20b" {
+		t.Fatalf("describe: got %#v err=%v path=%s", d, err, lastPath)
+	}
+
+	retention := int64(1000)
+	d, err = a.UpdateTopicConfig(ctx, "demo", TopicConfigUpdate{RetentionMs: &retention})
+	if err != nil || d.Config.RetentionMs != 1000 || lastMethod != http.MethodPatch || lastBody != `{"retention_ms":1000}` {
+		t.Fatalf("update: got %#v err=%v method=%s body=%s", d, err, lastMethod, lastBody)
+	}
+
+	d, err = a.AddPartitions(ctx, "demo", 4)
+	if err != nil || d.Partitions != 4 || lastBody != `{"partitions":4}` {
+		t.Fatalf("add partitions: got %#v err=%v body=%s", d, err, lastBody)
+	}
+
+	if err := a.DeleteTopic(ctx, "demo"); err != nil || lastMethod != http.MethodDelete {
+		t.Fatalf("delete: err=%v method=%s", err, lastMethod)
+	}
+
+	if err := a.DeleteTopic(ctx, "missing"); !errors.Is(err, ErrTopicNotFound) {
+		t.Fatalf("delete: expected ErrTopicNotFound, got %v", err)
+	}
+
+	// A 404 on a list call means the endpoint is missing, not a topic
+	if _, err := a.ListTopics(ctx); errors.Is(err, ErrTopicNotFound) || !errors.As(err, &ae) || ae.Status != http.StatusNotFound {
+		t.Fatalf("list: expected a plain 404 APIError, got %v", err)
+	}
+}
+
+func TestAdmin_GroupCalls(t *testing.T) {
+	var lastMethod, lastPath, lastQuery, lastBody string
+	noList := false
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		lastMethod, lastPath, lastQuery, lastBody = r.Method, r.URL.EscapedPath(), r.URL.RawQuery, string(b)
+
+		switch {
+		case r.URL.Path == "/v1/groups" && noList:
+			http.NotFound(w, r)
+
+		case r.URL.Path == "/v1/groups":
+			_, _ = w.Write([]byte(`{"groups":["a",{"name":"b","topics":["demo"]}]}`))
+
+		case r.URL.Path == "/v1/groups/missing":
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(ErrorResponse{Error: "NOT_FOUND", Message: "no such group"})
+
+		case r.URL.Path == "/v1/groups/busy" && r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(ErrorResponse{Error: "CONFLICT", Message: "group has members"})
+
+		case r.URL.Path == "/v1/groups/workers" && r.Method == http.MethodGet:
+			_, _ = w.Write([]byte(`{"members":["w1",{"owner":"w2","topic":"demo","partitions":[1]}],"partitions":[
+				{"topic":"demo","partition":0,"committed_offset":5,"high_watermark":9,"in_flight":2,"owner":"w1"},
+				{"topic":"demo","partition":1,"committed_offset":3,"high_watermark":3,"lag":0}]}`))
+
+		case r.URL.Path == "/v1/groups/workers/offsets/reset":
+			_, _ = w.Write([]byte(`{"dry_run":true,"partitions":[{"partition":0,"previous_offset":5,"new_offset":0}]}`))
+
+		case r.URL.Path == "/v1/groups/workers" && r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL, Retry: RetryConfig{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	a := c.Admin()
+	ctx := context.Background()
+
+	gl, err := a.ListGroups(ctx)
+	if err != nil || len(gl.Groups) != 2 || gl.Groups[0].Name != "a" || gl.Groups[1].Topics[0] != "demo" {
+		t.Fatalf("list: got %#v err=%v", gl, err)
+	}
+
+	// As for topics, a 404 on the list call is a plain status error, not a missing group
+	noList = true
+	var ae *APIError
+	if _, err := a.ListGroups(ctx); errors.Is(err, ErrGroupNotFound) || !errors.As(err, &ae) || ae.Status != http.StatusNotFound {
+		t.Fatalf("list: expected a plain 404 APIError, got %v", err)
+	}
+	noList = false
+
+	if _, err := a.DescribeGroup(ctx, "missing", ""); !errors.Is(err, ErrGroupNotFound) {
+		t.Fatalf("describe: expected ErrGroupNotFound, got %v", err)
+	}
+
+	d, err := a.DescribeGroup(ctx, "workers", "demo")
+	if err != nil {
+		t.Fatalf("describe: %v", err)
+	}
+	if lastQuery != "topic=demo" || d.Name != "workers" || len(d.Members) != 2 || d.Members[0].Owner != "w1" {
+		t.Fatalf("describe: got %#v query=%s", d, lastQuery)
+	}
+	if d.Partitions[0].Lag != 4 || d.Partitions[0].InFlight != 2 || d.TotalLag() != 4 {
+		t.Fatalf("describe: expected lag derived from high-water mark, got %#v", d.Partitions)
+	}
+
+	if _, err := a.ResetGroupOffsets(ctx, "workers", GroupOffsetsResetRequest{Topic: "demo", To: ResetToTimestamp}); err == nil {
+		t.Fatalf("reset: expected validation error for missing timestamp")
+	}
+
+	rr, err := a.ResetGroupOffsets(ctx, "workers", GroupOffsetsResetRequest{Topic: "demo", To: ResetToEarliest, DryRun: true})
+	if err != nil || !rr.DryRun || rr.Group != "workers" || rr.Topic != "demo" || rr.Partitions[0].NewOffset != 0 {
+		t.Fatalf("reset: got %#v err=%v", rr, err)
+	}
+	if lastMethod != http.MethodPost || lastBody != `{"topic":"demo","to":"earliest","dry_run":true}` {
+		t.Fatalf("reset: method=%s body=%s", lastMethod, lastBody)
+	}
+
+	if err := a.DeleteGroup(ctx, "busy"); !errors.Is(err, ErrConflict) {
+		t.Fatalf("delete: expected ErrConflict, got %v", err)
+	}
+	if err := a.DeleteGroup(ctx, "workers"); err != nil || lastPath != "/v1/groups/workers" {
+		t.Fatalf("delete: err=%v path=%s", err, lastPath)
+	}
+}
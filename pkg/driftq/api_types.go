@@ -69,8 +69,158 @@ type TopicsListResponse struct {
 }
 
 type TopicsCreateRequest struct {
-	Name       string `json:"name"`
-	Partitions int    `json:"partitions,omitempty"`
+	Name       string       `json:"name"`
+	Partitions int          `json:"partitions,omitempty"`
+	Config     *TopicConfig `json:"config,omitempty"` // optional; older servers ignore it
+}
+
+// TopicConfig holds per-topic settings. Zero values mean "server default"
+type TopicConfig struct {
+	RetentionMs     int64 `json:"retention_ms,omitempty"`
+	MaxMessageBytes int64 `json:"max_message_bytes,omitempty"`
+	DefaultLeaseMs  int64 `json:"default_lease_ms,omitempty"`
+}
+
+// TopicConfigUpdate is a partial update: only non-nil fields are sent
+type TopicConfigUpdate struct {
+	RetentionMs     *int64 `json:"retention_ms,omitempty"`
+	MaxMessageBytes *int64 `json:"max_message_bytes,omitempty"`
+	DefaultLeaseMs  *int64 `json:"default_lease_ms,omitempty"`
+}
+
+type TopicsAddPartitionsRequest struct {
+	Partitions int `json:"partitions"` // new total partition count
+}
+
+type PartitionInfo struct {
+	Partition     int   `json:"partition"`
+	HighWatermark int64 `json:"high_watermark"`
+}
+
+// TopicDescription supports thin and rich server encodings, like Topic:
+// 1) "demo"
+// 2) {"name":"demo","partitions":3}
+// 3) {"name":"demo","partitions":[{"partition":0,"high_watermark":42}],"config":{"retention_ms":...}}
+// Config fields may also be flat on the object, and the whole thing may be wrapped in {"topic":{...}}
+type TopicDescription struct {
+	Name          string          `json:"name"`
+	Partitions    int             `json:"partitions"`
+	Config        TopicConfig     `json:"config"`
+	PartitionInfo []PartitionInfo `json:"partition_info,omitempty"`
+}
+
+func (t *TopicDescription) UnmarshalJSON(b []byte) error {
+	*t = TopicDescription{}
+
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &t.Name)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	if inner, ok := raw["topic"]; ok && len(inner) > 0 && inner[0] == '{' {
+		return t.UnmarshalJSON(inner)
+	}
+
+	if v, ok := raw["name"]; ok {
+		if err := json.Unmarshal(v, &t.Name); err != nil {
+			return err
+		}
+	}
+
+	// Flat config first, then a nested "config" object overrides it
+	if err := json.Unmarshal(b, &t.Config); err != nil {
+		return err
+	}
+	if v, ok := raw["config"]; ok && string(v) != "null" {
+		if err := json.Unmarshal(v, &t.Config); err != nil {
+			return err
+		}
+	}
+
+	if v, ok := raw["partition_info"]; ok {
+		if err := decodePartitionInfo(v, &t.PartitionInfo); err != nil {
+			return err
+		}
+	}
+
+	if v, ok := raw["partitions"]; ok && len(v) > 0 {
+		switch v[0] {
+		case '[':
+			var infos []PartitionInfo
+			if err := decodePartitionInfo(v, &infos); err != nil {
+				return err
+			}
+			t.Partitions = len(infos)
+			if t.PartitionInfo == nil {
+				t.PartitionInfo = infos
+			}
+		case 'n': // null
+		default:
+			if err := json.Unmarshal(v, &t.Partitions); err != nil {
+				return err
+			}
+		}
+	}
+
+	if t.Partitions == 0 {
+		t.Partitions = len(t.PartitionInfo)
+	}
+	return nil
+}
+
+// decodePartitionInfo accepts [0,1,2] or objects using partition/id and high_watermark/hwm/end_offset
+func decodePartitionInfo(b []byte, out *[]PartitionInfo) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(b, &items); err != nil {
+		return err
+	}
+
+	infos := make([]PartitionInfo, 0, len(items))
+	for i, it := range items {
+		if len(it) > 0 && it[0] != '{' {
+			var id int
+			if err := json.Unmarshal(it, &id); err != nil {
+				return err
+			}
+			infos = append(infos, PartitionInfo{Partition: id})
+			continue
+		}
+
+		var o struct {
+			Partition     *int   `json:"partition"`
+			ID            *int   `json:"id"`
+			HighWatermark *int64 `json:"high_watermark"`
+			HWM           *int64 `json:"hwm"`
+			EndOffset     *int64 `json:"end_offset"`
+		}
+		if err := json.Unmarshal(it, &o); err != nil {
+			return err
+		}
+
+		pi := PartitionInfo{Partition: i}
+		switch {
+		case o.Partition != nil:
+			pi.Partition = *o.Partition
+		case o.ID != nil:
+			pi.Partition = *o.ID
+		}
+		switch {
+		case o.HighWatermark != nil:
+			pi.HighWatermark = *o.HighWatermark
+		case o.HWM != nil:
+			pi.HighWatermark = *o.HWM
+		case o.EndOffset != nil:
+			pi.HighWatermark = *o.EndOffset
+		}
+		infos = append(infos, pi)
+	}
+
+	*out = infos
+	return nil
 }
 
 type TopicsCreateResponse struct {
@@ -78,3 +228,117 @@ type TopicsCreateResponse struct {
 	Name       string `json:"name"`
 	Partitions int    `json:"partitions"`
 }
+
+// ---- Consumer groups (Admin API) ----
+
+// Group supports BOTH server encodings, like Topic:
+// 1) "workers"
+// 2) {"name":"workers","topics":["demo"]}
+type Group struct {
+	Name   string   `json:"name"`
+	Topics []string `json:"topics,omitempty"`
+}
+
+func (g *Group) UnmarshalJSON(b []byte) error {
+	*g = Group{}
+
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &g.Name)
+	}
+
+	type groupObj Group
+	var o groupObj
+	if err := json.Unmarshal(b, &o); err != nil {
+		return err
+	}
+
+	*g = Group(o)
+	return nil
+}
+
+type GroupsListResponse struct {
+	Groups []Group `json:"groups"`
+}
+
+type GroupMember struct {
+	Owner      string `json:"owner"`
+	Topic      string `json:"topic,omitempty"`
+	Partitions []int  `json:"partitions,omitempty"`
+}
+
+// GroupMember also accepts a bare owner string
+func (m *GroupMember) UnmarshalJSON(b []byte) error {
+	*m = GroupMember{}
+
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &m.Owner)
+	}
+
+	type memberObj GroupMember
+	var o memberObj
+	if err := json.Unmarshal(b, &o); err != nil {
+		return err
+	}
+
+	*m = GroupMember(o)
+	return nil
+}
+
+// GroupPartition is one partition's progress for a group.
+// CommittedOffset is the next offset the group will read; Lag = HighWatermark - CommittedOffset
+type GroupPartition struct {
+	Topic           string `json:"topic"`
+	Partition       int    `json:"partition"`
+	CommittedOffset int64  `json:"committed_offset"`
+	HighWatermark   int64  `json:"high_watermark"`
+	Lag             int64  `json:"lag"`
+	InFlight        int    `json:"in_flight"` // leased, not yet acked
+	Owner           string `json:"owner,omitempty"`
+}
+
+type GroupDescription struct {
+	Name       string           `json:"name"`
+	Members    []GroupMember    `json:"members"`
+	Partitions []GroupPartition `json:"partitions"`
+}
+
+// TotalLag sums lag over every partition
+func (g GroupDescription) TotalLag() int64 {
+	var n int64
+	for _, p := range g.Partitions {
+		n += p.Lag
+	}
+	return n
+}
+
+// OffsetResetTarget says where ResetGroupOffsets moves the group to
+type OffsetResetTarget string
+
+const (
+	ResetToEarliest  OffsetResetTarget = "earliest"
+	ResetToLatest    OffsetResetTarget = "latest"
+	ResetToTimestamp OffsetResetTarget = "timestamp"
+	ResetToOffset    OffsetResetTarget = "offset"
+)
+
+type GroupOffsetsResetRequest struct {
+	Topic       string            `json:"topic"`
+	Partitions  []int             `json:"partitions,omitempty"` // empty = every partition
+	To          OffsetResetTarget `json:"to"`
+	TimestampMs int64             `json:"timestamp_ms,omitempty"` // ResetToTimestamp
+	Offset      int64             `json:"offset,omitempty"`       // ResetToOffset
+	DryRun      bool              `json:"dry_run,omitempty"`
+}
+
+type PartitionOffsetChange struct {
+	Partition      int   `json:"partition"`
+	PreviousOffset int64 `json:"previous_offset"`
+	NewOffset      int64 `json:"new_offset"`
+}
+
+type GroupOffsetsResetResponse struct {
+	Group      string                  `json:"group"`
+	Topic      string                  `json:"topic"`
+	DryRun     bool                    `json:"dry_run"`
+	Partitions []PartitionOffsetChange `json:"partitions"`
+}
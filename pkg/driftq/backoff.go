@@ -0,0 +1,126 @@
+package driftq
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// BackoffConfig makes a Worker back off before a failed message is redelivered, instead of nacking
+// it for immediate redelivery, and stop retrying once the attempts run out.
+//
+// The delay before redelivery n+1 is BackoffMs * 2^(n-1) (with jitter), capped at MaxBackoffMs, taken from
+// the message's Envelope.RetryPolicy; Base and Max are used for fields the policy leaves at 0.
+// A handler's RetryAfter delay is used as is
+type BackoffConfig struct {
+	Base time.Duration // first delay (default 1s)
+	Max  time.Duration // cap (default 1m)
+
+	// MaxAttempts is the attempt limit for messages whose RetryPolicy has none; 0 = retry forever
+	MaxAttempts int
+
+	// Hold keeps the lease and nacks once the delay is over, for brokers that ignore NackRequest.DelayMS.
+	// The message keeps its Concurrency slot while held. A hold ends early when the worker stops,
+	// and is capped at half of ConsumeOptions.LeaseMS when that is set
+	Hold bool
+
+	// OnExhausted decides what happens to a message that failed its last attempt (default: fail it
+	// permanently). See ExhaustedHandler
+	OnExhausted ExhaustedHandler
+}
+
+// ExhaustedHandler gets a message that failed its last attempt and the handler's error.
+// It never leads to another retry: nil acks the message, Skip acks it as skipped, and any other
+// error is treated as Permanent (dead-lettered with DeadLetter set, otherwise acked and reported)
+type ExhaustedHandler func(ctx context.Context, msg ConsumeMessage, err error) error
+
+// ErrRetriesExhausted is the error OnExhausted gets wrapped around the last handler error
+var ErrRetriesExhausted = errors.New("retries exhausted")
+
+func (c BackoffConfig) withDefaults() BackoffConfig {
+	if c.Base <= 0 {
+		c.Base = time.Second
+	}
+	if c.Max <= 0 {
+		c.Max = time.Minute
+	}
+	if c.Max < c.Base {
+		c.Max = c.Base
+	}
+	if c.MaxAttempts < 0 {
+		c.MaxAttempts = 0
+	}
+	return c
+}
+
+// retryDelay is the backoff before msg's next delivery
+func (c *BackoffConfig) retryDelay(msg ConsumeMessage) time.Duration {
+	base, ceiling := c.Base, c.Max
+	if p := msgRetryPolicy(msg); p != nil {
+		if p.BackoffMs > 0 {
+			base = time.Duration(p.BackoffMs) * time.Millisecond
+		}
+		if p.MaxBackoffMs > 0 {
+			ceiling = time.Duration(p.MaxBackoffMs) * time.Millisecond
+		}
+	}
+	ceiling = max(ceiling, base)
+
+	// 2^30 * base is past any sane cap; keeps the float math from overflowing
+	attempt := min(max(msg.Attempts, 1), 31)
+	return min(backoff(base, ceiling, attempt), ceiling)
+}
+
+// exhausted reports whether msg just failed its last attempt
+func (c *BackoffConfig) exhausted(msg ConsumeMessage) bool {
+	limit := c.MaxAttempts
+	if p := msgRetryPolicy(msg); p != nil && p.MaxAttempts > 0 {
+		limit = p.MaxAttempts
+	}
+	return limit > 0 && msg.Attempts >= limit
+}
+
+func msgRetryPolicy(msg ConsumeMessage) *RetryPolicy {
+	if msg.Envelope == nil {
+		return nil
+	}
+	return msg.Envelope.RetryPolicy
+}
+
+// giveUp turns the last attempt's error into how the message is settled (nil, Skip or Permanent)
+func (w *Worker) giveUp(ctx context.Context, msg ConsumeMessage, err error) error {
+	cause := errors.Join(ErrRetriesExhausted, err)
+	if w.backoff.OnExhausted == nil {
+		return Permanent(cause)
+	}
+
+	res := w.backoff.OnExhausted(ctx, msg, cause)
+	var skip *SkipError
+	if res == nil || errors.As(res, &skip) || IsPermanent(res) {
+		return res
+	}
+	return Permanent(res)
+}
+
+// hold waits out a redelivery delay while keeping the lease (BackoffConfig.Hold).
+// It returns early when ctx is done or the worker is stopping
+func (w *Worker) hold(ctx context.Context, d time.Duration) {
+	if w.opt.LeaseMS > 0 {
+		d = min(d, time.Duration(w.opt.LeaseMS)*time.Millisecond/2)
+	}
+	if d <= 0 {
+		return
+	}
+
+	w.mu.Lock()
+	stop := w.stop
+	w.mu.Unlock()
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	case <-stop:
+	}
+}
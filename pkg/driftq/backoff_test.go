@@ -0,0 +1,150 @@
+package driftq
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWorker_BackoffFromRetryPolicy(t *testing.T) {
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(
+		`{"partition":0,"offset":1,"attempts":1,"value":"v","envelope":{"retry_policy":{"backoff_ms":100,"max_backoff_ms":1000}}}`,
+		`{"partition":0,"offset":2,"attempts":4,"value":"v","envelope":{"retry_policy":{"backoff_ms":100,"max_backoff_ms":500}}}`,
+		`{"partition":0,"offset":3,"attempts":2,"value":"v"}`,
+		`{"partition":0,"offset":4,"attempts":3,"value":"v","envelope":{"retry_policy":{"max_attempts":3}}}`,
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var exhausted []error
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		Backoff: &BackoffConfig{
+			Base: time.Second,
+			OnExhausted: func(ctx context.Context, msg ConsumeMessage, err error) error {
+				if msg.Offset != 4 {
+					t.Errorf("OnExhausted for offset %d", msg.Offset)
+				}
+				exhausted = append(exhausted, err)
+				return nil
+			},
+		},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			return errors.New("flaky")
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.nacks) != 3 || len(s.acks) != 1 || s.acks[0].Offset != 4 {
+		t.Fatalf("expected 3 nacks and the exhausted message acked: %d nacks, acks %#v", len(s.nacks), s.acks)
+	}
+
+	// 100ms for a first failure, 800ms capped at 500ms for the fourth, Base*2 without a policy (+/- 20% jitter)
+	for i, want := range [][2]int64{{80, 120}, {400, 500}, {1600, 2400}} {
+		if d := s.nacks[i].DelayMS; d < want[0] || d > want[1] {
+			t.Fatalf("nack %d: delay %dms, want %d..%dms", i, d, want[0], want[1])
+		}
+	}
+
+	if len(exhausted) != 1 || !errors.Is(exhausted[0], ErrRetriesExhausted) || !strings.Contains(exhausted[0].Error(), "flaky") {
+		t.Fatalf("unexpected OnExhausted calls %v", exhausted)
+	}
+}
+
+func TestWorker_BackoffExhaustedDeadLetters(t *testing.T) {
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(
+		`{"partition":0,"offset":1,"attempts":2,"value":"v"}`,
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	wk, err := NewWorker(WorkerConfig{
+		Client:     c,
+		Consume:    ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		DeadLetter: &DeadLetterConfig{Topic: "demo.dlq"},
+		Backoff:    &BackoffConfig{MaxAttempts: 2},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			return errors.New("boom")
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.produced) != 1 || len(s.acks) != 1 || len(s.nacks) != 0 {
+		t.Fatalf("expected the exhausted message to be dead-lettered: %d produced, %d acks, %d nacks", len(s.produced), len(s.acks), len(s.nacks))
+	}
+	rec, err := DecodeDeadLetter(ConsumeMessage{Value: s.produced[0].Value})
+	if err != nil || !strings.Contains(rec.LastError, "retries exhausted") {
+		t.Fatalf("unexpected dead letter %#v (err=%v)", rec, err)
+	}
+}
+
+func TestWorker_BackoffHoldsLease(t *testing.T) {
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(
+		`{"partition":0,"offset":1,"attempts":1,"value":"v"}`,
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var failedAt time.Time
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		Backoff: &BackoffConfig{Base: 100 * time.Millisecond, Hold: true},
+		Hooks: WorkerHooks{
+			OnNack: func(ctx context.Context, ev SettleEvent) {
+				if held := time.Since(failedAt); held < 70*time.Millisecond {
+					t.Errorf("nacked after %s, expected the delay to be held", held)
+				}
+			},
+		},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			failedAt = time.Now()
+			return errors.New("boom")
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.nacks) != 1 || s.nacks[0].DelayMS != 0 {
+		t.Fatalf("expected one nack without a server-side delay, got %#v", s.nacks)
+	}
+}
@@ -0,0 +1,182 @@
+package driftq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubTransport answers every request with the same canned response, so benchmarks
+// measure the SDK rather than the loopback network
+type stubTransport struct {
+	status int
+	ctype  string
+	body   func() []byte
+}
+
+func (s stubTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body != nil {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_ = r.Body.Close()
+	}
+	b := s.body()
+	return &http.Response{
+		StatusCode:    s.status,
+		Header:        http.Header{"Content-Type": {s.ctype}},
+		Body:          io.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       r,
+	}, nil
+}
+
+func jsonStub(v any) stubTransport {
+	b, _ := json.Marshal(v)
+	return stubTransport{status: http.StatusOK, ctype: "application/json", body: func() []byte { return b }}
+}
+
+func benchClient(b *testing.B, rt http.RoundTripper) *Client {
+	b.Helper()
+
+	c, err := Dial(context.Background(), Config{BaseURL: "http://driftq.bench", Transport: rt, Tracing: TracingConfig{Disable: true}})
+	if err != nil {
+		b.Fatalf("Dial: %v", err)
+	}
+	return c
+}
+
+func benchEnvelope() *Envelope {
+	deadline := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &Envelope{
+		RunID:          "run-0123456789",
+		StepID:         "charge",
+		TenantID:       "acme",
+		IdempotencyKey: "run-0123456789/charge",
+		Deadline:       &deadline,
+		RetryPolicy:    &RetryPolicy{MaxAttempts: 5, BackoffMs: 100, MaxBackoffMs: 5000},
+		TraceContext:   map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	}
+}
+
+func BenchmarkConsumeStream_Decode(b *testing.B) {
+	for _, tc := range []struct {
+		name string
+		env  *Envelope
+		size int
+	}{
+		{"plain-256B", nil, 256},
+		{"envelope-256B", benchEnvelope(), 256},
+		{"envelope-4KB", benchEnvelope(), 4096},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			line, _ := json.Marshal(ConsumeMessage{
+				Partition: 3, Offset: 123456, Attempts: 1, Key: "order-42",
+				Value: strings.Repeat("v", tc.size), Envelope: tc.env,
+			})
+			line = append(line, '\n')
+
+			// One stream carrying b.N messages, so ns/op is per decoded message
+			n := b.N
+			stub := stubTransport{status: http.StatusOK, ctype: "application/x-ndjson", body: func() []byte {
+				return bytes.Repeat(line, n)
+			}}
+			c := benchClient(b, stub)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			b.SetBytes(int64(len(line)))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			msgs, errs, err := c.ConsumeStream(ctx, ConsumeOptions{Topic: "t", Group: "g", Owner: "o"})
+			if err != nil {
+				b.Fatalf("ConsumeStream: %v", err)
+			}
+			got := 0
+			for range msgs {
+				got++
+			}
+			b.StopTimer()
+
+			if err := <-errs; err != nil {
+				b.Fatalf("stream error: %v", err)
+			}
+			if got != n {
+				b.Fatalf("decoded %d of %d messages", got, n)
+			}
+		})
+	}
+}
+
+func BenchmarkProduce_Encode(b *testing.B) {
+	for _, size := range []int{64, 1024, 16384} {
+		req := ProduceRequest{Topic: "orders", Key: "order-42", Value: strings.Repeat("v", size), Envelope: benchEnvelope()}
+
+		b.Run(strconv.Itoa(size)+"B", func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := json.Marshal(req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkProduce(b *testing.B) {
+	c := benchClient(b, jsonStub(ProduceResponse{Status: "produced", Topic: "orders"}))
+	ctx := context.Background()
+	req := ProduceRequest{Topic: "orders", Key: "order-42", Value: strings.Repeat("v", 256), Envelope: benchEnvelope()}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := c.Produce(ctx, req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkMiddleware compares a bare transport with each middleware and the default client stack
+func BenchmarkMiddleware(b *testing.B) {
+	base := jsonStub(HealthzResponse{Status: "ok"})
+	metrics := newClientMetrics(MetricsConfig{})
+
+	for _, tc := range []struct {
+		name string
+		mws  []RoundTripperMiddleware
+	}{
+		{"bare", nil},
+		{"deadline", []RoundTripperMiddleware{DeadlineMiddleware(10 * time.Second)}},
+		{"tracing", []RoundTripperMiddleware{TracingMiddleware(TracingConfig{})}},
+		{"retry", []RoundTripperMiddleware{RetryMiddleware(RetryConfig{})}},
+		{"metrics", []RoundTripperMiddleware{metricsMiddleware(metrics)}},
+		{"default-stack", []RoundTripperMiddleware{
+			DeadlineMiddleware(10 * time.Second),
+			TracingMiddleware(TracingConfig{}),
+			RetryMiddleware(RetryConfig{}),
+			metricsMiddleware(metrics),
+		}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			hc := &http.Client{Transport: ChainTransport(base, tc.mws...)}
+			req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://driftq.bench/v1/healthz", nil)
+
+			b.ReportAllocs()
+			for b.Loop() {
+				resp, err := hc.Do(req)
+				if err != nil {
+					b.Fatal(err)
+				}
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package driftq
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ---- Chaos / fault injection ----
+
+// FaultKind is how ChaosMiddleware breaks a request (after any Fault.Latency)
+type FaultKind int
+
+const (
+	// FaultNone only applies Fault.Latency
+	FaultNone FaultKind = iota
+	// FaultReset fails the round trip with a connection reset; the request never reaches the server
+	FaultReset
+	// FaultStatus answers Fault.Status (default 503) without reaching the server
+	FaultStatus
+	// FaultTruncate passes AfterLines complete lines, then half of the next one, then a clean EOF
+	FaultTruncate
+	// FaultDisconnect passes AfterLines complete lines, then fails the body read with a connection reset
+	FaultDisconnect
+	// FaultSlowDrip delivers the body DripBytes at a time, waiting DripInterval between reads
+	FaultSlowDrip
+)
+
+func (k FaultKind) String() string {
+	switch k {
+	case FaultNone:
+		return "none"
+	case FaultReset:
+		return "reset"
+	case FaultStatus:
+		return "status"
+	case FaultTruncate:
+		return "truncate"
+	case FaultDisconnect:
+		return "disconnect"
+	case FaultSlowDrip:
+		return "slow_drip"
+	default:
+		return "unknown"
+	}
+}
+
+type Fault struct {
+	Kind    FaultKind
+	Latency time.Duration // applied before anything else, for every kind
+
+	Status     int           // FaultStatus (default 503)
+	RetryAfter time.Duration // FaultStatus: Retry-After in whole seconds, rounded up (always sent for 429/503)
+
+	AfterLines int // FaultTruncate/FaultDisconnect: complete NDJSON lines let through first
+
+	DripBytes    int           // FaultSlowDrip (default 1)
+	DripInterval time.Duration // FaultSlowDrip (default 10ms)
+}
+
+// ChaosRule picks requests to break. Method and Path filter (Path may end in "*" for a prefix; empty = any).
+// The k-th matching request gets Script[k]; once the script is used up, Fault is applied with Probability
+type ChaosRule struct {
+	Method string
+	Path   string
+
+	Script []Fault
+
+	Probability float64 // 0..1
+	Fault       Fault
+}
+
+func (r *ChaosRule) matches(req *http.Request) bool {
+	if r.Method != "" && r.Method != req.Method {
+		return false
+	}
+	if r.Path == "" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(r.Path, "*"); ok {
+		return strings.HasPrefix(req.URL.Path, prefix)
+	}
+	return r.Path == req.URL.Path
+}
+
+type ChaosConfig struct {
+	// Seed makes Probability rolls reproducible for a given request order (0 = time-based)
+	Seed int64
+
+	// Rules are checked in order; the first match decides
+	Rules []ChaosRule
+
+	// OnFault is called for every fault injected (optional)
+	OnFault func(req *http.Request, f Fault)
+}
+
+// ChaosMiddleware injects latency, resets, error statuses and broken bodies per cfg.Rules.
+// Put it innermost (or use FaultTransport as Config.Transport) so retries and tracing see the faults
+func ChaosMiddleware(cfg ChaosConfig) RoundTripperMiddleware {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	rules := make([]ChaosRule, len(cfg.Rules))
+	copy(rules, cfg.Rules)
+
+	var (
+		mu   sync.Mutex
+		rng  = rand.New(rand.NewSource(seed))
+		seen = make([]int, len(rules))
+	)
+
+	pick := func(req *http.Request) (Fault, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for i := range rules {
+			r := &rules[i]
+			if !r.matches(req) {
+				continue
+			}
+
+			n := seen[i]
+			seen[i]++
+			if n < len(r.Script) {
+				return r.Script[n], true
+			}
+			if r.Probability > 0 && rng.Float64() < r.Probability {
+				return r.Fault, true
+			}
+			return Fault{}, false
+		}
+		return Fault{}, false
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			f, ok := pick(req)
+			if !ok {
+				return next.RoundTrip(req)
+			}
+
+			if cfg.OnFault != nil {
+				cfg.OnFault(req, f)
+			}
+
+			if err := sleepCtx(req.Context(), f.Latency); err != nil {
+				return nil, err
+			}
+
+			switch f.Kind {
+			case FaultReset:
+				if req.Body != nil {
+					req.Body.Close()
+				}
+				return nil, chaosResetErr("dial")
+
+			case FaultStatus:
+				if req.Body != nil {
+					req.Body.Close()
+				}
+				return chaosStatusResponse(req, f), nil
+			}
+
+			resp, err := next.RoundTrip(req)
+			if err != nil || resp == nil {
+				return resp, err
+			}
+
+			switch f.Kind {
+			case FaultTruncate, FaultDisconnect:
+				resp.Body = &lineCutBody{rc: resp.Body, br: bufio.NewReader(resp.Body), after: f.AfterLines, kind: f.Kind}
+				resp.ContentLength = -1
+
+			case FaultSlowDrip:
+				resp.Body = &dripBody{ReadCloser: resp.Body, ctx: req.Context(), n: f.DripBytes, every: f.DripInterval}
+			}
+			return resp, nil
+		})
+	}
+}
+
+// FaultTransport wraps base (nil = http.DefaultTransport) with ChaosMiddleware
+func FaultTransport(base http.RoundTripper, cfg ChaosConfig) http.RoundTripper {
+	return ChainTransport(base, ChaosMiddleware(cfg))
+}
+
+func chaosResetErr(op string) error {
+	return &net.OpError{Op: op, Net: "tcp", Err: syscall.ECONNRESET}
+}
+
+func chaosStatusResponse(req *http.Request, f Fault) *http.Response {
+	status := f.Status
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+
+	h := make(http.Header)
+	h.Set("Content-Type", "application/json")
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable || f.RetryAfter > 0 {
+		secs := int((f.RetryAfter + time.Second - 1) / time.Second)
+		h.Set("Retry-After", strconv.Itoa(secs))
+	}
+
+	body := `{"error":"CHAOS","message":"injected ` + strconv.Itoa(status) + `"}`
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// lineCutBody lets `after` complete lines through, then truncates or resets
+type lineCutBody struct {
+	rc    io.ReadCloser
+	br    *bufio.Reader
+	after int
+	kind  FaultKind
+
+	buf  bytes.Buffer
+	done bool
+	err  error
+}
+
+func (b *lineCutBody) Read(p []byte) (int, error) {
+	for b.buf.Len() == 0 {
+		if b.done {
+			return 0, b.err
+		}
+		b.fill()
+	}
+	return b.buf.Read(p)
+}
+
+func (b *lineCutBody) fill() {
+	if b.after > 0 {
+		line, err := b.br.ReadBytes('\n')
+		b.buf.Write(line)
+		if err != nil {
+			b.done, b.err = true, err
+			return
+		}
+		b.after--
+		return
+	}
+
+	b.done = true
+	if b.kind == FaultDisconnect {
+		b.err = chaosResetErr("read")
+		return
+	}
+
+	// Half of the next line (at least one byte, never its newline), then a clean EOF
+	line, _ := b.br.ReadBytes('\n')
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	if len(line) > 0 {
+		b.buf.Write(line[:max(1, len(line)/2)])
+	}
+	b.err = io.EOF
+}
+
+func (b *lineCutBody) Close() error { return b.rc.Close() }
+
+// dripBody hands out at most n bytes per Read, sleeping `every` before each one
+type dripBody struct {
+	io.ReadCloser
+	ctx   context.Context
+	n     int
+	every time.Duration
+}
+
+func (b *dripBody) Read(p []byte) (int, error) {
+	n, every := b.n, b.every
+	if n <= 0 {
+		n = 1
+	}
+	if every <= 0 {
+		every = 10 * time.Millisecond
+	}
+
+	if err := sleepCtx(b.ctx, every); err != nil {
+		return 0, err
+	}
+	if len(p) > n {
+		p = p[:n]
+	}
+	return b.ReadCloser.Read(p)
+}
@@ -0,0 +1,278 @@
+package driftq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestChaos_RetryMiddlewareRidesOverScriptedFaults(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_ = json.NewEncoder(w).Encode(HealthzResponse{Status: "ok"})
+	}))
+	defer srv.Close()
+
+	var injected []FaultKind
+	c, err := Dial(context.Background(), Config{
+		BaseURL: srv.URL,
+		Retry:   RetryConfig{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		Transport: FaultTransport(nil, ChaosConfig{
+			Rules: []ChaosRule{{
+				Path: "/v1/healthz",
+				Script: []Fault{
+					{Kind: FaultStatus, Status: http.StatusTooManyRequests},
+					{Kind: FaultReset},
+					{Kind: FaultStatus, Status: http.StatusBadGateway},
+				},
+			}},
+			OnFault: func(_ *http.Request, f Fault) { injected = append(injected, f.Kind) },
+		}),
+	})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	h, err := c.Healthz(context.Background())
+	if err != nil || h.Status != "ok" {
+		t.Fatalf("Healthz: %#v err=%v", h, err)
+	}
+	if hits.Load() != 1 || len(injected) != 3 {
+		t.Fatalf("expected 3 injected faults then 1 real hit, got faults=%v hits=%d", injected, hits.Load())
+	}
+
+	// Script used up and no Probability: requests pass through untouched
+	if _, err := c.Healthz(context.Background()); err != nil || hits.Load() != 2 {
+		t.Fatalf("expected pass-through, err=%v hits=%d", err, hits.Load())
+	}
+}
+
+func TestChaos_ExhaustedRetriesSurfaceTheFault(t *testing.T) {
+	c, err := Dial(context.Background(), Config{
+		BaseURL: "http://driftq.invalid",
+		Retry:   RetryConfig{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		Transport: FaultTransport(nil, ChaosConfig{
+			Rules: []ChaosRule{{Probability: 1, Fault: Fault{Kind: FaultReset}}},
+		}),
+	})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	if _, err := c.Healthz(context.Background()); !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("expected connection reset, got %v", err)
+	}
+}
+
+func TestChaos_SeedIsReproducible(t *testing.T) {
+	run := func(seed int64) []bool {
+		var got []bool
+		rt := FaultTransport(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			got = append(got, false)
+			return chaosStatusResponse(r, Fault{Status: http.StatusOK}), nil
+		}), ChaosConfig{
+			Seed:    seed,
+			Rules:   []ChaosRule{{Probability: 0.5, Fault: Fault{Kind: FaultReset}}},
+			OnFault: func(*http.Request, Fault) { got = append(got, true) },
+		})
+
+		for range 32 {
+			req, _ := http.NewRequest(http.MethodGet, "http://x/v1/healthz", nil)
+			if resp, err := rt.RoundTrip(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+		return got
+	}
+
+	a, b := run(42), run(42)
+	faults := 0
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("same seed diverged at request %d", i)
+		}
+		if a[i] {
+			faults++
+		}
+	}
+	if faults == 0 || faults == len(a) {
+		t.Fatalf("expected a mix of faults and pass-throughs, got %d/%d", faults, len(a))
+	}
+}
+
+func TestChaos_SlowDripDeliversWholeBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(HealthzResponse{Status: "ok"})
+	}))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{
+		BaseURL: srv.URL,
+		Transport: FaultTransport(nil, ChaosConfig{
+			Rules: []ChaosRule{{Probability: 1, Fault: Fault{Kind: FaultSlowDrip, DripBytes: 4, DripInterval: time.Millisecond}}},
+		}),
+	})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	start := time.Now()
+	h, err := c.Healthz(context.Background())
+	if err != nil || h.Status != "ok" {
+		t.Fatalf("Healthz: %#v err=%v", h, err)
+	}
+	if time.Since(start) < 4*time.Millisecond {
+		t.Fatalf("body was not dripped")
+	}
+}
+
+// chaosWorkerServer serves two messages per consume stream and records acks
+func chaosWorkerServer(t *testing.T) (*httptest.Server, func() []int64) {
+	t.Helper()
+
+	var (
+		mu    sync.Mutex
+		acked []int64
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/consume":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			_, _ = w.Write([]byte(`{"partition":0,"offset":1,"attempts":1,"value":"a"}` + "\n"))
+			_, _ = w.Write([]byte(`{"partition":0,"offset":2,"attempts":1,"value":"b"}` + "\n"))
+
+		case "/v1/ack":
+			var req AckRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			mu.Lock()
+			acked = append(acked, req.Offset)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() []int64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int64(nil), acked...)
+	}
+}
+
+func TestChaos_WorkerStreamFaults(t *testing.T) {
+	for _, kind := range []FaultKind{FaultTruncate, FaultDisconnect} {
+		t.Run(kind.String(), func(t *testing.T) {
+			srv, acked := chaosWorkerServer(t)
+
+			c, err := Dial(context.Background(), Config{
+				BaseURL: srv.URL,
+				Transport: FaultTransport(nil, ChaosConfig{
+					Rules: []ChaosRule{{Path: "/v1/consume", Script: []Fault{{Kind: kind, AfterLines: 1}}}},
+				}),
+			})
+			if err != nil {
+				t.Fatalf("Dial: %v", err)
+			}
+
+			var (
+				mu       sync.Mutex
+				reported []error
+			)
+			wk, err := NewWorker(WorkerConfig{
+				Client:  c,
+				Consume: ConsumeOptions{Topic: "demo", Group: "g", Owner: "w1"},
+				Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error { return nil }),
+				OnError: func(err error) {
+					mu.Lock()
+					reported = append(reported, err)
+					mu.Unlock()
+				},
+			})
+			if err != nil {
+				t.Fatalf("NewWorker: %v", err)
+			}
+
+			// Run again whenever the stream breaks, as a service's main loop would
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for ctx.Err() == nil {
+					_ = wk.Run(ctx) // stream errors go to OnError
+				}
+			}()
+
+			deadline := time.Now().Add(5 * time.Second)
+			for len(acked()) < 3 && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			cancel()
+			<-done
+
+			// Broken stream: offset 1 only; after the reconnect: 1 and 2 (and more, it keeps reconnecting)
+			got := acked()
+			if len(got) < 3 || got[0] != 1 || got[1] != 1 || got[2] != 2 {
+				t.Fatalf("unexpected acks %v", got)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(reported) == 0 {
+				t.Fatalf("expected the stream error to be reported")
+			}
+			if kind == FaultDisconnect && !errors.Is(reported[0], syscall.ECONNRESET) {
+				t.Fatalf("expected connection reset, got %v", reported[0])
+			}
+		})
+	}
+}
+
+func TestChaos_WorkerReportsAckFailure(t *testing.T) {
+	srv, acked := chaosWorkerServer(t)
+
+	c, err := Dial(context.Background(), Config{
+		BaseURL: srv.URL,
+		Transport: FaultTransport(nil, ChaosConfig{
+			Rules: []ChaosRule{{Path: "/v1/ack", Script: []Fault{{Kind: FaultStatus, Status: http.StatusServiceUnavailable}}}},
+		}),
+	})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var reported []error
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "demo", Group: "g", Owner: "w1"},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error { return nil }),
+		OnError: func(err error) { reported = append(reported, err) },
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+
+	// Stream closes after two messages, so Run returns on its own
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// Ack is a POST without an idempotency key: not retried, the failure is reported
+	var ae *APIError
+	if len(reported) != 1 || !errors.As(reported[0], &ae) || ae.Status != http.StatusServiceUnavailable {
+		t.Fatalf("expected one reported 503, got %v", reported)
+	}
+	if got := acked(); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("expected only offset 2 to reach the server, got %v", got)
+	}
+}
@@ -3,6 +3,7 @@ package driftq
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
@@ -18,6 +19,8 @@ type Client struct {
 	cfg     Config
 	baseURL string
 	httpc   *http.Client
+	metrics *clientMetrics
+	log     *slog.Logger
 }
 
 type Config struct {
@@ -26,8 +29,18 @@ type Config struct {
 	Timeout   time.Duration
 	Retry     RetryConfig
 	Tracing   TracingConfig
+	Metrics   MetricsConfig
 	UserAgent string
 	Transport http.RoundTripper
+
+	// Logger receives structured SDK events (retries, stream lifecycle, ack/nack failures, shutdown).
+	// nil = silent. Message values are redacted unless LogPayloads is set
+	Logger      *slog.Logger
+	LogPayloads bool
+
+	// RejectExpired makes Produce fail with a *DeadlineExpiredError, without sending,
+	// when Envelope.Deadline has already passed
+	RejectExpired bool
 }
 
 func Dial(ctx context.Context, cfg Config) (*Client, error) {
@@ -51,14 +64,25 @@ func Dial(ctx context.Context, cfg Config) (*Client, error) {
 		cfg.UserAgent = "driftq-go/" + Version
 	}
 
+	metrics := newClientMetrics(cfg.Metrics)
+	logger := newLogger(cfg.Logger)
+
+	retry := cfg.Retry
+	if retry.Logger == nil {
+		retry.Logger = logger
+	} else {
+		retry.Logger = newLogger(retry.Logger)
+	}
+
 	// Middleware stack (outer -> inner):
-	// Deadline -> Tracing -> Retry -> base transport
+	// Deadline -> Tracing -> Retry -> Metrics -> base transport
 	baseTransport := cfg.Transport
 	transport := ChainTransport(
 		baseTransport,
 		DeadlineMiddleware(cfg.Timeout),
 		TracingMiddleware(cfg.Tracing),
-		RetryMiddleware(cfg.Retry),
+		RetryMiddleware(retry),
+		metricsMiddleware(metrics),
 	)
 
 	httpc := &http.Client{Transport: transport}
@@ -67,6 +91,8 @@ func Dial(ctx context.Context, cfg Config) (*Client, error) {
 		cfg:     cfg,
 		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
 		httpc:   httpc,
+		metrics: metrics,
+		log:     logger,
 	}, nil
 }
 
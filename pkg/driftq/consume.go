@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // ConsumeStream opens /v1/consume and decodes NDJSON items until ctx is cancelled
@@ -58,8 +61,16 @@ func (c *Client) ConsumeStream(ctx context.Context, opt ConsumeOptions) (<-chan
 		req.Header.Set("User-Agent", ua)
 	}
 
+	streamAttrs := []slog.Attr{
+		slog.String("topic", topic),
+		slog.String("group", group),
+		slog.String("owner", owner),
+	}
+
 	resp, err := c.httpc.Do(req)
 	if err != nil {
+		c.log.LogAttrs(ctx, slog.LevelWarn, "driftq consume stream open failed",
+			append(streamAttrs, slog.String("error", err.Error()))...)
 		return nil, nil, err
 	}
 
@@ -67,13 +78,18 @@ func (c *Client) ConsumeStream(ctx context.Context, opt ConsumeOptions) (<-chan
 		defer resp.Body.Close()
 		var er ErrorResponse
 		_ = json.NewDecoder(resp.Body).Decode(&er) // best-effort
-		return nil, nil, &APIError{
+		apiErr := &APIError{
 			Status:  resp.StatusCode,
 			Code:    er.Error,
 			Message: er.Message,
 		}
+		c.log.LogAttrs(ctx, slog.LevelWarn, "driftq consume stream open failed",
+			append(streamAttrs, slog.String("error", apiErr.Error()))...)
+		return nil, nil, apiErr
 	}
 
+	c.log.LogAttrs(ctx, slog.LevelDebug, "driftq consume stream opened", streamAttrs...)
+
 	msgs := make(chan ConsumeMessage)
 	errs := make(chan error, 1)
 
@@ -83,13 +99,28 @@ func (c *Client) ConsumeStream(ctx context.Context, opt ConsumeOptions) (<-chan
 		defer resp.Body.Close()
 
 		dec := json.NewDecoder(resp.Body)
+		mopt := messagingAttrs(topic, attribute.String(attrMessagingConsumerGroup, group))
+
+		received := 0
+		closed := func(reason string) {
+			c.log.LogAttrs(ctx, slog.LevelDebug, "driftq consume stream closed",
+				append(streamAttrs, slog.String("reason", reason), slog.Int("received", received))...)
+		}
 
 		for {
 			var m ConsumeMessage
 			if err := dec.Decode(&m); err != nil {
-				if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				if errors.Is(err, io.EOF) {
+					closed("eof")
+					return
+				}
+				if ctx.Err() != nil {
+					closed("canceled")
 					return
 				}
+
+				c.log.LogAttrs(ctx, slog.LevelError, "driftq consume stream decode error",
+					append(streamAttrs, slog.String("error", err.Error()), slog.Int("received", received))...)
 				select {
 				case errs <- err:
 				default:
@@ -97,9 +128,13 @@ func (c *Client) ConsumeStream(ctx context.Context, opt ConsumeOptions) (<-chan
 				return
 			}
 
+			received++
+			c.metrics.consumedMessages.Add(ctx, 1, mopt)
+
 			select {
 			case msgs <- m:
 			case <-ctx.Done():
+				closed("canceled")
 				return
 			}
 		}
@@ -0,0 +1,283 @@
+package driftq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DeadLetterConfig turns on dead-lettering for a Worker.
+//
+// A failed message is dead-lettered when its handler error is Permanent (see IsPermanent) or when
+// ConsumeMessage.Attempts reaches the limit: Envelope.RetryPolicy.MaxAttempts if set, otherwise MaxAttempts.
+// The worker produces a DeadLetterRecord to Topic and then acks the original
+type DeadLetterConfig struct {
+	Topic       string
+	MaxAttempts int    // fallback attempt limit for messages without a RetryPolicy; 0 = only terminal errors
+	HandlerName string // recorded on every DeadLetterRecord (handy when several workers share a DLQ)
+}
+
+// DeadLetterRecord is the value produced to the DLQ topic: the original message plus failure metadata
+type DeadLetterRecord struct {
+	Topic     string    `json:"topic"`
+	Group     string    `json:"group"`
+	Partition int       `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Key       string    `json:"key,omitempty"`
+	Value     string    `json:"value"`
+	Routing   *Routing  `json:"routing,omitempty"`
+	Envelope  *Envelope `json:"envelope,omitempty"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	Handler   string    `json:"handler,omitempty"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// DecodeDeadLetter parses a DLQ message value back into a DeadLetterRecord
+func DecodeDeadLetter(msg ConsumeMessage) (DeadLetterRecord, error) {
+	var rec DeadLetterRecord
+	if err := json.Unmarshal([]byte(msg.Value), &rec); err != nil {
+		return DeadLetterRecord{}, fmt.Errorf("decode dead letter: %w", err)
+	}
+	if rec.Topic == "" {
+		return DeadLetterRecord{}, errors.New("decode dead letter: missing original topic")
+	}
+	return rec, nil
+}
+
+func (w *Worker) shouldDeadLetter(msg ConsumeMessage, err error) bool {
+	if w.dlq == nil {
+		return false
+	}
+
+	if IsPermanent(err) {
+		return true
+	}
+
+	limit := w.dlq.MaxAttempts
+	if msg.Envelope != nil && msg.Envelope.RetryPolicy != nil && msg.Envelope.RetryPolicy.MaxAttempts > 0 {
+		limit = msg.Envelope.RetryPolicy.MaxAttempts
+	}
+	return limit > 0 && msg.Attempts >= limit
+}
+
+func (w *Worker) deadLetter(ctx context.Context, msg ConsumeMessage, reason string) error {
+	return w.park(ctx, w.dlq.Topic, "dlq", w.dlq.HandlerName, msg, reason)
+}
+
+// park produces msg as a DeadLetterRecord to topic; prefix namespaces the idempotency key
+func (w *Worker) park(ctx context.Context, topic, prefix, handler string, msg ConsumeMessage, reason string) error {
+	rec := DeadLetterRecord{
+		Topic:     w.opt.Topic,
+		Group:     w.opt.Group,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Routing:   msg.Routing,
+		Envelope:  msg.Envelope,
+		Attempts:  msg.Attempts,
+		LastError: reason,
+		Handler:   handler,
+		FailedAt:  time.Now().UTC(),
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("dead letter: %w", err)
+	}
+
+	env := &Envelope{
+		// Same source position => same key, so a retried (or redelivered) dead-letter produce is deduplicated
+		IdempotencyKey: fmt.Sprintf("%s:%s:%s:%d:%d", prefix, w.opt.Group, w.opt.Topic, msg.Partition, msg.Offset),
+	}
+	if msg.Envelope != nil {
+		env.RunID = msg.Envelope.RunID
+		env.StepID = msg.Envelope.StepID
+		env.TenantID = msg.Envelope.TenantID
+	}
+
+	if _, err := w.c.Produce(ctx, ProduceRequest{
+		Topic:    topic,
+		Key:      msg.Key,
+		Value:    string(b),
+		Envelope: env,
+	}); err != nil {
+		return fmt.Errorf("dead letter to %q: %w", topic, err)
+	}
+	return nil
+}
+
+// ---- Redrive ----
+
+type RedriveOptions struct {
+	// DLQ is where dead letters are read from (Topic, Group, Owner, LeaseMS)
+	DLQ ConsumeOptions
+
+	// Filter selects which records to replay; nil replays everything.
+	// Records that are filtered out are left in the DLQ (never acked)
+	Filter func(rec DeadLetterRecord) bool
+
+	// Topic overrides the destination; empty replays to each record's original topic
+	Topic string
+
+	RatePerSec  float64       // max replays per second; 0 = unlimited
+	Limit       int           // stop after this many replays; 0 = no limit
+	IdleTimeout time.Duration // stop once no new DLQ message arrived for this long (default 2s)
+}
+
+type RedriveResult struct {
+	Redriven int
+	Skipped  int // filtered out, left in the DLQ
+	Failed   int // undecodable records or failed produces (nacked back into the DLQ)
+}
+
+// Redrive replays dead letters back to their original topic (see DeadLetterConfig).
+// Each replayed record is produced with its original key, value and envelope, then acked in the DLQ.
+//
+// Redrive returns when ctx is done, Limit is reached or the DLQ has been idle for IdleTimeout
+func (c *Client) Redrive(ctx context.Context, opt RedriveOptions) (RedriveResult, error) {
+	var res RedriveResult
+
+	if opt.RatePerSec < 0 || opt.Limit < 0 || opt.IdleTimeout < 0 {
+		return res, errors.New("redrive: RatePerSec, Limit and IdleTimeout must be >= 0")
+	}
+
+	idle := opt.IdleTimeout
+	if idle == 0 {
+		idle = 2 * time.Second
+	}
+
+	var interval time.Duration
+	if opt.RatePerSec > 0 {
+		interval = time.Duration(float64(time.Second) / opt.RatePerSec)
+	}
+
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	msgs, errs, err := c.ConsumeStream(sctx, opt.DLQ)
+	if err != nil {
+		return res, err
+	}
+
+	dlq := strings.TrimSpace(opt.DLQ.Topic)
+	ackReq := func(m ConsumeMessage) AckRequest {
+		return AckRequest{Topic: dlq, Group: opt.DLQ.Group, Owner: opt.DLQ.Owner, Partition: m.Partition, Offset: m.Offset}
+	}
+
+	type pos struct {
+		p int
+		o int64
+	}
+	seen := make(map[pos]bool)
+
+	idleT := time.NewTimer(idle)
+	defer idleT.Stop()
+
+	var last time.Time
+
+	for {
+		if opt.Limit > 0 && res.Redriven >= opt.Limit {
+			return res, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return res, ctx.Err()
+
+		case <-idleT.C:
+			return res, nil
+
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if err != nil {
+				return res, err
+			}
+
+		case m, ok := <-msgs:
+			if !ok {
+				return res, nil
+			}
+
+			// Skipped records come back once their lease expires; they don't count as progress
+			if seen[pos{m.Partition, m.Offset}] {
+				continue
+			}
+			seen[pos{m.Partition, m.Offset}] = true
+
+			if !idleT.Stop() {
+				select {
+				case <-idleT.C:
+				default:
+				}
+			}
+			idleT.Reset(idle)
+
+			rec, err := DecodeDeadLetter(m)
+			if err != nil {
+				res.Failed++
+				_ = c.Nack(ctx, NackRequest{
+					Topic: dlq, Group: opt.DLQ.Group, Owner: opt.DLQ.Owner,
+					Partition: m.Partition, Offset: m.Offset, Reason: err.Error(),
+				})
+				continue
+			}
+
+			if opt.Filter != nil && !opt.Filter(rec) {
+				res.Skipped++
+				continue
+			}
+
+			if interval > 0 && !last.IsZero() {
+				if err := sleepCtx(ctx, interval-time.Since(last)); err != nil {
+					return res, err
+				}
+			}
+			last = time.Now()
+
+			if err := c.redriveOne(ctx, dlq, m, rec, opt.Topic); err != nil {
+				res.Failed++
+				_ = c.Nack(ctx, NackRequest{
+					Topic: dlq, Group: opt.DLQ.Group, Owner: opt.DLQ.Owner,
+					Partition: m.Partition, Offset: m.Offset, Reason: err.Error(),
+				})
+				continue
+			}
+
+			if err := c.Ack(ctx, ackReq(m)); err != nil {
+				return res, fmt.Errorf("redrive: ack dlq message: %w", err)
+			}
+			res.Redriven++
+		}
+	}
+}
+
+func (c *Client) redriveOne(ctx context.Context, dlq string, m ConsumeMessage, rec DeadLetterRecord, topic string) error {
+	if topic == "" {
+		topic = rec.Topic
+	}
+
+	env := &Envelope{}
+	if rec.Envelope != nil {
+		cp := *rec.Envelope
+		env = &cp
+	}
+
+	// A fresh key per DLQ position: the original key would be deduplicated against the first produce
+	env.IdempotencyKey = fmt.Sprintf("redrive:%s:%d:%d", dlq, m.Partition, m.Offset)
+
+	_, err := c.Produce(ctx, ProduceRequest{
+		Topic:    topic,
+		Key:      rec.Key,
+		Value:    rec.Value,
+		Envelope: env,
+	})
+	return err
+}
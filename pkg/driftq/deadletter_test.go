@@ -0,0 +1,212 @@
+package driftq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// dlqServer serves a fixed set of consume lines and records produce/ack/nack calls.
+// With hold set the consume stream stays open after the lines until the client goes away
+type dlqServer struct {
+	hold bool
+
+	mu       sync.Mutex
+	produced []ProduceRequest
+	prodKeys []string
+	acks     []AckRequest
+	nacks    []NackRequest
+}
+
+func (s *dlqServer) handler(lines ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.hold && r.URL.Path == "/v1/consume" {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(strings.Join(lines, "\n") + "\n"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch r.URL.Path {
+		case "/v1/consume":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(strings.Join(lines, "\n") + "\n"))
+
+		case "/v1/produce":
+			var req ProduceRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			s.produced = append(s.produced, req)
+			s.prodKeys = append(s.prodKeys, r.Header.Get("Idempotency-Key"))
+			_ = json.NewEncoder(w).Encode(ProduceResponse{Status: "ok", Topic: req.Topic})
+
+		case "/v1/ack":
+			var req AckRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			s.acks = append(s.acks, req)
+			w.WriteHeader(http.StatusNoContent)
+
+		case "/v1/nack":
+			var req NackRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			s.nacks = append(s.nacks, req)
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func TestWorker_DeadLettersAtMaxAttempts(t *testing.T) {
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(
+		`{"partition":1,"offset":7,"attempts":3,"key":"k","value":"v","envelope":{"run_id":"r1","retry_policy":{"max_attempts":3}}}`,
+		`{"partition":1,"offset":8,"attempts":1,"key":"k","value":"v","envelope":{"retry_policy":{"max_attempts":3}}}`,
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	wk, err := NewWorker(WorkerConfig{
+		Client:     c,
+		Consume:    ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		DeadLetter: &DeadLetterConfig{Topic: "demo.dlq", HandlerName: "demo-handler"},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			return errors.New("boom")
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.produced) != 1 || len(s.acks) != 1 || len(s.nacks) != 1 {
+		t.Fatalf("expected 1 produce/1 ack/1 nack, got %d/%d/%d", len(s.produced), len(s.acks), len(s.nacks))
+	}
+
+	if s.acks[0].Offset != 7 || s.nacks[0].Offset != 8 {
+		t.Fatalf("wrong messages acked/nacked: ack=%#v nack=%#v", s.acks[0], s.nacks[0])
+	}
+
+	p := s.produced[0]
+	if p.Topic != "demo.dlq" || p.Key != "k" || s.prodKeys[0] != "dlq:g:demo:1:7" {
+		t.Fatalf("unexpected dlq produce: %#v key=%q", p, s.prodKeys[0])
+	}
+
+	rec, err := DecodeDeadLetter(ConsumeMessage{Value: p.Value})
+	if err != nil {
+		t.Fatalf("DecodeDeadLetter: %v", err)
+	}
+
+	if rec.Topic != "demo" || rec.Partition != 1 || rec.Offset != 7 || rec.Attempts != 3 ||
+		rec.LastError != "boom" || rec.Handler != "demo-handler" || rec.Value != "v" || rec.Envelope.RunID != "r1" {
+		t.Fatalf("unexpected dead letter record: %#v", rec)
+	}
+}
+
+func TestWorker_DeadLettersTerminalErrors(t *testing.T) {
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(
+		`{"partition":0,"offset":1,"attempts":1,"key":"k","value":"v","routing":{"label":"unknown"}}`,
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	mux := NewMux().
+		Label("known", StepFunc(func(ctx context.Context, msg ConsumeMessage) error { return nil })).
+		Unmatched(UnmatchedDeadLetter)
+
+	wk, err := NewWorker(WorkerConfig{
+		Client:     c,
+		Consume:    ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		DeadLetter: &DeadLetterConfig{Topic: "demo.dlq"},
+		Handler:    mux,
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.produced) != 1 || len(s.acks) != 1 || len(s.nacks) != 0 {
+		t.Fatalf("expected 1 produce/1 ack/0 nack, got %d/%d/%d", len(s.produced), len(s.acks), len(s.nacks))
+	}
+}
+
+func TestRedrive_ReplaysFilteredRecords(t *testing.T) {
+	rec := func(offset int64, topic, errMsg string) string {
+		r := DeadLetterRecord{Topic: topic, Partition: 0, Offset: offset, Key: "k", Value: "payload", LastError: errMsg,
+			Envelope: &Envelope{TenantID: "t1", IdempotencyKey: "orig"}}
+		b, _ := json.Marshal(r)
+		m, _ := json.Marshal(ConsumeMessage{Partition: 0, Offset: offset, Attempts: 1, Value: string(b)})
+		return string(m)
+	}
+
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(
+		rec(1, "orders", "timeout"),
+		rec(2, "orders", "validation"),
+		`{"partition":0,"offset":3,"attempts":1,"value":"not json"}`,
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	res, err := c.Redrive(context.Background(), RedriveOptions{
+		DLQ:         ConsumeOptions{Topic: "orders.dlq", Group: "redrive", Owner: "ops"},
+		Filter:      func(r DeadLetterRecord) bool { return r.LastError == "timeout" },
+		IdleTimeout: 200 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Redrive: %v", err)
+	}
+
+	if res.Redriven != 1 || res.Skipped != 1 || res.Failed != 1 {
+		t.Fatalf("unexpected result: %#v", res)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.produced[0]
+	if p.Topic != "orders" || p.Value != "payload" || p.Envelope.TenantID != "t1" || s.prodKeys[0] != "redrive:orders.dlq:0:1" {
+		t.Fatalf("unexpected redrive produce: %#v key=%q", p, s.prodKeys[0])
+	}
+
+	if len(s.acks) != 1 || s.acks[0].Topic != "orders.dlq" || s.acks[0].Offset != 1 {
+		t.Fatalf("unexpected dlq acks: %#v", s.acks)
+	}
+}
@@ -0,0 +1,83 @@
+package driftq
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ---- Envelope deadline enforcement ----
+//
+// Produce: with Config.RejectExpired, a message whose Envelope.Deadline already passed fails
+// client-side with a *DeadlineExpiredError instead of being sent.
+// Consume: with WorkerConfig.Expired, such a message is settled by policy without calling the handler.
+// Emit: a child step never gets more time than its parent had left (see StepContext.NextEnvelope).
+
+// DeadlineExpiredError is returned by Produce (with Config.RejectExpired) for an already expired message.
+// errors.Is(err, ErrDeadlineExpired) matches it
+type DeadlineExpiredError struct {
+	Topic    string
+	Deadline time.Time
+	Late     time.Duration // how long ago the deadline passed
+}
+
+func (e *DeadlineExpiredError) Error() string {
+	return fmt.Sprintf("produce to %q: %s (deadline %s, %s ago)", e.Topic, ErrDeadlineExpired, e.Deadline.Format(time.RFC3339Nano), e.Late)
+}
+
+func (e *DeadlineExpiredError) Unwrap() error { return ErrDeadlineExpired }
+
+// ExpiredConfig makes a Worker settle messages whose Envelope.Deadline already passed
+// without running the handler (or its middleware)
+type ExpiredConfig struct {
+	Policy DeadlineExpiredPolicy
+
+	// Grace is how long past its deadline a message still gets handled (clock skew between hosts).
+	// The handler ctx deadline is pushed back by the same amount
+	Grace time.Duration
+}
+
+const (
+	attrDeadlinePolicy = "driftq.deadline.policy"
+	policyReject       = "reject"
+)
+
+// checkProduceDeadline is Produce's RejectExpired check
+func (c *Client) checkProduceDeadline(ctx context.Context, req ProduceRequest) error {
+	if !c.cfg.RejectExpired || req.Envelope == nil || req.Envelope.Deadline == nil {
+		return nil
+	}
+
+	late := time.Since(*req.Envelope.Deadline)
+	if late < 0 {
+		return nil
+	}
+
+	c.metrics.expired.Add(ctx, 1, messagingAttrs(req.Topic, attribute.String(attrDeadlinePolicy, policyReject)))
+	return &DeadlineExpiredError{Topic: req.Topic, Deadline: *req.Envelope.Deadline, Late: late}
+}
+
+// expired reports whether msg is past its deadline (plus Grace) under WorkerConfig.Expired
+func (w *Worker) expired(msg ConsumeMessage) (time.Time, bool) {
+	if w.expiry == nil {
+		return time.Time{}, false
+	}
+	dl := envelopeDeadline(msg)
+	if dl.IsZero() {
+		return time.Time{}, false
+	}
+	return dl, time.Now().After(dl.Add(w.expiry.Grace))
+}
+
+// onExpired records an expired message and returns the error that settles it under the policy
+func (w *Worker) onExpired(ctx context.Context, ev MessageEvent, deadline time.Time) error {
+	policy := w.expiry.Policy
+	w.metrics.expired.Add(ctx, 1, w.metricAttrs(attribute.String(attrDeadlinePolicy, policy.String())))
+	w.log.LogAttrs(ctx, slog.LevelDebug, "driftq message expired before handling",
+		append(w.c.messageLogAttrs(ev.Message), slog.Time("deadline", deadline), slog.String("policy", policy.String()))...)
+	w.hookExpired(ctx, ExpiredEvent{MessageEvent: ev, Deadline: deadline, Policy: policy})
+	return policy.err()
+}
@@ -0,0 +1,139 @@
+package driftq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProduce_RejectExpired(t *testing.T) {
+	var s dlqServer
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL, RejectExpired: true})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	_, err = c.Produce(context.Background(), ProduceRequest{Topic: "demo", Value: "v", Envelope: &Envelope{Deadline: &past}})
+	var de *DeadlineExpiredError
+	if !errors.Is(err, ErrDeadlineExpired) || !errors.As(err, &de) || de.Topic != "demo" || de.Late < time.Minute {
+		t.Fatalf("expected a *DeadlineExpiredError, got %v", err)
+	}
+
+	future := time.Now().Add(time.Minute)
+	if _, err := c.Produce(context.Background(), ProduceRequest{Topic: "demo", Value: "v", Envelope: &Envelope{Deadline: &future}}); err != nil {
+		t.Fatalf("Produce: %v", err)
+	}
+	if _, err := c.Produce(context.Background(), ProduceRequest{Topic: "demo", Value: "v"}); err != nil {
+		t.Fatalf("Produce: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.produced) != 2 {
+		t.Fatalf("expected only the unexpired messages to be sent, got %d", len(s.produced))
+	}
+}
+
+func TestWorker_ExpiredPolicies(t *testing.T) {
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339Nano)
+	lines := []string{
+		fmt.Sprintf(`{"partition":0,"offset":1,"attempts":1,"value":"late","envelope":{"deadline":%q}}`, past),
+		`{"partition":0,"offset":2,"attempts":1,"value":"fresh"}`,
+	}
+
+	for _, tc := range []struct {
+		cfg                  ExpiredConfig
+		handled, acks, nacks int
+		dead                 int
+	}{
+		{cfg: ExpiredConfig{Policy: ExpiredAck}, handled: 1, acks: 2},
+		{cfg: ExpiredConfig{Policy: ExpiredSkip}, handled: 1, acks: 2},
+		{cfg: ExpiredConfig{Policy: ExpiredNack}, handled: 1, acks: 1, nacks: 1},
+		{cfg: ExpiredConfig{Policy: ExpiredDeadLetter}, handled: 1, acks: 2, dead: 1},
+		{cfg: ExpiredConfig{Policy: ExpiredNack, Grace: time.Hour}, handled: 2, acks: 2},
+	} {
+		t.Run(fmt.Sprintf("%s/grace=%s", tc.cfg.Policy, tc.cfg.Grace), func(t *testing.T) {
+			var s dlqServer
+			srv := httptest.NewServer(s.handler(lines...))
+			defer srv.Close()
+
+			c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+			if err != nil {
+				t.Fatalf("Dial: %v", err)
+			}
+
+			cfg := tc.cfg
+			handled, expired := 0, 0
+			wk, err := NewWorker(WorkerConfig{
+				Client:     c,
+				Consume:    ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+				DeadLetter: &DeadLetterConfig{Topic: "demo.dlq"},
+				Expired:    &cfg,
+				Hooks: WorkerHooks{
+					OnExpired: func(ctx context.Context, ev ExpiredEvent) {
+						if ev.Message.Offset != 1 || ev.Policy != cfg.Policy || ev.Deadline.IsZero() {
+							t.Errorf("unexpected expired event %+v", ev)
+						}
+						expired++
+					},
+				},
+				Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+					if err := ctx.Err(); err != nil {
+						t.Errorf("offset %d handled with a done ctx: %v", msg.Offset, err)
+					}
+					handled++
+					return nil
+				}),
+			})
+			if err != nil {
+				t.Fatalf("NewWorker: %v", err)
+			}
+			if err := wk.Run(context.Background()); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if handled != tc.handled || len(s.acks) != tc.acks || len(s.nacks) != tc.nacks || len(s.produced) != tc.dead {
+				t.Fatalf("got handled=%d acks=%d nacks=%d dlq=%d", handled, len(s.acks), len(s.nacks), len(s.produced))
+			}
+			if wantExpired := 2 - tc.handled; expired != wantExpired {
+				t.Fatalf("expected %d OnExpired calls, got %d", wantExpired, expired)
+			}
+			if tc.nacks == 1 && s.nacks[0].Reason != ErrDeadlineExpired.Error() {
+				t.Fatalf("unexpected nack reason %q", s.nacks[0].Reason)
+			}
+		})
+	}
+}
+
+func TestStepContext_EmitKeepsParentDeadline(t *testing.T) {
+	parent := time.Now().Add(time.Minute).UTC()
+	sc := NewStepContext(nil, "demo", "g", ConsumeMessage{Envelope: &Envelope{RunID: "r1", StepID: "a", Deadline: &parent}})
+
+	if env := sc.NextEnvelope("next", nil); env.Deadline == nil || !env.Deadline.Equal(parent) {
+		t.Fatalf("expected the child to inherit the deadline, got %v", env.Deadline)
+	}
+
+	later := parent.Add(time.Hour)
+	if env := sc.NextEnvelope("next", &Envelope{Deadline: &later}); !env.Deadline.Equal(parent) {
+		t.Fatalf("expected a later child deadline to be cut to the parent's, got %v", env.Deadline)
+	}
+
+	sooner := parent.Add(-30 * time.Second)
+	if env := sc.NextEnvelope("next", &Envelope{Deadline: &sooner}); !env.Deadline.Equal(sooner) {
+		t.Fatalf("expected a sooner child deadline to be kept, got %v", env.Deadline)
+	}
+
+	free := NewStepContext(nil, "demo", "g", ConsumeMessage{})
+	if env := free.NextEnvelope("next", nil); env.Deadline != nil {
+		t.Fatalf("expected no deadline without a parent deadline, got %v", env.Deadline)
+	}
+}
@@ -0,0 +1,513 @@
+package driftqtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---- Cassettes ----
+// A Recorder captures request/response pairs (NDJSON streams line by line, with timing) into a
+// cassette file; a Replayer serves them back so integration tests run without a broker.
+
+const cassetteVersion = 1
+
+// Redacted replaces secret header values and body fields in cassettes
+const Redacted = "REDACTED"
+
+var (
+	// DefaultRedactHeaders are always redacted in cassettes
+	DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "Idempotency-Key"}
+
+	// DefaultRedactBodyFields are JSON keys (at any depth) whose values are redacted in recorded bodies
+	DefaultRedactBodyFields = []string{"idempotency_key"}
+
+	// DefaultIgnoreBodyFields are JSON keys (at any depth) dropped from request bodies before they
+	// are recorded or matched. Trace context differs on every run, so it would never match otherwise
+	DefaultIgnoreBodyFields = []string{"trace_context", "traceparent", "tracestate", "baggage"}
+
+	// ErrNoInteraction is returned by a strict Replayer for requests it has no recording for
+	ErrNoInteraction = errors.New("driftqtest: no recorded interaction matches request")
+)
+
+type Cassette struct {
+	Version      int            `json:"version"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query,omitempty"` // canonical (sorted) encoding
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"` // redacted, normalized JSON (ignored fields dropped) when the body is JSON
+}
+
+type RecordedResponse struct {
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	Chunks []Chunk     `json:"chunks,omitempty"` // NDJSON streams
+	Error  string      `json:"error,omitempty"`  // transport error instead of a response
+}
+
+// Chunk is one NDJSON line and when it arrived, relative to the response headers
+type Chunk struct {
+	AfterMs int64  `json:"after_ms"`
+	Data    string `json:"data"`
+}
+
+// LoadCassette reads a cassette written by Recorder.Save
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cassette
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if c.Version != cassetteVersion {
+		return nil, fmt.Errorf("%s: unsupported cassette version %d", path, c.Version)
+	}
+	return &c, nil
+}
+
+// ---- Recorder ----
+
+type RecorderConfig struct {
+	// Transport performs the real requests (nil = http.DefaultTransport)
+	Transport http.RoundTripper
+
+	// RedactHeaders and RedactBodyFields add to DefaultRedactHeaders / DefaultRedactBodyFields
+	RedactHeaders    []string
+	RedactBodyFields []string
+
+	// IgnoreBodyFields adds to DefaultIgnoreBodyFields
+	IgnoreBodyFields []string
+}
+
+// Recorder is an http.RoundTripper that records every interaction; use it as driftq.Config.Transport
+type Recorder struct {
+	next    http.RoundTripper
+	headers []string
+	fields  map[string]bool
+	ignore  map[string]bool
+
+	mu  sync.Mutex
+	cas Cassette
+}
+
+func NewRecorder(cfg RecorderConfig) *Recorder {
+	next := cfg.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &Recorder{
+		next:    next,
+		headers: append(append([]string(nil), DefaultRedactHeaders...), cfg.RedactHeaders...),
+		fields:  fieldSet(DefaultRedactBodyFields, cfg.RedactBodyFields),
+		ignore:  fieldSet(DefaultIgnoreBodyFields, cfg.IgnoreBodyFields),
+		cas:     Cassette{Version: cassetteVersion},
+	}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		req.Body = io.NopCloser(bytes.NewReader(b))
+	}
+
+	it := &Interaction{Request: RecordedRequest{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  canonicalQuery(req.URL.RawQuery),
+		Header: redactHeader(req.Header, r.headers),
+		Body:   normalizeBody(reqBody, r.fields, r.ignore),
+	}}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		it.Response.Error = err.Error()
+		r.add(it)
+		return nil, err
+	}
+
+	it.Response.Status = resp.StatusCode
+	it.Response.Header = redactHeader(resp.Header, r.headers)
+
+	if isNDJSON(resp.Header) {
+		resp.Body = &recordingBody{ReadCloser: resp.Body, r: r, it: it, start: time.Now()}
+		r.add(it)
+		return resp, nil
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	it.Response.Body = redactBody(b, r.fields)
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	r.add(it)
+	return resp, nil
+}
+
+func (r *Recorder) add(it *Interaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cas.Interactions = append(r.cas.Interactions, it)
+}
+
+// Cassette returns a snapshot of what has been recorded so far
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, _ := json.Marshal(r.cas)
+	var c Cassette
+	_ = json.Unmarshal(b, &c)
+	return &c
+}
+
+// Save writes the cassette to path. Streams still open are saved with the lines read so far
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	b, err := json.MarshalIndent(r.cas, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// recordingBody records complete NDJSON lines as they are read
+type recordingBody struct {
+	io.ReadCloser
+	r     *Recorder
+	it    *Interaction
+	start time.Time
+	line  []byte
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	b.r.mu.Lock()
+	defer b.r.mu.Unlock()
+
+	after := time.Since(b.start).Milliseconds()
+	for _, c := range p[:n] {
+		b.line = append(b.line, c)
+		if c == '\n' {
+			b.it.Response.Chunks = append(b.it.Response.Chunks, Chunk{AfterMs: after, Data: redactBody(b.line, b.r.fields)})
+			b.line = nil
+		}
+	}
+
+	// A partial last line is only real data if the server ended the stream
+	if errors.Is(err, io.EOF) && len(b.line) > 0 {
+		b.it.Response.Chunks = append(b.it.Response.Chunks, Chunk{AfterMs: after, Data: redactBody(b.line, b.r.fields)})
+		b.line = nil
+	}
+	return n, err
+}
+
+// ---- Replayer ----
+
+type ReplayMode int
+
+const (
+	// ReplayStrict fails unmatched requests with ErrNoInteraction
+	ReplayStrict ReplayMode = iota
+	// ReplayPassthrough sends unmatched requests to ReplayerConfig.Transport
+	ReplayPassthrough
+)
+
+type ReplayerConfig struct {
+	Mode ReplayMode
+
+	// Transport serves unmatched requests in ReplayPassthrough mode (nil = http.DefaultTransport)
+	Transport http.RoundTripper
+
+	// Realtime replays stream chunks with their recorded timing (default: as fast as possible)
+	Realtime bool
+
+	// RedactBodyFields and IgnoreBodyFields must match what the Recorder used, so bodies normalize the same way
+	RedactBodyFields []string
+	IgnoreBodyFields []string
+}
+
+// Replayer serves recorded interactions. Requests match on method, path, query and normalized body;
+// each interaction is used once, in recorded order
+type Replayer struct {
+	cfg    ReplayerConfig
+	next   http.RoundTripper
+	fields map[string]bool
+	ignore map[string]bool
+
+	mu   sync.Mutex
+	its  []*Interaction
+	used []bool
+}
+
+func NewReplayer(c *Cassette, cfg ReplayerConfig) *Replayer {
+	next := cfg.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &Replayer{
+		cfg:    cfg,
+		next:   next,
+		fields: fieldSet(DefaultRedactBodyFields, cfg.RedactBodyFields),
+		ignore: fieldSet(DefaultIgnoreBodyFields, cfg.IgnoreBodyFields),
+		its:    c.Interactions,
+		used:   make([]bool, len(c.Interactions)),
+	}
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		req.Body = io.NopCloser(bytes.NewReader(b))
+	}
+
+	it := r.take(req.Method, req.URL.Path, canonicalQuery(req.URL.RawQuery), normalizeBody(reqBody, r.fields, r.ignore))
+	if it == nil {
+		if r.cfg.Mode == ReplayPassthrough {
+			return r.next.RoundTrip(req)
+		}
+		return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, req.URL.RequestURI())
+	}
+
+	if it.Response.Error != "" {
+		return nil, errors.New(it.Response.Error)
+	}
+
+	resp := &http.Response{
+		Status:     fmt.Sprintf("%d %s", it.Response.Status, http.StatusText(it.Response.Status)),
+		StatusCode: it.Response.Status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     it.Response.Header.Clone(),
+		Request:    req,
+	}
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+
+	if len(it.Response.Chunks) == 0 {
+		resp.Body = io.NopCloser(strings.NewReader(it.Response.Body))
+		resp.ContentLength = int64(len(it.Response.Body))
+		return resp, nil
+	}
+
+	resp.ContentLength = -1
+	if !r.cfg.Realtime {
+		var buf bytes.Buffer
+		for _, c := range it.Response.Chunks {
+			buf.WriteString(c.Data)
+		}
+		resp.Body = io.NopCloser(&buf)
+		return resp, nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		start := time.Now()
+		for _, c := range it.Response.Chunks {
+			wait := time.Duration(c.AfterMs)*time.Millisecond - time.Since(start)
+			if wait > 0 {
+				t := time.NewTimer(wait)
+				select {
+				case <-t.C:
+				case <-req.Context().Done():
+					t.Stop()
+					pw.CloseWithError(req.Context().Err())
+					return
+				}
+			}
+			if _, err := io.WriteString(pw, c.Data); err != nil {
+				return
+			}
+		}
+		pw.Close()
+	}()
+	resp.Body = pr
+	return resp, nil
+}
+
+func (r *Replayer) take(method, path, query, body string) *Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, it := range r.its {
+		q := it.Request
+		if r.used[i] || q.Method != method || q.Path != path || q.Query != query || q.Body != body {
+			continue
+		}
+		r.used[i] = true
+		return it
+	}
+	return nil
+}
+
+// Unused returns the recorded interactions no request has matched yet
+func (r *Replayer) Unused() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out []*Interaction
+	for i, it := range r.its {
+		if !r.used[i] {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// ---- Normalization & redaction ----
+
+func isNDJSON(h http.Header) bool {
+	return strings.Contains(h.Get("Content-Type"), "ndjson")
+}
+
+func canonicalQuery(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	v, err := url.ParseQuery(raw)
+	if err != nil {
+		return raw
+	}
+	return v.Encode()
+}
+
+func redactHeader(h http.Header, names []string) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+
+	out := h.Clone()
+	for _, n := range names {
+		if vs := out.Values(n); len(vs) > 0 {
+			red := make([]string, len(vs))
+			for i := range red {
+				red[i] = Redacted
+			}
+			out[http.CanonicalHeaderKey(n)] = red
+		}
+	}
+	return out
+}
+
+func fieldSet(defaults, extra []string) map[string]bool {
+	out := make(map[string]bool, len(defaults)+len(extra))
+	for _, f := range defaults {
+		out[f] = true
+	}
+	for _, f := range extra {
+		out[f] = true
+	}
+	return out
+}
+
+// normalizeBody redacts fields, drops ignore and re-encodes JSON bodies (sorted keys, no whitespace)
+// so that semantically equal bodies match. Non-JSON bodies are kept as is
+func normalizeBody(b []byte, fields, ignore map[string]bool) string {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return ""
+	}
+
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return string(b)
+	}
+
+	out, err := json.Marshal(redactValue(v, fields, ignore))
+	if err != nil {
+		return string(b)
+	}
+	return string(out)
+}
+
+// redactBody is normalizeBody for recorded responses: bodies without redacted fields are kept
+// byte for byte, and a trailing newline (NDJSON) survives re-encoding
+func redactBody(b []byte, fields map[string]bool) string {
+	hit := false
+	for f := range fields {
+		if bytes.Contains(b, []byte(`"`+f+`"`)) {
+			hit = true
+			break
+		}
+	}
+	if !hit {
+		return string(b)
+	}
+
+	out := normalizeBody(b, fields, nil)
+	if bytes.HasSuffix(b, []byte("\n")) && !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	return out
+}
+
+func redactValue(v any, fields, ignore map[string]bool) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, vv := range t {
+			switch {
+			case ignore[k]:
+				delete(t, k)
+			case fields[k]:
+				t[k] = Redacted
+			default:
+				// An object left empty only by dropping fields (an envelope holding just
+				// trace_context) goes too, so it matches a body that never had it
+				m, ok := vv.(map[string]any)
+				had := ok && len(m) > 0
+				t[k] = redactValue(vv, fields, ignore)
+				if had && len(m) == 0 {
+					delete(t, k)
+				}
+			}
+		}
+	case []any:
+		for i := range t {
+			t[i] = redactValue(t[i], fields, ignore)
+		}
+	}
+	return v
+}
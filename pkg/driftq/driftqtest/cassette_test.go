@@ -0,0 +1,176 @@
+package driftqtest_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq"
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq/driftqtest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCassette_RecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	ctx := waitCtx(t)
+
+	// Record against a live (fake) broker
+	srv := driftqtest.NewServer(driftqtest.Options{})
+	srv.CreateTopic("demo", 1)
+
+	rec := driftqtest.NewRecorder(driftqtest.RecorderConfig{})
+	c, err := driftq.Dial(ctx, driftq.Config{BaseURL: srv.URL, Transport: rec, Tracing: driftq.TracingConfig{Disable: true}})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	session := func(c *driftq.Client) ([]string, error) {
+		var got []string
+		for _, v := range []string{"a", "b"} {
+			env := &driftq.Envelope{IdempotencyKey: "secret-" + v}
+			if _, err := c.Produce(ctx, driftq.ProduceRequest{Topic: "demo", Value: v, Envelope: env}); err != nil {
+				return nil, err
+			}
+		}
+
+		sctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		msgs, _, err := c.ConsumeStream(sctx, driftq.ConsumeOptions{Topic: "demo", Group: "g", Owner: "w1"})
+		if err != nil {
+			return nil, err
+		}
+		for m := range msgs {
+			got = append(got, m.Value)
+			if len(got) == 2 {
+				break
+			}
+		}
+		cancel()
+
+		h, err := c.Healthz(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return append(got, h.Status), nil
+	}
+
+	want, err := session(c)
+	if err != nil {
+		t.Fatalf("recording session: %v", err)
+	}
+	srv.Close()
+
+	if err := rec.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	raw, _ := os.ReadFile(path)
+	if strings.Contains(string(raw), "secret-") {
+		t.Fatalf("cassette leaks idempotency keys:\n%s", raw)
+	}
+
+	// Replay with the broker gone
+	cas, err := driftqtest.LoadCassette(path)
+	if err != nil {
+		t.Fatalf("LoadCassette: %v", err)
+	}
+	rp := driftqtest.NewReplayer(cas, driftqtest.ReplayerConfig{})
+	c, err = driftq.Dial(ctx, driftq.Config{BaseURL: srv.URL, Transport: rp, Tracing: driftq.TracingConfig{Disable: true}})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	got, err := session(c)
+	if err != nil {
+		t.Fatalf("replay session: %v", err)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("replay = %v, recorded %v", got, want)
+	}
+	if u := rp.Unused(); len(u) != 0 {
+		t.Fatalf("expected every interaction to be used, %d left", len(u))
+	}
+
+	// Strict mode: anything not on the cassette fails
+	if _, err := c.Version(ctx); !errors.Is(err, driftqtest.ErrNoInteraction) {
+		t.Fatalf("expected ErrNoInteraction, got %v", err)
+	}
+}
+
+func TestCassette_PassthroughMode(t *testing.T) {
+	ctx := waitCtx(t)
+
+	srv := driftqtest.NewServer(driftqtest.Options{Version: "live"})
+	defer srv.Close()
+
+	rp := driftqtest.NewReplayer(&driftqtest.Cassette{Version: 1}, driftqtest.ReplayerConfig{Mode: driftqtest.ReplayPassthrough})
+	c, err := driftq.Dial(ctx, driftq.Config{BaseURL: srv.URL, Transport: rp})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	v, err := c.Version(ctx)
+	if err != nil || v.Version != "live" {
+		t.Fatalf("expected pass-through to the live server, got %#v err=%v", v, err)
+	}
+}
+
+func TestCassette_ReplayWithTracing(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+
+	// Every run has its own trace, so trace_context differs between recording and replay
+	traced := func(traceByte byte) context.Context {
+		sc := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{traceByte, 1},
+			SpanID:     trace.SpanID{traceByte, 2},
+			TraceFlags: trace.FlagsSampled,
+		})
+		return trace.ContextWithSpanContext(waitCtx(t), sc)
+	}
+
+	session := func(ctx context.Context, c *driftq.Client) error {
+		if _, err := c.Produce(ctx, driftq.ProduceRequest{Topic: "demo", Value: "bare"}); err != nil {
+			return err
+		}
+		env := &driftq.Envelope{IdempotencyKey: "k1"}
+		_, err := c.Produce(ctx, driftq.ProduceRequest{Topic: "demo", Value: "keyed", Envelope: env})
+		return err
+	}
+
+	srv := driftqtest.NewServer(driftqtest.Options{})
+	srv.CreateTopic("demo", 1)
+	rec := driftqtest.NewRecorder(driftqtest.RecorderConfig{})
+	c, err := driftq.Dial(waitCtx(t), driftq.Config{BaseURL: srv.URL, Transport: rec})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	if err := session(traced(1), c); err != nil {
+		t.Fatalf("recording session: %v", err)
+	}
+	srv.Close()
+
+	cas := rec.Cassette()
+	for _, it := range cas.Interactions {
+		if strings.Contains(it.Request.Body, "traceparent") || strings.Contains(it.Request.Body, "trace_context") {
+			t.Fatalf("recorded body keeps trace context: %s", it.Request.Body)
+		}
+	}
+
+	rp := driftqtest.NewReplayer(cas, driftqtest.ReplayerConfig{})
+	c, err = driftq.Dial(waitCtx(t), driftq.Config{BaseURL: srv.URL, Transport: rp})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	if err := session(traced(2), c); err != nil {
+		t.Fatalf("replay with a different trace: %v", err)
+	}
+	if u := rp.Unused(); len(u) != 0 {
+		t.Fatalf("expected every interaction to be used, %d left", len(u))
+	}
+}
@@ -0,0 +1,99 @@
+package driftqtest
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the Server's time source for leases and nack delays
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a channel that fires once d has passed on this clock, and a stop func
+	NewTimer(d time.Duration) (<-chan time.Time, func())
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTimer(d)
+	return t.C, func() { t.Stop() }
+}
+
+// FakeClock only moves when told to. Leases expire and delayed nacks come due on Advance/Set,
+// which makes redelivery tests deterministic
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	nextID  int
+	waiters map[int]fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewFakeClock starts at start (zero = 2025-01-01T00:00:00Z)
+func NewFakeClock(start time.Time) *FakeClock {
+	if start.IsZero() {
+		start = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return &FakeClock{now: start, waiters: make(map[int]fakeWaiter)}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) (<-chan time.Time, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch, func() {}
+	}
+
+	id := c.nextID
+	c.nextID++
+	c.waiters[id] = fakeWaiter{at: c.now.Add(d), ch: ch}
+
+	return ch, func() {
+		c.mu.Lock()
+		delete(c.waiters, id)
+		c.mu.Unlock()
+	}
+}
+
+// Advance moves the clock forward by d and fires every timer that came due
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(c.now.Add(d))
+}
+
+// Set moves the clock to t (never backwards)
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(t)
+}
+
+func (c *FakeClock) setLocked(t time.Time) {
+	if t.Before(c.now) {
+		return
+	}
+
+	c.now = t
+	for id, w := range c.waiters {
+		if !w.at.After(t) {
+			w.ch <- t
+			delete(c.waiters, id)
+		}
+	}
+}
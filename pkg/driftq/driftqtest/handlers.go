@@ -0,0 +1,593 @@
+package driftqtest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq"
+)
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v1/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, driftq.HealthzResponse{Status: "ok"})
+	})
+	mux.HandleFunc("GET /v1/version", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, driftq.VersionResponse{Version: s.opt.Version})
+	})
+
+	mux.HandleFunc("POST /v1/produce", s.handleProduce)
+	mux.HandleFunc("GET /v1/consume", s.handleConsume)
+	mux.HandleFunc("POST /v1/ack", s.handleAck)
+	mux.HandleFunc("POST /v1/nack", s.handleNack)
+
+	mux.HandleFunc("GET /v1/topics", s.handleListTopics)
+	mux.HandleFunc("POST /v1/topics", s.handleCreateTopic)
+	mux.HandleFunc("GET /v1/topics/{name}", s.handleDescribeTopic)
+	mux.HandleFunc("DELETE /v1/topics/{name}", s.handleDeleteTopic)
+	mux.HandleFunc("PATCH /v1/topics/{name}/config", s.handleUpdateTopicConfig)
+	mux.HandleFunc("POST /v1/topics/{name}/partitions", s.handleAddPartitions)
+
+	mux.HandleFunc("GET /v1/groups", s.handleListGroups)
+	mux.HandleFunc("GET /v1/groups/{group}", s.handleDescribeGroup)
+	mux.HandleFunc("DELETE /v1/groups/{group}", s.handleDeleteGroup)
+	mux.HandleFunc("POST /v1/groups/{group}/offsets/reset", s.handleResetGroupOffsets)
+
+	return s.withFaults(mux)
+}
+
+// withFaults applies SetLatency and FailNext before the real handler runs
+func (s *Server) withFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		latency := s.latency
+		status := 0
+		if q := s.faults[r.URL.Path]; len(q) > 0 {
+			status, s.faults[r.URL.Path] = q[0], q[1:]
+		}
+		s.mu.Unlock()
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if status != 0 {
+			writeError(w, status, "INJECTED", "driftqtest: injected failure")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, driftq.ErrorResponse{Error: code, Message: msg})
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return false
+	}
+	return true
+}
+
+// ---- Messages ----
+
+func (s *Server) handleProduce(w http.ResponseWriter, r *http.Request) {
+	var req driftq.ProduceRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Topic) == "" {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "topic is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.topicLocked(req.Topic, true)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "topic not found")
+		return
+	}
+
+	// Duplicate idempotency keys are accepted and dropped
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" && req.Envelope != nil {
+		key = req.Envelope.IdempotencyKey
+	}
+	if key != "" && s.idem[req.Topic][key] {
+		writeJSON(w, http.StatusOK, driftq.ProduceResponse{Status: "ok", Topic: req.Topic})
+		return
+	}
+
+	p, err := s.partitionFor(req.Topic, t, req.Key, req.Envelope)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+		return
+	}
+
+	if key != "" {
+		if s.idem[req.Topic] == nil {
+			s.idem[req.Topic] = make(map[string]bool)
+		}
+		s.idem[req.Topic][key] = true
+	}
+
+	s.appendLocked(t, Message{Topic: req.Topic, Partition: p, Key: req.Key, Value: req.Value, Envelope: req.Envelope})
+	writeJSON(w, http.StatusOK, driftq.ProduceResponse{Status: "ok", Topic: req.Topic})
+}
+
+func (s *Server) handleConsume(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	topic, group, owner := q.Get("topic"), q.Get("group"), q.Get("owner")
+	if topic == "" || group == "" || owner == "" {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "topic, group, and owner are required")
+		return
+	}
+
+	var leaseMS int64
+	if v := q.Get("lease_ms"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "invalid lease_ms")
+			return
+		}
+		leaseMS = n
+	}
+
+	s.mu.Lock()
+	t, err := s.topicLocked(topic, true)
+	drop := s.drop
+	lease := s.opt.DefaultLease
+	if err == nil && t.cfg.DefaultLeaseMs > 0 {
+		lease = time.Duration(t.cfg.DefaultLeaseMs) * time.Millisecond
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "topic not found")
+		return
+	}
+	if leaseMS > 0 {
+		lease = time.Duration(leaseMS) * time.Millisecond
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	enc := json.NewEncoder(w)
+	ctx := r.Context()
+	for {
+		s.mu.Lock()
+		msgs, next := s.leaseLocked(topic, group, owner, lease)
+		changed := s.changed
+		s.mu.Unlock()
+
+		for _, m := range msgs {
+			if err := enc.Encode(m); err != nil {
+				return
+			}
+		}
+		if len(msgs) > 0 {
+			if flusher != nil {
+				flusher.Flush()
+			}
+			continue
+		}
+
+		var timer <-chan time.Time
+		stop := func() {}
+		if !next.IsZero() {
+			timer, stop = s.Clock.NewTimer(next.Sub(s.Clock.Now()))
+		}
+
+		select {
+		case <-changed:
+		case <-timer:
+		case <-drop:
+			stop()
+			return
+		case <-s.done:
+			stop()
+			return
+		case <-ctx.Done():
+			stop()
+			return
+		}
+		stop()
+	}
+}
+
+func (s *Server) handleAck(w http.ResponseWriter, r *http.Request) {
+	var req driftq.AckRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, err := s.settleLocked(req.Topic, req.Group, req.Owner, req.Partition, req.Offset)
+	if err != nil {
+		writeSettleError(w, err)
+		return
+	}
+
+	if !d.acked {
+		d.acked = true
+		d.owner = ""
+		s.acked = append(s.acked, Settlement{
+			Topic: req.Topic, Group: req.Group, Owner: req.Owner,
+			Partition: req.Partition, Offset: req.Offset,
+			Attempts: d.attempts, At: s.Clock.Now(),
+		})
+		s.notify()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleNack(w http.ResponseWriter, r *http.Request) {
+	var req driftq.NackRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.DelayMS < 0 {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "delay_ms must be >= 0")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, err := s.settleLocked(req.Topic, req.Group, req.Owner, req.Partition, req.Offset)
+	if err != nil {
+		writeSettleError(w, err)
+		return
+	}
+	if d.acked {
+		writeError(w, http.StatusConflict, "CONFLICT", "message already acked")
+		return
+	}
+
+	now := s.Clock.Now()
+	delay := time.Duration(req.DelayMS) * time.Millisecond
+	d.owner = ""
+	d.leaseUntil = time.Time{}
+	d.notBefore = now.Add(delay)
+	d.lastError = req.Reason
+
+	s.nacked = append(s.nacked, Settlement{
+		Topic: req.Topic, Group: req.Group, Owner: req.Owner,
+		Partition: req.Partition, Offset: req.Offset,
+		Attempts: d.attempts, Reason: req.Reason, Delay: delay, At: now,
+	})
+	s.notify()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeSettleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errLeaseHeld) {
+		writeError(w, http.StatusConflict, "CONFLICT", err.Error())
+		return
+	}
+	writeError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+}
+
+// ---- Topics ----
+
+type wireTopic struct {
+	Name       string                 `json:"name"`
+	Partitions []driftq.PartitionInfo `json:"partitions"`
+	Config     driftq.TopicConfig     `json:"config"`
+}
+
+func (s *Server) wireTopicLocked(name string) wireTopic {
+	t := s.topics[name]
+	out := wireTopic{Name: name, Config: t.cfg}
+	for p, msgs := range t.parts {
+		out.Partitions = append(out.Partitions, driftq.PartitionInfo{Partition: p, HighWatermark: int64(len(msgs))})
+	}
+	return out
+}
+
+func (s *Server) handleListTopics(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.topics))
+	for n := range s.topics {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	out := driftq.TopicsListResponse{Topics: make([]driftq.Topic, 0, len(names))}
+	for _, n := range names {
+		out.Topics = append(out.Topics, driftq.Topic{Name: n})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleCreateTopic(w http.ResponseWriter, r *http.Request) {
+	var req driftq.TopicsCreateRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" || req.Partitions < 0 {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "name is required and partitions must be >= 0")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.topics[req.Name]; ok {
+		writeError(w, http.StatusConflict, "ALREADY_EXISTS", "topic already exists")
+		return
+	}
+
+	n := req.Partitions
+	if n == 0 {
+		n = s.opt.DefaultPartitions
+	}
+	t := &topicState{parts: make([][]Message, n)}
+	if req.Config != nil {
+		t.cfg = *req.Config
+	}
+	s.topics[req.Name] = t
+	s.notify()
+
+	writeJSON(w, http.StatusOK, driftq.TopicsCreateResponse{Status: "created", Name: req.Name, Partitions: n})
+}
+
+func (s *Server) handleDescribeTopic(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.topics[name]; !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "topic not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.wireTopicLocked(name))
+}
+
+func (s *Server) handleDeleteTopic(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.topics[name]; !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "topic not found")
+		return
+	}
+
+	delete(s.topics, name)
+	delete(s.idem, name)
+	for k := range s.groups {
+		if k.topic == name {
+			delete(s.groups, k)
+		}
+	}
+	s.notify()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleUpdateTopicConfig(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	var upd driftq.TopicConfigUpdate
+	if !decodeBody(w, r, &upd) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.topics[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "topic not found")
+		return
+	}
+
+	if upd.RetentionMs != nil {
+		t.cfg.RetentionMs = *upd.RetentionMs
+	}
+	if upd.MaxMessageBytes != nil {
+		t.cfg.MaxMessageBytes = *upd.MaxMessageBytes
+	}
+	if upd.DefaultLeaseMs != nil {
+		t.cfg.DefaultLeaseMs = *upd.DefaultLeaseMs
+	}
+	writeJSON(w, http.StatusOK, s.wireTopicLocked(name))
+}
+
+func (s *Server) handleAddPartitions(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	var req driftq.TopicsAddPartitionsRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.topics[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "topic not found")
+		return
+	}
+	if req.Partitions < len(t.parts) {
+		writeError(w, http.StatusConflict, "CONFLICT", "partitions can't be removed")
+		return
+	}
+
+	for len(t.parts) < req.Partitions {
+		t.parts = append(t.parts, nil)
+	}
+	writeJSON(w, http.StatusOK, s.wireTopicLocked(name))
+}
+
+// ---- Groups ----
+
+func (s *Server) handleListGroups(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byGroup := map[string][]string{}
+	for k := range s.groups {
+		byGroup[k.group] = append(byGroup[k.group], k.topic)
+	}
+
+	out := driftq.GroupsListResponse{Groups: make([]driftq.Group, 0, len(byGroup))}
+	for g, topics := range byGroup {
+		sort.Strings(topics)
+		out.Groups = append(out.Groups, driftq.Group{Name: g, Topics: topics})
+	}
+	sort.Slice(out.Groups, func(i, j int) bool { return out.Groups[i].Name < out.Groups[j].Name })
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleDescribeGroup(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.describeGroupLocked(r.PathValue("group"), r.URL.Query().Get("topic"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "group not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+func (s *Server) handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	group := r.PathValue("group")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []groupKey
+	now := s.Clock.Now()
+	for k, g := range s.groups {
+		if k.group != group {
+			continue
+		}
+		for _, d := range g.msgs {
+			if !d.acked && d.owner != "" && now.Before(d.leaseUntil) {
+				writeError(w, http.StatusConflict, "CONFLICT", "group has active members")
+				return
+			}
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "group not found")
+		return
+	}
+
+	for _, k := range keys {
+		delete(s.groups, k)
+	}
+	s.notify()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleResetGroupOffsets(w http.ResponseWriter, r *http.Request) {
+	group := r.PathValue("group")
+
+	var req driftq.GroupOffsetsResetRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.topics[req.Topic]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "topic not found")
+		return
+	}
+
+	parts := req.Partitions
+	if len(parts) == 0 {
+		for p := range t.parts {
+			parts = append(parts, p)
+		}
+	}
+	for _, p := range parts {
+		if p < 0 || p >= len(t.parts) {
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "unknown partition")
+			return
+		}
+	}
+
+	out := driftq.GroupOffsetsResetResponse{Group: group, Topic: req.Topic, DryRun: req.DryRun}
+	g := s.groups[groupKey{req.Topic, group}]
+	for _, p := range parts {
+		msgs := t.parts[p]
+
+		var target int64
+		switch req.To {
+		case driftq.ResetToEarliest:
+		case driftq.ResetToLatest:
+			target = int64(len(msgs))
+		case driftq.ResetToTimestamp:
+			target = int64(sort.Search(len(msgs), func(i int) bool {
+				return msgs[i].ProducedAt.UnixMilli() >= req.TimestampMs
+			}))
+		case driftq.ResetToOffset:
+			target = min(max(req.Offset, 0), int64(len(msgs)))
+		default:
+			writeError(w, http.StatusBadRequest, "BAD_REQUEST", "to must be earliest, latest, timestamp or offset")
+			return
+		}
+
+		out.Partitions = append(out.Partitions, driftq.PartitionOffsetChange{
+			Partition:      p,
+			PreviousOffset: committedOffset(g, p, msgs),
+			NewOffset:      target,
+		})
+	}
+
+	if !req.DryRun {
+		// Everything before the new offset counts as acked, everything from it on is fresh
+		g = s.groupLocked(req.Topic, group)
+		for _, c := range out.Partitions {
+			for _, m := range t.parts[c.Partition] {
+				pos := position{c.Partition, m.Offset}
+				if m.Offset < c.NewOffset {
+					g.msgs[pos] = &deliveryState{acked: true}
+				} else {
+					delete(g.msgs, pos)
+				}
+			}
+		}
+		s.notify()
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,560 @@
+// Package driftqtest provides an in-memory DriftQ broker that speaks the HTTP API, for tests.
+//
+//	srv := driftqtest.NewServer(driftqtest.Options{})
+//	defer srv.Close()
+//	srv.CreateTopic("demo", 1)
+//
+//	c, _ := driftq.Dial(ctx, driftq.Config{BaseURL: srv.URL})
+//	// ... run a Worker against c ...
+//	acked, err := srv.WaitForAcked(ctx, "demo", "workers", 3)
+//
+// It implements topics, partitions, produce, NDJSON consume with leases and redelivery,
+// ack/nack with attempt counting, consumer group describe, healthz and version.
+// Leases and nack delays follow Options.Clock, so a FakeClock makes redelivery deterministic.
+//
+// Recorder and Replayer capture interactions with a real broker into a cassette file and serve
+// them back, for integration tests that run without one.
+package driftqtest
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq"
+)
+
+type Options struct {
+	// Clock drives lease expiry and nack delays (default: the real clock)
+	Clock Clock
+
+	// AutoCreateTopics creates unknown topics on produce/consume instead of answering 404
+	AutoCreateTopics bool
+
+	// DefaultPartitions is used by auto-created topics and topic creates without a count (default 1)
+	DefaultPartitions int
+
+	// DefaultLease applies when neither the consumer nor the topic sets one (default 30s)
+	DefaultLease time.Duration
+
+	// Version is reported by /v1/version (default "driftqtest")
+	Version string
+}
+
+func (o Options) withDefaults() Options {
+	if o.Clock == nil {
+		o.Clock = realClock{}
+	}
+	if o.DefaultPartitions <= 0 {
+		o.DefaultPartitions = 1
+	}
+	if o.DefaultLease <= 0 {
+		o.DefaultLease = 30 * time.Second
+	}
+	if o.Version == "" {
+		o.Version = "driftqtest"
+	}
+	return o
+}
+
+// Message is a message as stored by the fake broker
+type Message struct {
+	Topic      string
+	Partition  int
+	Offset     int64
+	Key        string
+	Value      string
+	Routing    *driftq.Routing
+	Envelope   *driftq.Envelope
+	ProducedAt time.Time
+}
+
+// Settlement is an ack or nack the server accepted
+type Settlement struct {
+	Topic     string
+	Group     string
+	Owner     string
+	Partition int
+	Offset    int64
+	Attempts  int // delivery attempt that was settled
+	Reason    string
+	Delay     time.Duration
+	At        time.Time
+}
+
+// Server is an in-memory DriftQ broker on an httptest.Server
+type Server struct {
+	URL   string
+	Clock Clock
+
+	opt Options
+	hs  *httptest.Server
+
+	mu      sync.Mutex
+	topics  map[string]*topicState
+	groups  map[groupKey]*groupState
+	idem    map[string]map[string]bool // topic -> idempotency keys seen
+	rr      map[string]int             // topic -> round-robin cursor for keyless produce
+	acked   []Settlement
+	nacked  []Settlement
+	changed chan struct{} // closed and replaced on every state change
+	drop    chan struct{} // closed and replaced by DropConsumers
+	done    chan struct{}
+	closing sync.Once
+
+	faults  map[string][]int // path -> queued statuses
+	latency time.Duration
+}
+
+type topicState struct {
+	cfg   driftq.TopicConfig
+	parts [][]Message
+}
+
+type groupKey struct{ topic, group string }
+
+type position struct {
+	partition int
+	offset    int64
+}
+
+type groupState struct {
+	msgs map[position]*deliveryState
+}
+
+type deliveryState struct {
+	attempts   int
+	owner      string
+	leaseUntil time.Time
+	notBefore  time.Time
+	acked      bool
+	lastError  string
+}
+
+// NewServer starts a fake broker; Close it when done
+func NewServer(opt Options) *Server {
+	opt = opt.withDefaults()
+
+	s := &Server{
+		Clock:   opt.Clock,
+		opt:     opt,
+		topics:  make(map[string]*topicState),
+		groups:  make(map[groupKey]*groupState),
+		idem:    make(map[string]map[string]bool),
+		rr:      make(map[string]int),
+		changed: make(chan struct{}),
+		drop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		faults:  make(map[string][]int),
+	}
+
+	s.hs = httptest.NewServer(s.routes())
+	s.URL = s.hs.URL
+	return s
+}
+
+// Close ends open consume streams and shuts the server down
+func (s *Server) Close() {
+	s.closing.Do(func() { close(s.done) })
+	s.hs.Close()
+}
+
+// notify wakes consume streams and waiters. Callers hold s.mu
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// ---- Setup ----
+
+// CreateTopic creates (or resizes, never shrinking) a topic; partitions <= 0 uses DefaultPartitions
+func (s *Server) CreateTopic(name string, partitions int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if partitions <= 0 {
+		partitions = s.opt.DefaultPartitions
+	}
+
+	t, ok := s.topics[name]
+	if !ok {
+		t = &topicState{}
+		s.topics[name] = t
+	}
+	for len(t.parts) < partitions {
+		t.parts = append(t.parts, nil)
+	}
+}
+
+// Publish stores m as if it had been produced. Unlike the HTTP API it can set Routing.
+// Partition is used as given when m.Envelope has no override; the stored message is returned
+func (s *Server) Publish(m Message) (Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, err := s.topicLocked(m.Topic, true)
+	if err != nil {
+		return Message{}, err
+	}
+	if m.Envelope != nil && m.Envelope.PartitionOverride != nil {
+		m.Partition = *m.Envelope.PartitionOverride
+	}
+	if m.Partition < 0 || m.Partition >= len(t.parts) {
+		return Message{}, errors.New("partition out of range")
+	}
+
+	return s.appendLocked(t, m), nil
+}
+
+func (s *Server) topicLocked(name string, create bool) (*topicState, error) {
+	if t, ok := s.topics[name]; ok {
+		return t, nil
+	}
+	if !create || !s.opt.AutoCreateTopics {
+		return nil, driftq.ErrTopicNotFound
+	}
+
+	t := &topicState{parts: make([][]Message, s.opt.DefaultPartitions)}
+	s.topics[name] = t
+	return t, nil
+}
+
+func (s *Server) appendLocked(t *topicState, m Message) Message {
+	m.Offset = int64(len(t.parts[m.Partition]))
+	m.ProducedAt = s.Clock.Now()
+	t.parts[m.Partition] = append(t.parts[m.Partition], m)
+	s.notify()
+	return m
+}
+
+// partitionFor picks a partition like the broker: override, else key hash, else round-robin
+func (s *Server) partitionFor(topic string, t *topicState, key string, env *driftq.Envelope) (int, error) {
+	n := len(t.parts)
+	if env != nil && env.PartitionOverride != nil {
+		p := *env.PartitionOverride
+		if p < 0 || p >= n {
+			return 0, errors.New("partition_override out of range")
+		}
+		return p, nil
+	}
+
+	if key != "" {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(key))
+		return int(h.Sum32() % uint32(n)), nil
+	}
+
+	p := s.rr[topic] % n
+	s.rr[topic]++
+	return p, nil
+}
+
+func (s *Server) groupLocked(topic, group string) *groupState {
+	k := groupKey{topic, group}
+	g, ok := s.groups[k]
+	if !ok {
+		g = &groupState{msgs: make(map[position]*deliveryState)}
+		s.groups[k] = g
+	}
+	return g
+}
+
+// ---- Fault injection ----
+
+// FailNext makes the next n requests to path (e.g. "/v1/ack", "/v1/consume") answer status
+func (s *Server) FailNext(path string, status, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for range n {
+		s.faults[path] = append(s.faults[path], status)
+	}
+}
+
+// SetLatency delays every request (and the opening of consume streams) by d of real time
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// DropConsumers ends every open consume stream; clients see a clean EOF.
+// Leased messages stay leased until their lease expires, like a real disconnect
+func (s *Server) DropConsumers() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	close(s.drop)
+	s.drop = make(chan struct{})
+}
+
+// ---- Assertions ----
+
+// Messages returns everything stored on topic, ordered by partition then offset
+func (s *Server) Messages(topic string) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.topics[topic]
+	if !ok {
+		return nil
+	}
+
+	var out []Message
+	for _, p := range t.parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// Acked returns the acks accepted for topic/group, in order
+func (s *Server) Acked(topic, group string) []Settlement {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return filterSettlements(s.acked, topic, group)
+}
+
+// Nacked returns the nacks accepted for topic/group, in order
+func (s *Server) Nacked(topic, group string) []Settlement {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return filterSettlements(s.nacked, topic, group)
+}
+
+// Attempts is how many times the message at partition/offset was delivered to group
+func (s *Server) Attempts(topic, group string, partition int, offset int64) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g, ok := s.groups[groupKey{topic, group}]
+	if !ok {
+		return 0
+	}
+	if d, ok := g.msgs[position{partition, offset}]; ok {
+		return d.attempts
+	}
+	return 0
+}
+
+// WaitForAcked blocks until topic/group has at least n acks, or ctx is done
+func (s *Server) WaitForAcked(ctx context.Context, topic, group string, n int) ([]Settlement, error) {
+	return s.waitFor(ctx, func() []Settlement { return filterSettlements(s.acked, topic, group) }, n)
+}
+
+// WaitForNacked blocks until topic/group has at least n nacks, or ctx is done
+func (s *Server) WaitForNacked(ctx context.Context, topic, group string, n int) ([]Settlement, error) {
+	return s.waitFor(ctx, func() []Settlement { return filterSettlements(s.nacked, topic, group) }, n)
+}
+
+func (s *Server) waitFor(ctx context.Context, get func() []Settlement, n int) ([]Settlement, error) {
+	for {
+		s.mu.Lock()
+		got := get()
+		changed := s.changed
+		s.mu.Unlock()
+
+		if len(got) >= n {
+			return got, nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return got, ctx.Err()
+		}
+	}
+}
+
+func filterSettlements(all []Settlement, topic, group string) []Settlement {
+	var out []Settlement
+	for _, st := range all {
+		if st.Topic == topic && st.Group == group {
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+// ---- Delivery ----
+
+// leaseLocked leases every message of topic that group can have right now.
+// It also returns when the next one becomes available (zero if nothing is pending)
+func (s *Server) leaseLocked(topic, group, owner string, lease time.Duration) ([]driftq.ConsumeMessage, time.Time) {
+	t, ok := s.topics[topic]
+	if !ok {
+		return nil, time.Time{}
+	}
+
+	g := s.groupLocked(topic, group)
+	now := s.Clock.Now()
+
+	var (
+		out  []driftq.ConsumeMessage
+		next time.Time
+	)
+	wake := func(at time.Time) {
+		if next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+
+	for p, msgs := range t.parts {
+		for _, m := range msgs {
+			pos := position{p, m.Offset}
+			d, ok := g.msgs[pos]
+			if !ok {
+				d = &deliveryState{}
+				g.msgs[pos] = d
+			}
+
+			switch {
+			case d.acked:
+				continue
+			case d.owner != "" && now.Before(d.leaseUntil):
+				wake(d.leaseUntil)
+				continue
+			case now.Before(d.notBefore):
+				wake(d.notBefore)
+				continue
+			}
+
+			d.attempts++
+			d.owner = owner
+			d.leaseUntil = now.Add(lease)
+			wake(d.leaseUntil)
+
+			out = append(out, driftq.ConsumeMessage{
+				Partition: p,
+				Offset:    m.Offset,
+				Attempts:  d.attempts,
+				Key:       m.Key,
+				Value:     m.Value,
+				LastError: d.lastError,
+				Routing:   m.Routing,
+				Envelope:  m.Envelope,
+			})
+		}
+	}
+	return out, next
+}
+
+// settleLocked validates that owner may settle partition/offset for group
+func (s *Server) settleLocked(topic, group, owner string, partition int, offset int64) (*deliveryState, error) {
+	g, ok := s.groups[groupKey{topic, group}]
+	if !ok {
+		return nil, errNotDelivered
+	}
+
+	d, ok := g.msgs[position{partition, offset}]
+	if !ok || d.attempts == 0 {
+		return nil, errNotDelivered
+	}
+	if d.acked {
+		return d, nil
+	}
+
+	// Someone else holds a live lease on it now
+	if d.owner != "" && d.owner != owner && s.Clock.Now().Before(d.leaseUntil) {
+		return nil, errLeaseHeld
+	}
+	return d, nil
+}
+
+var (
+	errNotDelivered = errors.New("message has not been delivered to this group")
+	errLeaseHeld    = errors.New("message is leased to another owner")
+)
+
+// committedOffset is one past the end of the acked prefix of msgs (g may be nil)
+func committedOffset(g *groupState, partition int, msgs []Message) int64 {
+	if g == nil {
+		return 0
+	}
+
+	var n int64
+	for _, m := range msgs {
+		d := g.msgs[position{partition, m.Offset}]
+		if d == nil || !d.acked {
+			break
+		}
+		n = m.Offset + 1
+	}
+	return n
+}
+
+// describeGroupLocked builds the /v1/groups/{group} payload
+func (s *Server) describeGroupLocked(group, topicFilter string) (driftq.GroupDescription, bool) {
+	out := driftq.GroupDescription{Name: group}
+	now := s.Clock.Now()
+	owners := map[string]map[string]map[int]bool{} // owner -> topic -> partitions
+
+	var topics []string
+	for k := range s.groups {
+		if k.group == group && (topicFilter == "" || k.topic == topicFilter) {
+			topics = append(topics, k.topic)
+		}
+	}
+	if len(topics) == 0 {
+		return out, false
+	}
+	sort.Strings(topics)
+
+	for _, topic := range topics {
+		g := s.groups[groupKey{topic, group}]
+		t, ok := s.topics[topic]
+		if !ok {
+			continue
+		}
+
+		for p, msgs := range t.parts {
+			gp := driftq.GroupPartition{Topic: topic, Partition: p, HighWatermark: int64(len(msgs))}
+
+			committed := true
+			for _, m := range msgs {
+				d := g.msgs[position{p, m.Offset}]
+				acked := d != nil && d.acked
+				if committed && acked {
+					gp.CommittedOffset = m.Offset + 1
+				} else {
+					committed = false
+				}
+
+				if d != nil && !d.acked && d.owner != "" && now.Before(d.leaseUntil) {
+					gp.InFlight++
+					gp.Owner = d.owner
+					if owners[d.owner] == nil {
+						owners[d.owner] = map[string]map[int]bool{}
+					}
+					if owners[d.owner][topic] == nil {
+						owners[d.owner][topic] = map[int]bool{}
+					}
+					owners[d.owner][topic][p] = true
+				}
+			}
+			gp.Lag = gp.HighWatermark - gp.CommittedOffset
+			out.Partitions = append(out.Partitions, gp)
+		}
+	}
+
+	for owner, byTopic := range owners {
+		for topic, parts := range byTopic {
+			m := driftq.GroupMember{Owner: owner, Topic: topic}
+			for p := range parts {
+				m.Partitions = append(m.Partitions, p)
+			}
+			sort.Ints(m.Partitions)
+			out.Members = append(out.Members, m)
+		}
+	}
+	sort.Slice(out.Members, func(i, j int) bool {
+		if out.Members[i].Owner != out.Members[j].Owner {
+			return out.Members[i].Owner < out.Members[j].Owner
+		}
+		return out.Members[i].Topic < out.Members[j].Topic
+	})
+	return out, true
+}
@@ -0,0 +1,268 @@
+package driftqtest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq"
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq/driftqtest"
+)
+
+func dial(t *testing.T, srv *driftqtest.Server, retry driftq.RetryConfig) *driftq.Client {
+	t.Helper()
+
+	c, err := driftq.Dial(context.Background(), driftq.Config{BaseURL: srv.URL, Retry: retry})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	return c
+}
+
+func runWorker(t *testing.T, c *driftq.Client, h driftq.StepHandler) context.CancelFunc {
+	t.Helper()
+
+	wk, err := driftq.NewWorker(driftq.WorkerConfig{
+		Client:  c,
+		Consume: driftq.ConsumeOptions{Topic: "demo", Group: "g", Owner: "w1"},
+		Handler: h,
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = wk.Run(ctx)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func waitCtx(t *testing.T) context.Context {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestServer_ProduceConsumeAck(t *testing.T) {
+	srv := driftqtest.NewServer(driftqtest.Options{})
+	defer srv.Close()
+	srv.CreateTopic("demo", 2)
+
+	c := dial(t, srv, driftq.RetryConfig{})
+	ctx := waitCtx(t)
+
+	for _, k := range []string{"a", "b", "c"} {
+		if _, err := c.Produce(ctx, driftq.ProduceRequest{Topic: "demo", Key: k, Value: "v-" + k}); err != nil {
+			t.Fatalf("Produce: %v", err)
+		}
+	}
+	// Same idempotency key twice is stored once
+	for range 2 {
+		env := &driftq.Envelope{IdempotencyKey: "once"}
+		if _, err := c.Produce(ctx, driftq.ProduceRequest{Topic: "demo", Value: "dup", Envelope: env}); err != nil {
+			t.Fatalf("Produce: %v", err)
+		}
+	}
+	if got := len(srv.Messages("demo")); got != 4 {
+		t.Fatalf("expected 4 stored messages, got %d", got)
+	}
+
+	stop := runWorker(t, c, driftq.StepFunc(func(ctx context.Context, msg driftq.ConsumeMessage) error { return nil }))
+	defer stop()
+
+	acked, err := srv.WaitForAcked(ctx, "demo", "g", 4)
+	if err != nil {
+		t.Fatalf("WaitForAcked: %v (got %d)", err, len(acked))
+	}
+	for _, a := range acked {
+		if a.Owner != "w1" || a.Attempts != 1 {
+			t.Fatalf("unexpected ack %#v", a)
+		}
+	}
+
+	g, err := c.Admin().DescribeGroup(ctx, "g", "demo")
+	if err != nil || g.TotalLag() != 0 || len(g.Partitions) != 2 {
+		t.Fatalf("DescribeGroup: %#v err=%v", g, err)
+	}
+	if _, err := c.Admin().DescribeTopic(ctx, "missing"); !errors.Is(err, driftq.ErrTopicNotFound) {
+		t.Fatalf("expected ErrTopicNotFound, got %v", err)
+	}
+}
+
+func TestServer_NackDelayAndLeaseExpiryFollowFakeClock(t *testing.T) {
+	clock := driftqtest.NewFakeClock(time.Time{})
+	srv := driftqtest.NewServer(driftqtest.Options{Clock: clock, AutoCreateTopics: true})
+	defer srv.Close()
+
+	c := dial(t, srv, driftq.RetryConfig{})
+	ctx := waitCtx(t)
+
+	if _, err := srv.Publish(driftqtest.Message{Topic: "demo", Value: "x", Routing: &driftq.Routing{Label: "orders.created"}}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	msgs, _, err := c.ConsumeStream(ctx, driftq.ConsumeOptions{Topic: "demo", Group: "g", Owner: "w1", LeaseMS: 1000})
+	if err != nil {
+		t.Fatalf("ConsumeStream: %v", err)
+	}
+
+	m := <-msgs
+	if m.Attempts != 1 || m.Routing == nil || m.Routing.Label != "orders.created" {
+		t.Fatalf("unexpected first delivery %#v", m)
+	}
+
+	// Nack with a delay: nothing until the clock passes it
+	if err := c.Nack(ctx, driftq.NackRequest{Topic: "demo", Group: "g", Owner: "w1", Offset: m.Offset, Reason: "boom", DelayMS: 5000}); err != nil {
+		t.Fatalf("Nack: %v", err)
+	}
+	clock.Advance(4 * time.Second)
+	select {
+	case m := <-msgs:
+		t.Fatalf("redelivered before the nack delay: %#v", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+	m = <-msgs
+	if m.Attempts != 2 || m.LastError != "boom" {
+		t.Fatalf("unexpected redelivery %#v", m)
+	}
+
+	// Don't settle: the lease expires and it comes back again
+	clock.Advance(time.Second)
+	m = <-msgs
+	if m.Attempts != 3 || srv.Attempts("demo", "g", 0, m.Offset) != 3 {
+		t.Fatalf("expected lease-expiry redelivery, got %#v", m)
+	}
+
+	if err := c.Ack(ctx, driftq.AckRequest{Topic: "demo", Group: "g", Owner: "w1", Offset: m.Offset}); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+	if n := srv.Nacked("demo", "g"); len(n) != 1 || n[0].Delay != 5*time.Second {
+		t.Fatalf("unexpected nacks %#v", n)
+	}
+}
+
+func TestServer_FaultInjection(t *testing.T) {
+	srv := driftqtest.NewServer(driftqtest.Options{})
+	defer srv.Close()
+	srv.CreateTopic("demo", 1)
+
+	ctx := waitCtx(t)
+
+	// Retries (idempotent produce) ride over injected 503s
+	c := dial(t, srv, driftq.RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	srv.FailNext("/v1/produce", http.StatusServiceUnavailable, 2)
+	env := &driftq.Envelope{IdempotencyKey: "k1"}
+	if _, err := c.Produce(ctx, driftq.ProduceRequest{Topic: "demo", Value: "v", Envelope: env}); err != nil {
+		t.Fatalf("Produce with retries: %v", err)
+	}
+	if n := len(srv.Messages("demo")); n != 1 {
+		t.Fatalf("expected 1 stored message, got %d", n)
+	}
+
+	srv.FailNext("/v1/healthz", http.StatusInternalServerError, 1)
+	plain := dial(t, srv, driftq.RetryConfig{MaxAttempts: 1})
+	var ae *driftq.APIError
+	if _, err := plain.Healthz(ctx); !errors.As(err, &ae) || ae.Code != "INJECTED" {
+		t.Fatalf("expected injected failure, got %v", err)
+	}
+	if _, err := plain.Healthz(ctx); err != nil {
+		t.Fatalf("fault should have been used up: %v", err)
+	}
+
+	// A dropped stream ends cleanly
+	msgs, _, err := plain.ConsumeStream(ctx, driftq.ConsumeOptions{Topic: "demo", Group: "g", Owner: "w1"})
+	if err != nil {
+		t.Fatalf("ConsumeStream: %v", err)
+	}
+	<-msgs
+	srv.DropConsumers()
+	if _, ok := <-msgs; ok {
+		t.Fatalf("expected stream to close after DropConsumers")
+	}
+}
+
+func TestServer_ResetOffsetsAndDeleteGroup(t *testing.T) {
+	srv := driftqtest.NewServer(driftqtest.Options{})
+	defer srv.Close()
+	srv.CreateTopic("demo", 1)
+
+	c := dial(t, srv, driftq.RetryConfig{})
+	a := c.Admin()
+	ctx := waitCtx(t)
+
+	for _, v := range []string{"a", "b", "c"} {
+		if _, err := c.Produce(ctx, driftq.ProduceRequest{Topic: "demo", Value: v}); err != nil {
+			t.Fatalf("Produce: %v", err)
+		}
+	}
+	stop := runWorker(t, c, driftq.StepFunc(func(ctx context.Context, msg driftq.ConsumeMessage) error { return nil }))
+	if _, err := srv.WaitForAcked(ctx, "demo", "g", 3); err != nil {
+		t.Fatalf("WaitForAcked: %v", err)
+	}
+	stop()
+
+	// A dry run reports the plan and changes nothing
+	rr, err := a.ResetGroupOffsets(ctx, "g", driftq.GroupOffsetsResetRequest{Topic: "demo", To: driftq.ResetToEarliest, DryRun: true})
+	if err != nil || !rr.DryRun || len(rr.Partitions) != 1 || rr.Partitions[0].PreviousOffset != 3 || rr.Partitions[0].NewOffset != 0 {
+		t.Fatalf("dry run: %#v err=%v", rr, err)
+	}
+	if g, err := a.DescribeGroup(ctx, "g", "demo"); err != nil || g.TotalLag() != 0 {
+		t.Fatalf("dry run moved the group: %#v err=%v", g, err)
+	}
+
+	rr, err = a.ResetGroupOffsets(ctx, "g", driftq.GroupOffsetsResetRequest{Topic: "demo", To: driftq.ResetToOffset, Offset: 1})
+	if err != nil || rr.Partitions[0].NewOffset != 1 {
+		t.Fatalf("reset: %#v err=%v", rr, err)
+	}
+	if g, err := a.DescribeGroup(ctx, "g", "demo"); err != nil || g.Partitions[0].CommittedOffset != 1 || g.TotalLag() != 2 {
+		t.Fatalf("unexpected group after reset: %#v err=%v", g, err)
+	}
+	if _, err := a.ResetGroupOffsets(ctx, "g", driftq.GroupOffsetsResetRequest{Topic: "missing", To: driftq.ResetToLatest}); !errors.Is(err, driftq.ErrGroupNotFound) {
+		t.Fatalf("expected a 404 for an unknown topic, got %v", err)
+	}
+
+	// Offsets 1 and 2 come back; holding a lease blocks the delete
+	msgs, _, err := c.ConsumeStream(ctx, driftq.ConsumeOptions{Topic: "demo", Group: "g", Owner: "w2"})
+	if err != nil {
+		t.Fatalf("ConsumeStream: %v", err)
+	}
+	m := <-msgs
+	if m.Offset != 1 || m.Attempts != 1 {
+		t.Fatalf("unexpected redelivery %#v", m)
+	}
+	if err := a.DeleteGroup(ctx, "g"); !errors.Is(err, driftq.ErrConflict) {
+		t.Fatalf("expected ErrConflict while a lease is held, got %v", err)
+	}
+	for _, off := range []int64{1, (<-msgs).Offset} {
+		if err := c.Ack(ctx, driftq.AckRequest{Topic: "demo", Group: "g", Owner: "w2", Offset: off}); err != nil {
+			t.Fatalf("Ack: %v", err)
+		}
+	}
+
+	// Wait for the stream handler to return so nothing re-creates the group
+	srv.DropConsumers()
+	for range msgs {
+	}
+	if err := a.DeleteGroup(ctx, "g"); err != nil {
+		t.Fatalf("DeleteGroup: %v", err)
+	}
+	if _, err := a.DescribeGroup(ctx, "g", ""); !errors.Is(err, driftq.ErrGroupNotFound) {
+		t.Fatalf("expected ErrGroupNotFound after delete, got %v", err)
+	}
+	if err := a.DeleteGroup(ctx, "g"); !errors.Is(err, driftq.ErrGroupNotFound) {
+		t.Fatalf("expected ErrGroupNotFound on a second delete, got %v", err)
+	}
+}
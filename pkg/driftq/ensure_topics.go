@@ -0,0 +1,339 @@
+package driftq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TopicSpec is the desired state of one topic for EnsureTopics.
+// Partitions 0 means "don't care" (server default on create); nil Config or zero fields are not checked
+type TopicSpec struct {
+	Name       string       `json:"name"`
+	Partitions int          `json:"partitions,omitempty"`
+	Config     *TopicConfig `json:"config,omitempty"`
+}
+
+// DriftPolicy says what EnsureTopics does when an existing topic differs from its spec
+type DriftPolicy int
+
+const (
+	// DriftReport records the drift in the result and changes nothing (default)
+	DriftReport DriftPolicy = iota
+	// DriftReconcile grows partitions and updates config to match the spec.
+	// Drift that can't be fixed (fewer partitions than exist) is still reported
+	DriftReconcile
+	// DriftFail changes nothing and makes EnsureTopics return ErrTopicDrift
+	DriftFail
+)
+
+type EnsureTopicsOptions struct {
+	Drift DriftPolicy
+
+	// DryRun describes topics and returns the plan without creating or changing anything
+	DryRun bool
+}
+
+// TopicAction is what EnsureTopics did (or, with DryRun, would do) for a topic
+type TopicAction string
+
+const (
+	TopicUnchanged  TopicAction = "unchanged"
+	TopicCreated    TopicAction = "created"
+	TopicDrifted    TopicAction = "drifted"    // differs from spec and left as is
+	TopicReconciled TopicAction = "reconciled" // brought in line with spec
+	TopicFailed     TopicAction = "failed"
+)
+
+// TopicDrift is one field that differs from the spec
+type TopicDrift struct {
+	Field   string // "partitions", "retention_ms", "max_message_bytes", "default_lease_ms"
+	Want    int64
+	Have    int64
+	Fixable bool // false when reconciling is impossible (partitions can't shrink)
+}
+
+type TopicEnsureResult struct {
+	Name   string
+	Action TopicAction
+	Drift  []TopicDrift
+	Err    error
+}
+
+type EnsureTopicsResult struct {
+	DryRun bool
+	Topics []TopicEnsureResult
+}
+
+// Changed reports whether anything was (or, with DryRun, would be) created or reconciled
+func (r EnsureTopicsResult) Changed() bool {
+	for _, t := range r.Topics {
+		if t.Action == TopicCreated || t.Action == TopicReconciled {
+			return true
+		}
+	}
+	return false
+}
+
+// Drifted returns the topics left different from their spec
+func (r EnsureTopicsResult) Drifted() []TopicEnsureResult {
+	var out []TopicEnsureResult
+	for _, t := range r.Topics {
+		if t.Action == TopicDrifted {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// EnsureTopics makes sure every spec'd topic exists, reporting drift (DriftReport)
+func (a *Admin) EnsureTopics(ctx context.Context, specs []TopicSpec) (EnsureTopicsResult, error) {
+	return a.EnsureTopicsWithOptions(ctx, specs, EnsureTopicsOptions{})
+}
+
+// EnsureTopicsWithOptions creates missing topics and handles drift per opt.Drift.
+// Which topics exist comes from ListTopics, since older servers answer 404 to every describe.
+// An already-exists race on create counts as success and the topic is then compared like any other;
+// a topic the server can't describe is reported unchanged without a drift check.
+// Every spec is processed; the returned error joins per-topic failures (and ErrTopicDrift under DriftFail)
+func (a *Admin) EnsureTopicsWithOptions(ctx context.Context, specs []TopicSpec, opt EnsureTopicsOptions) (EnsureTopicsResult, error) {
+	if err := validateTopicSpecs(specs); err != nil {
+		return EnsureTopicsResult{}, err
+	}
+
+	list, err := a.ListTopics(ctx)
+	if err != nil {
+		return EnsureTopicsResult{}, err
+	}
+	exists := make(map[string]bool, len(list.Topics))
+	for _, t := range list.Topics {
+		exists[t.Name] = true
+	}
+
+	res := EnsureTopicsResult{DryRun: opt.DryRun, Topics: make([]TopicEnsureResult, 0, len(specs))}
+	var errs []error
+	for _, s := range specs {
+		tr := a.ensureTopic(ctx, s, exists[s.Name], opt)
+		res.Topics = append(res.Topics, tr)
+
+		switch {
+		case tr.Err != nil:
+			errs = append(errs, fmt.Errorf("topic %q: %w", tr.Name, tr.Err))
+		case tr.Action == TopicDrifted && opt.Drift == DriftFail:
+			errs = append(errs, fmt.Errorf("topic %q: %w", tr.Name, ErrTopicDrift))
+		}
+	}
+	return res, errors.Join(errs...)
+}
+
+func (a *Admin) ensureTopic(ctx context.Context, s TopicSpec, exists bool, opt EnsureTopicsOptions) TopicEnsureResult {
+	tr := TopicEnsureResult{Name: s.Name}
+	fail := func(err error) TopicEnsureResult {
+		tr.Action, tr.Err = TopicFailed, err
+		return tr
+	}
+
+	if !exists {
+		tr.Action = TopicCreated
+		if opt.DryRun {
+			return tr
+		}
+
+		_, err := a.CreateTopicWithConfig(ctx, TopicsCreateRequest{Name: s.Name, Partitions: s.Partitions, Config: s.Config})
+		if err == nil {
+			return tr
+		}
+		if !errors.Is(err, ErrTopicExists) {
+			return fail(err)
+		}
+		// Someone else created it first: compare theirs with ours
+	}
+
+	d, err := a.DescribeTopic(ctx, s.Name)
+	if errors.Is(err, ErrTopicNotFound) {
+		// It exists (listed, or create said 409), so this server just can't describe topics
+		tr.Action = TopicUnchanged
+		return tr
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	tr.Drift = topicDrift(s, d)
+	if len(tr.Drift) == 0 {
+		tr.Action = TopicUnchanged
+		return tr
+	}
+
+	tr.Action = TopicDrifted
+	if opt.Drift != DriftReconcile {
+		return tr
+	}
+
+	var (
+		grow     int
+		upd      TopicConfigUpdate
+		updating bool
+		stuck    bool
+	)
+	for _, dr := range tr.Drift {
+		if !dr.Fixable {
+			stuck = true
+			continue
+		}
+
+		v := dr.Want
+		switch dr.Field {
+		case "partitions":
+			grow = int(v)
+		case "retention_ms":
+			upd.RetentionMs, updating = &v, true
+		case "max_message_bytes":
+			upd.MaxMessageBytes, updating = &v, true
+		case "default_lease_ms":
+			upd.DefaultLeaseMs, updating = &v, true
+		}
+	}
+
+	if grow == 0 && !updating {
+		return tr
+	}
+	if !opt.DryRun {
+		if grow > 0 {
+			if _, err := a.AddPartitions(ctx, s.Name, grow); err != nil {
+				return fail(err)
+			}
+		}
+		if updating {
+			if _, err := a.UpdateTopicConfig(ctx, s.Name, upd); err != nil {
+				return fail(err)
+			}
+		}
+	}
+
+	if !stuck {
+		tr.Action = TopicReconciled
+	}
+	return tr
+}
+
+// topicDrift compares a spec with what the server reports. Config is only compared when the
+// server reports any (older servers don't), and only for fields the spec sets
+func topicDrift(s TopicSpec, d TopicDescription) []TopicDrift {
+	var out []TopicDrift
+
+	if s.Partitions > 0 && d.Partitions > 0 && s.Partitions != d.Partitions {
+		out = append(out, TopicDrift{
+			Field:   "partitions",
+			Want:    int64(s.Partitions),
+			Have:    int64(d.Partitions),
+			Fixable: s.Partitions > d.Partitions,
+		})
+	}
+
+	if s.Config == nil || d.Config == (TopicConfig{}) {
+		return out
+	}
+
+	check := func(field string, want, have int64) {
+		if want != 0 && want != have {
+			out = append(out, TopicDrift{Field: field, Want: want, Have: have, Fixable: true})
+		}
+	}
+	check("retention_ms", s.Config.RetentionMs, d.Config.RetentionMs)
+	check("max_message_bytes", s.Config.MaxMessageBytes, d.Config.MaxMessageBytes)
+	check("default_lease_ms", s.Config.DefaultLeaseMs, d.Config.DefaultLeaseMs)
+	return out
+}
+
+func validateTopicSpecs(specs []TopicSpec) error {
+	seen := make(map[string]bool, len(specs))
+	for i, s := range specs {
+		name := strings.TrimSpace(s.Name)
+		switch {
+		case name == "":
+			return fmt.Errorf("topic spec %d: name is required", i)
+		case name != s.Name:
+			return fmt.Errorf("topic spec %q: name has surrounding whitespace", s.Name)
+		case seen[name]:
+			return fmt.Errorf("topic spec %q: duplicate name", name)
+		case s.Partitions < 0:
+			return fmt.Errorf("topic spec %q: partitions must be >= 0", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// ---- Spec files ----
+
+// TopicSpecFile is the on-disk topology format:
+//
+//	topics:
+//	  - name: orders
+//	    partitions: 3
+//	    config:
+//	      retention_ms: 604800000
+//
+// A bare list of specs is accepted too. Keys match the JSON API names
+type TopicSpecFile struct {
+	Topics []TopicSpec `json:"topics"`
+}
+
+// LoadTopicSpecs reads a YAML or JSON spec file (see TopicSpecFile)
+func LoadTopicSpecs(path string) ([]TopicSpec, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	specs, err := ParseTopicSpecs(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return specs, nil
+}
+
+// ParseTopicSpecs decodes YAML or JSON (JSON is valid YAML). Unknown keys are an error so typos
+// in committed topology don't get silently ignored
+func ParseTopicSpecs(data []byte) ([]TopicSpec, error) {
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	// Round-trip through JSON so the json tags (and their names) are the single source of truth
+	jb, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var specs []TopicSpec
+	if _, isList := raw.([]any); isList {
+		err = decodeStrict(jb, &specs)
+	} else {
+		var f TopicSpecFile
+		err = decodeStrict(jb, &f)
+		specs = f.Topics
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateTopicSpecs(specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+func decodeStrict(b []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
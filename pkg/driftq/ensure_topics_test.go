@@ -0,0 +1,269 @@
+package driftq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// topicsServer keeps topics in memory and serves the admin topic endpoints EnsureTopics uses
+type topicsServer struct {
+	mu     sync.Mutex
+	topics map[string]TopicDescription
+	calls  []string
+
+	// raceCreate makes the first create of this name answer 409 after creating it "elsewhere"
+	raceCreate string
+	// noDescribe answers 404 to every describe, like servers that predate the endpoint
+	noDescribe bool
+}
+
+func (s *topicsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = append(s.calls, r.Method+" "+r.URL.Path)
+	rest := strings.TrimPrefix(r.URL.Path, "/v1/topics")
+	name, sub, _ := strings.Cut(strings.TrimPrefix(rest, "/"), "/")
+
+	notFound := func() {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(ErrorResponse{Error: "NOT_FOUND"})
+	}
+
+	switch {
+	case rest == "" && r.Method == http.MethodPost:
+		var req TopicsCreateRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Name == s.raceCreate {
+			s.raceCreate = ""
+			s.topics[req.Name] = TopicDescription{Name: req.Name, Partitions: 1, Config: TopicConfig{RetentionMs: 1}}
+		}
+		if _, ok := s.topics[req.Name]; ok {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		d := TopicDescription{Name: req.Name, Partitions: max(req.Partitions, 1)}
+		if req.Config != nil {
+			d.Config = *req.Config
+		}
+		s.topics[req.Name] = d
+		_ = json.NewEncoder(w).Encode(TopicsCreateResponse{Status: "created", Name: req.Name, Partitions: d.Partitions})
+
+	case rest == "" && r.Method == http.MethodGet:
+		out := TopicsListResponse{Topics: []Topic{}}
+		for n := range s.topics {
+			out.Topics = append(out.Topics, Topic{Name: n})
+		}
+		_ = json.NewEncoder(w).Encode(out)
+
+	case sub == "" && r.Method == http.MethodGet:
+		d, ok := s.topics[name]
+		if !ok || s.noDescribe {
+			notFound()
+			return
+		}
+		_ = json.NewEncoder(w).Encode(d)
+
+	case sub == "partitions":
+		var req TopicsAddPartitionsRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		d := s.topics[name]
+		d.Partitions = req.Partitions
+		s.topics[name] = d
+		_ = json.NewEncoder(w).Encode(d)
+
+	case sub == "config":
+		var upd TopicConfigUpdate
+		_ = json.NewDecoder(r.Body).Decode(&upd)
+		d := s.topics[name]
+		if upd.RetentionMs != nil {
+			d.Config.RetentionMs = *upd.RetentionMs
+		}
+		if upd.DefaultLeaseMs != nil {
+			d.Config.DefaultLeaseMs = *upd.DefaultLeaseMs
+		}
+		s.topics[name] = d
+		_ = json.NewEncoder(w).Encode(d)
+
+	default:
+		notFound()
+	}
+}
+
+func (s *topicsServer) mutations() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, c := range s.calls {
+		if !strings.HasPrefix(c, http.MethodGet) {
+			n++
+		}
+	}
+	return n
+}
+
+func newTopicsServer(t *testing.T, existing ...TopicDescription) (*topicsServer, *Admin) {
+	t.Helper()
+
+	ts := &topicsServer{topics: map[string]TopicDescription{}}
+	for _, d := range existing {
+		ts.topics[d.Name] = d
+	}
+	srv := httptest.NewServer(ts)
+	t.Cleanup(srv.Close)
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL, Retry: RetryConfig{MaxAttempts: 1}})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	return ts, c.Admin()
+}
+
+func TestEnsureTopics_CreatesAndReportsDrift(t *testing.T) {
+	ts, a := newTopicsServer(t,
+		TopicDescription{Name: "same", Partitions: 2, Config: TopicConfig{RetentionMs: 100}},
+		TopicDescription{Name: "drift", Partitions: 4, Config: TopicConfig{RetentionMs: 100}},
+	)
+	ts.raceCreate = "raced"
+
+	specs := []TopicSpec{
+		{Name: "new", Partitions: 3},
+		{Name: "same", Partitions: 2, Config: &TopicConfig{RetentionMs: 100}},
+		{Name: "drift", Partitions: 2, Config: &TopicConfig{RetentionMs: 200}},
+		{Name: "raced", Partitions: 1},
+	}
+
+	res, err := a.EnsureTopics(context.Background(), specs)
+	if err != nil {
+		t.Fatalf("EnsureTopics: %v", err)
+	}
+
+	got := map[string]TopicEnsureResult{}
+	for _, tr := range res.Topics {
+		got[tr.Name] = tr
+	}
+	if got["new"].Action != TopicCreated || ts.topics["new"].Partitions != 3 {
+		t.Fatalf("new: %#v", got["new"])
+	}
+	if got["same"].Action != TopicUnchanged || got["raced"].Action != TopicUnchanged {
+		t.Fatalf("expected same/raced unchanged, got %#v / %#v", got["same"], got["raced"])
+	}
+
+	want := []TopicDrift{
+		{Field: "partitions", Want: 2, Have: 4, Fixable: false},
+		{Field: "retention_ms", Want: 200, Have: 100, Fixable: true},
+	}
+	if d := got["drift"]; d.Action != TopicDrifted || len(d.Drift) != 2 || d.Drift[0] != want[0] || d.Drift[1] != want[1] {
+		t.Fatalf("drift: %#v", d)
+	}
+	if ts.topics["drift"].Config.RetentionMs != 100 {
+		t.Fatalf("DriftReport must not change the topic")
+	}
+
+	// Second run is a no-op apart from the reported drift
+	before := ts.mutations()
+	res, err = a.EnsureTopics(context.Background(), specs)
+	if err != nil || res.Changed() || len(res.Drifted()) != 1 || ts.mutations() != before {
+		t.Fatalf("second run: changed=%v drifted=%d err=%v", res.Changed(), len(res.Drifted()), err)
+	}
+}
+
+func TestEnsureTopics_Policies(t *testing.T) {
+	ctx := context.Background()
+	specs := []TopicSpec{{Name: "orders", Partitions: 6, Config: &TopicConfig{RetentionMs: 200, DefaultLeaseMs: 30000}}}
+	existing := TopicDescription{Name: "orders", Partitions: 3, Config: TopicConfig{RetentionMs: 100}}
+
+	ts, a := newTopicsServer(t, existing)
+	res, err := a.EnsureTopicsWithOptions(ctx, specs, EnsureTopicsOptions{Drift: DriftFail})
+	if !errors.Is(err, ErrTopicDrift) || res.Topics[0].Action != TopicDrifted || ts.mutations() != 0 {
+		t.Fatalf("DriftFail: res=%#v err=%v", res, err)
+	}
+
+	res, err = a.EnsureTopicsWithOptions(ctx, specs, EnsureTopicsOptions{Drift: DriftReconcile, DryRun: true})
+	if err != nil || res.Topics[0].Action != TopicReconciled || !res.Changed() || ts.mutations() != 0 {
+		t.Fatalf("dry run: res=%#v err=%v", res, err)
+	}
+
+	res, err = a.EnsureTopicsWithOptions(ctx, specs, EnsureTopicsOptions{Drift: DriftReconcile})
+	if err != nil || res.Topics[0].Action != TopicReconciled {
+		t.Fatalf("reconcile: res=%#v err=%v", res, err)
+	}
+	if d := ts.topics["orders"]; d.Partitions != 6 || d.Config.RetentionMs != 200 || d.Config.DefaultLeaseMs != 30000 {
+		t.Fatalf("reconcile: topic is %#v", d)
+	}
+
+	res, err = a.EnsureTopicsWithOptions(ctx, specs, EnsureTopicsOptions{Drift: DriftReconcile})
+	if err != nil || res.Topics[0].Action != TopicUnchanged {
+		t.Fatalf("after reconcile: res=%#v err=%v", res, err)
+	}
+
+	if _, err := a.EnsureTopics(ctx, []TopicSpec{{Name: "a"}, {Name: "a"}}); err == nil {
+		t.Fatalf("expected duplicate spec error")
+	}
+}
+
+func TestEnsureTopics_ServerWithoutDescribe(t *testing.T) {
+	ts, a := newTopicsServer(t, TopicDescription{Name: "old", Partitions: 4})
+	ts.noDescribe = true
+	ts.raceCreate = "raced"
+
+	specs := []TopicSpec{{Name: "old", Partitions: 2}, {Name: "raced"}, {Name: "new"}}
+	res, err := a.EnsureTopicsWithOptions(context.Background(), specs, EnsureTopicsOptions{Drift: DriftFail})
+	if err != nil {
+		t.Fatalf("EnsureTopics: %v", err)
+	}
+
+	want := []TopicAction{TopicUnchanged, TopicUnchanged, TopicCreated}
+	for i, tr := range res.Topics {
+		if tr.Action != want[i] || tr.Err != nil {
+			t.Fatalf("%s: expected %s, got %#v", tr.Name, want[i], tr)
+		}
+	}
+
+	// "old" is listed, so only raced and new are created
+	if n := ts.mutations(); n != 2 {
+		t.Fatalf("expected creates for raced and new only, got calls %v", ts.calls)
+	}
+}
+
+func TestParseTopicSpecs(t *testing.T) {
+	yamlSpec := `
+topics:
+  - name: orders
+    partitions: 3
+    config:
+      retention_ms: 604800000
+  - name: audit
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "topics.yaml")
+	if err := os.WriteFile(path, []byte(yamlSpec), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	specs, err := LoadTopicSpecs(path)
+	if err != nil {
+		t.Fatalf("LoadTopicSpecs: %v", err)
+	}
+	if len(specs) != 2 || specs[0].Partitions != 3 || specs[0].Config.RetentionMs != 604800000 || specs[1].Name != "audit" {
+		t.Fatalf("unexpected specs %#v", specs)
+	}
+
+	specs, err = ParseTopicSpecs([]byte(`[{"name":"orders","partitions":2}]`))
+	if err != nil || len(specs) != 1 || specs[0].Partitions != 2 {
+		t.Fatalf("JSON list: %#v err=%v", specs, err)
+	}
+
+	if _, err := ParseTopicSpecs([]byte("topics:\n  - name: x\n    partitons: 2\n")); err == nil {
+		t.Fatalf("expected unknown key error")
+	}
+}
@@ -1,9 +1,131 @@
 package driftq
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 var (
 	// These are some common typed errors (expand as real APIs land)
 	ErrTopicNotFound     = errors.New("topic not found")
+	ErrTopicExists       = errors.New("topic already exists")
+	ErrGroupNotFound     = errors.New("consumer group not found")
+	ErrTopicDrift        = errors.New("topic differs from spec")
+	ErrConflict          = errors.New("conflict")
 	ErrBrokerUnavailable = errors.New("broker unavailable")
+	ErrDeadlineExpired   = errors.New("envelope deadline expired")
+	ErrNoRoute           = errors.New("no route for message")
+	ErrNoStepContext     = errors.New("no step context in ctx")
+	ErrPoisonMessage     = errors.New("poison message")
+)
+
+// mapStatusErr wraps an *APIError with the sentinel registered for its status,
+// so callers can use errors.Is(err, ErrTopicNotFound) and still errors.As the *APIError
+func mapStatusErr(err error, byStatus map[int]error) error {
+	var ae *APIError
+	if !errors.As(err, &ae) {
+		return err
+	}
+	if sentinel, ok := byStatus[ae.Status]; ok {
+		return fmt.Errorf("%w: %w", sentinel, err)
+	}
+	return err
+}
+
+// ---- Handler error wrappers ----
+// A StepHandler can return these to tell the Worker what to do instead of a plain Nack.
+// The Worker hands the wrapped cause (not the wrapper) to NackReason.
+
+// PermanentError marks a failure that can never succeed (validation, unknown route, ...)
+type PermanentError struct{ Err error }
+
+func (e *PermanentError) Error() string { return "permanent: " + errString(e.Err) }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// Permanent marks err as not worth retrying: the Worker dead-letters the message
+// (when DeadLetter is set) or acks it and reports err via OnError. Permanent(nil) is nil
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent reports whether err (or anything it wraps) was marked Permanent
+func IsPermanent(err error) bool {
+	var pe *PermanentError
+	return errors.As(err, &pe)
+}
+
+// IsTerminal is IsPermanent.
+//
+// Deprecated: use IsPermanent, which matches the Permanent constructor
+func IsTerminal(err error) bool { return IsPermanent(err) }
+
+// RetryAfterError asks for the message to be redelivered no sooner than Delay
+type RetryAfterError struct {
+	Err   error
+	Delay time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("retry after %s: %s", e.Delay, errString(e.Err))
+}
+func (e *RetryAfterError) Unwrap() error { return e.Err }
+
+// RetryAfter nacks the message with a requested redelivery delay (NackRequest.DelayMS)
+func RetryAfter(err error, d time.Duration) error {
+	if d < 0 {
+		d = 0
+	}
+	return &RetryAfterError{Err: err, Delay: d}
+}
+
+// SkipError acks a message without treating it as processed (e.g. a duplicate or irrelevant event)
+type SkipError struct{ Err error }
+
+func (e *SkipError) Error() string { return "skip: " + errString(e.Err) }
+func (e *SkipError) Unwrap() error { return e.Err }
+
+// Skip acks the message; err is only informational and may be nil
+func Skip(err error) error {
+	return &SkipError{Err: err}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return "<nil>"
+	}
+	return err.Error()
+}
+
+type handlerOutcome int
+
+const (
+	outcomeNack handlerOutcome = iota
+	outcomeSkip
+	outcomePermanent
+	outcomeRetryAfter
 )
+
+// classifyHandlerErr unwraps the handler error wrappers above.
+// It returns the outcome, the underlying cause and (for RetryAfter) the requested delay
+func classifyHandlerErr(err error) (handlerOutcome, error, time.Duration) {
+	var se *SkipError
+	if errors.As(err, &se) {
+		return outcomeSkip, se.Err, 0
+	}
+
+	var pe *PermanentError
+	if errors.As(err, &pe) {
+		return outcomePermanent, pe.Err, 0
+	}
+
+	var re *RetryAfterError
+	if errors.As(err, &re) {
+		return outcomeRetryAfter, re.Err, re.Delay
+	}
+
+	return outcomeNack, err, 0
+}
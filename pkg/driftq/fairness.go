@@ -0,0 +1,471 @@
+package driftq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// FairnessConfig shares a Worker's Concurrency slots between tenants.
+//
+// Received messages wait in per-tenant queues and free slots go to the tenant with the least
+// weighted service so far (start-time fair queuing), so one busy tenant can't take every slot.
+// Messages over a tenant's rate limit or queue cap are nacked with a delay instead of queued.
+//
+// Throttle nacks don't use up attempts: the worker counts the times it throttled each message
+// (or handed it back unstarted when it stopped) and takes them off ConsumeMessage.Attempts when
+// the message finally runs, so RetryPolicy, DeadLetter and Backoff limits only count handler runs.
+// The count is kept by this Worker only: when the message is redelivered to another worker, or
+// after a restart, those nacks do count as attempts.
+//
+// Tenant metrics carry driftq.tenant.id, one series per tenant ever seen. The metrics SDK keeps
+// them after an idle tenant is forgotten here, so with many or unbounded tenant ids make
+// TenantOf return a bounded set (a plan or tier, a hash bucket)
+type FairnessConfig struct {
+	// TenantOf picks a message's tenant (default: Envelope.TenantID; "" is a tenant too)
+	TenantOf func(msg ConsumeMessage) string
+
+	// Weights gives tenants a bigger share when they compete for slots (default weight 1)
+	Weights map[string]int
+
+	// MaxInFlightPerTenant caps one tenant's running handlers; 0 = no cap beyond Concurrency
+	MaxInFlightPerTenant int
+
+	// MaxQueued is how many received messages may wait for a slot across tenants (default Concurrency).
+	// The worker stops reading the stream while the queues are full
+	MaxQueued int
+
+	// MaxQueuedPerTenant caps one tenant's waiting messages (default MaxQueued/2, at least 1),
+	// so a single tenant can't fill the queues. Messages over it are nacked with ThrottleDelay
+	MaxQueuedPerTenant int
+
+	// Rates are per-tenant token buckets; DefaultRate applies to tenants not listed (nil = unlimited).
+	// Messages over the rate are nacked with a delay until the next token
+	Rates       map[string]TenantRate
+	DefaultRate *TenantRate
+
+	ThrottleDelay time.Duration // nack delay for a full tenant queue (default 1s)
+
+	// TenantIdleTimeout forgets a tenant (stats, throttle counts) once it had nothing queued or
+	// running and a full token bucket for this long (default 5m)
+	TenantIdleTimeout time.Duration
+}
+
+// TenantRate is a token bucket: PerSecond messages on average, up to Burst at once (default ceil(PerSecond))
+type TenantRate struct {
+	PerSecond float64
+	Burst     int
+}
+
+// TenantStats is a snapshot of one tenant's share of a Worker
+type TenantStats struct {
+	Tenant    string
+	Queued    int   // received, waiting for a slot
+	InFlight  int   // handlers running
+	Started   int64 // handlers started since the worker was created
+	Throttled int64 // messages nacked for being over quota
+}
+
+// Throttle reasons (ThrottleEvent.Reason)
+const (
+	ThrottleRate      = "rate"
+	ThrottleQueueFull = "queue_full"
+)
+
+const attrTenantID = "driftq.tenant.id"
+
+func (c FairnessConfig) withDefaults(concurrency int) (FairnessConfig, error) {
+	if c.MaxInFlightPerTenant < 0 || c.MaxQueued < 0 || c.MaxQueuedPerTenant < 0 || c.ThrottleDelay < 0 || c.TenantIdleTimeout < 0 {
+		return c, errors.New("worker: Fairness limits must be >= 0")
+	}
+	for t, wt := range c.Weights {
+		if wt <= 0 {
+			return c, fmt.Errorf("worker: Fairness weight for tenant %q must be > 0", t)
+		}
+	}
+	for t, r := range c.Rates {
+		if r.PerSecond <= 0 || r.Burst < 0 {
+			return c, fmt.Errorf("worker: Fairness rate for tenant %q must have PerSecond > 0", t)
+		}
+	}
+	if r := c.DefaultRate; r != nil && (r.PerSecond <= 0 || r.Burst < 0) {
+		return c, errors.New("worker: Fairness DefaultRate must have PerSecond > 0")
+	}
+
+	if c.TenantOf == nil {
+		c.TenantOf = func(msg ConsumeMessage) string {
+			if msg.Envelope == nil {
+				return ""
+			}
+			return msg.Envelope.TenantID
+		}
+	}
+	if c.MaxQueued == 0 {
+		c.MaxQueued = concurrency
+	}
+	if c.MaxQueuedPerTenant == 0 {
+		c.MaxQueuedPerTenant = max(1, c.MaxQueued/2)
+	}
+	if c.ThrottleDelay == 0 {
+		c.ThrottleDelay = time.Second
+	}
+	if c.TenantIdleTimeout == 0 {
+		c.TenantIdleTimeout = 5 * time.Minute
+	}
+	return c, nil
+}
+
+// fairScheduler holds the per-tenant queues. Tenants are kept until idle for TenantIdleTimeout
+type fairScheduler struct {
+	cfg  FairnessConfig
+	now  func() time.Time
+	wake chan struct{} // a slot was released
+
+	mu      sync.Mutex
+	tenants map[string]*tenantState
+	queued  int
+	vnow    float64   // virtual start time of the last dispatched message
+	swept   time.Time // last evictIdle pass
+}
+
+type tenantState struct {
+	name     string
+	queue    []ConsumeMessage
+	inflight int
+	vt       float64 // virtual time: messages started / weight, caught up to vnow when idle
+	weight   float64
+	seen     time.Time
+
+	rate   *TenantRate
+	tokens float64
+	last   time.Time
+
+	started   int64
+	throttled int64
+	nacked    map[msgPosition]int // throttle nacks per message not yet run
+}
+
+type msgPosition struct {
+	partition int
+	offset    int64
+}
+
+func newFairScheduler(cfg FairnessConfig) *fairScheduler {
+	return &fairScheduler{
+		cfg:     cfg,
+		now:     time.Now,
+		wake:    make(chan struct{}, 1),
+		tenants: map[string]*tenantState{},
+	}
+}
+
+func (s *fairScheduler) tenant(name string) *tenantState {
+	t := s.tenants[name]
+	if t != nil {
+		return t
+	}
+
+	t = &tenantState{name: name, weight: 1, vt: s.vnow, nacked: map[msgPosition]int{}}
+	if wt, ok := s.cfg.Weights[name]; ok {
+		t.weight = float64(wt)
+	}
+	if r, ok := s.cfg.Rates[name]; ok {
+		t.rate = &r
+	} else if s.cfg.DefaultRate != nil {
+		r := *s.cfg.DefaultRate
+		t.rate = &r
+	}
+	if t.rate != nil {
+		if t.rate.Burst == 0 {
+			t.rate.Burst = int(math.Ceil(t.rate.PerSecond))
+		}
+		t.tokens, t.last = float64(t.rate.Burst), s.now()
+	}
+	s.tenants[name] = t
+	return t
+}
+
+// take spends one token, or says how long until one is available
+func (t *tenantState) take(now time.Time) (time.Duration, bool) {
+	if t.rate == nil {
+		return 0, true
+	}
+	t.tokens = min(float64(t.rate.Burst), t.tokens+now.Sub(t.last).Seconds()*t.rate.PerSecond)
+	t.last = now
+	if t.tokens >= 1 {
+		t.tokens--
+		return 0, true
+	}
+	wait := time.Duration((1 - t.tokens) / t.rate.PerSecond * float64(time.Second))
+	return max(wait, time.Millisecond), false
+}
+
+// admit queues msg for tenant, or returns why (and for how long) it must be throttled
+func (s *fairScheduler) admit(tenant string, msg ConsumeMessage) (reason string, delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.evictIdle(now)
+
+	t := s.tenant(tenant)
+	t.seen = now
+	pos := msgPosition{msg.Partition, msg.Offset}
+	if len(t.queue) >= s.cfg.MaxQueuedPerTenant {
+		t.throttled++
+		t.nacked[pos]++
+		return ThrottleQueueFull, s.cfg.ThrottleDelay
+	}
+	if wait, ok := t.take(now); !ok {
+		t.throttled++
+		t.nacked[pos]++
+		return ThrottleRate, wait
+	}
+
+	// The broker counted our throttle nacks as attempts; the handler and retry limits must not.
+	// The count is kept until the message starts, in case it is handed back again (releaseQueued)
+	if n := t.nacked[pos]; n > 0 {
+		msg.Attempts = max(1, msg.Attempts-n)
+	}
+
+	if len(t.queue) == 0 && t.inflight == 0 {
+		// Back from idle: no credit for the time it wasn't competing
+		t.vt = max(t.vt, s.vnow)
+	}
+	t.queue = append(t.queue, msg)
+	s.queued++
+	return "", 0
+}
+
+// evictIdle forgets tenants that have had nothing queued or running for TenantIdleTimeout,
+// so tenants (and their throttle counts for messages that went to other workers) don't pile up.
+// A tenant whose bucket hasn't refilled yet is kept: forgetting it would hand it a fresh burst
+func (s *fairScheduler) evictIdle(now time.Time) {
+	idle := s.cfg.TenantIdleTimeout
+	if now.Sub(s.swept) < idle/2 {
+		return
+	}
+	s.swept = now
+
+	for name, t := range s.tenants {
+		if len(t.queue) > 0 || t.inflight > 0 || now.Sub(t.seen) < idle {
+			continue
+		}
+		if r := t.rate; r != nil && t.tokens+now.Sub(t.last).Seconds()*r.PerSecond < float64(r.Burst) {
+			continue
+		}
+		delete(s.tenants, name)
+	}
+}
+
+// next pops the message of the runnable tenant with the lowest virtual time
+func (s *fairScheduler) next() (ConsumeMessage, string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pick *tenantState
+	for _, t := range s.tenants {
+		if len(t.queue) == 0 || (s.cfg.MaxInFlightPerTenant > 0 && t.inflight >= s.cfg.MaxInFlightPerTenant) {
+			continue
+		}
+		if pick == nil || t.vt < pick.vt || (t.vt == pick.vt && t.name < pick.name) {
+			pick = t
+		}
+	}
+	if pick == nil {
+		return ConsumeMessage{}, "", false
+	}
+
+	msg := pick.queue[0]
+	pick.queue = slices.Delete(pick.queue, 0, 1)
+	delete(pick.nacked, msgPosition{msg.Partition, msg.Offset})
+	s.queued--
+	pick.inflight++
+	pick.started++
+	s.vnow = pick.vt
+	pick.vt += 1 / pick.weight
+	return msg, pick.name, true
+}
+
+// done releases tenant's slot and wakes the dispatcher
+func (s *fairScheduler) done(tenant string) {
+	s.mu.Lock()
+	t := s.tenants[tenant] // never evicted while it has handlers running
+	t.inflight--
+	t.seen = s.now()
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *fairScheduler) full() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queued >= s.cfg.MaxQueued
+}
+
+func (s *fairScheduler) empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queued == 0
+}
+
+// takeQueued empties every queue (the worker is stopping). The nack each message gets
+// is counted like a throttle, so a later Run of this Worker doesn't charge it an attempt
+func (s *fairScheduler) takeQueued() []ConsumeMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []ConsumeMessage
+	for _, t := range s.tenants {
+		for _, m := range t.queue {
+			t.nacked[msgPosition{m.Partition, m.Offset}]++
+		}
+		out = append(out, t.queue...)
+		t.queue = nil
+	}
+	s.queued = 0
+	return out
+}
+
+func (s *fairScheduler) stats() []TenantStats {
+	s.mu.Lock()
+	out := make([]TenantStats, 0, len(s.tenants))
+	for _, t := range s.tenants {
+		out = append(out, TenantStats{Tenant: t.name, Queued: len(t.queue), InFlight: t.inflight, Started: t.started, Throttled: t.throttled})
+	}
+	s.mu.Unlock()
+
+	slices.SortFunc(out, func(a, b TenantStats) int { return strings.Compare(a.Tenant, b.Tenant) })
+	return out
+}
+
+// Tenants reports per-tenant queue depth, in-flight handlers and throttling (nil without Fairness).
+// Tenants idle for FairnessConfig.TenantIdleTimeout are dropped, and their counts with them
+func (w *Worker) Tenants() []TenantStats {
+	if w.fair == nil {
+		return nil
+	}
+	return w.fair.stats()
+}
+
+// fairPump is pump for a Worker with Fairness: messages are read into the tenant queues
+// (while there is room) and started as slots free up
+func (w *Worker) fairPump(ctx, hctx context.Context, msgs <-chan ConsumeMessage, errs <-chan error, sem chan struct{}, wg *sync.WaitGroup) (err error) {
+	// Messages still queued when the stream ends were never started; hand them back right away
+	defer func() {
+		if err != nil {
+			w.releaseQueued(hctx)
+		}
+	}()
+
+	for {
+		w.dispatchFair(ctx, hctx, sem, wg)
+
+		if msgs == nil && w.fair.empty() {
+			// The stream closed and everything it delivered has been started
+			return nil
+		}
+		in := msgs
+		if w.fair.full() {
+			in = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errWorkerStopped
+
+		case <-w.stop:
+			return errWorkerStopped
+
+		case <-w.fair.wake:
+
+		case err, ok := <-errs:
+			if !ok || err == nil {
+				errs = nil
+				continue
+			}
+
+			w.report(err)
+			return err
+
+		case m, ok := <-in:
+			if !ok {
+				// ConsumeStream buffers its error before closing msgs; don't lose it to select order
+				select {
+				case err := <-errs:
+					if err != nil {
+						w.report(err)
+						return err
+					}
+				default:
+				}
+				msgs, errs = nil, nil
+				continue
+			}
+
+			w.hookMessageReceived(ctx, m)
+			w.admitFair(ctx, hctx, m, wg)
+		}
+	}
+}
+
+func (w *Worker) admitFair(ctx, hctx context.Context, m ConsumeMessage, wg *sync.WaitGroup) {
+	tenant := w.fair.cfg.TenantOf(m)
+	reason, delay := w.fair.admit(tenant, m)
+	if reason == "" {
+		w.metrics.tenantQueued.Add(ctx, 1, w.metricAttrs(attribute.String(attrTenantID, tenant)))
+		return
+	}
+
+	w.metrics.tenantThrottled.Add(ctx, 1, w.metricAttrs(
+		attribute.String(attrTenantID, tenant), attribute.String("driftq.throttle.reason", reason)))
+
+	// Nack off the pump goroutine; Run's WaitGroup keeps the worker from returning before it's sent
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		actx := context.WithoutCancel(hctx)
+		ev := w.receivedEvent(m)
+		w.hookThrottle(actx, ThrottleEvent{MessageEvent: ev, Tenant: tenant, Reason: reason, Delay: delay})
+		w.nack(actx, ev, fmt.Sprintf("tenant %q throttled: %s", tenant, reason), delay)
+	}()
+}
+
+func (w *Worker) dispatchFair(ctx, hctx context.Context, sem chan struct{}, wg *sync.WaitGroup) {
+	for {
+		select {
+		case sem <- struct{}{}:
+		default:
+			return
+		}
+
+		m, tenant, ok := w.fair.next()
+		if !ok {
+			<-sem
+			return
+		}
+		w.metrics.tenantQueued.Add(ctx, -1, w.metricAttrs(attribute.String(attrTenantID, tenant)))
+		w.launch(ctx, hctx, m, sem, wg, func() { w.fair.done(tenant) })
+	}
+}
+
+// releaseQueued nacks (without delay) every message that was queued but not started.
+// Only this Worker discounts that nack; redelivered elsewhere it costs the message an attempt
+func (w *Worker) releaseQueued(hctx context.Context) {
+	actx := context.WithoutCancel(hctx)
+	for _, m := range w.fair.takeQueued() {
+		w.metrics.tenantQueued.Add(actx, -1, w.metricAttrs(attribute.String(attrTenantID, w.fair.cfg.TenantOf(m))))
+		w.nack(actx, w.receivedEvent(m), "worker stopped before handling", 0)
+	}
+}
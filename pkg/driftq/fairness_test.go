@@ -0,0 +1,297 @@
+package driftq
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func tenantLine(offset int, tenant, value string) string {
+	return fmt.Sprintf(`{"partition":0,"offset":%d,"attempts":1,"value":%q,"envelope":{"tenant_id":%q}}`, offset, value, tenant)
+}
+
+func TestWorker_FairnessInterleavesTenants(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 6; i++ {
+		lines = append(lines, tenantLine(i, "noisy", fmt.Sprintf("n%d", i)))
+	}
+	lines = append(lines, tenantLine(7, "quiet", "q1"), tenantLine(8, "quiet", "q2"))
+
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(lines...))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var (
+		mu    sync.Mutex
+		order []string
+		wk    *Worker
+	)
+	wk, err = NewWorker(WorkerConfig{
+		Client:   c,
+		Consume:  ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		Fairness: &FairnessConfig{MaxQueued: 8, MaxQueuedPerTenant: 8},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			mu.Lock()
+			order = append(order, msg.Value)
+			first := len(order) == 1
+			mu.Unlock()
+
+			// Hold the only slot until the rest of the stream is queued, so the scheduler has a choice
+			for first {
+				queued := 0
+				for _, ts := range wk.Tenants() {
+					queued += ts.Queued
+				}
+				if queued == 7 {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	want := []string{"n1", "q1", "n2", "q2", "n3", "n4", "n5", "n6"}
+	if fmt.Sprint(order) != fmt.Sprint(want) {
+		t.Fatalf("expected tenants to take turns: got %v, want %v", order, want)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.acks) != 8 || len(s.nacks) != 0 {
+		t.Fatalf("expected every message acked, got %d acks / %d nacks", len(s.acks), len(s.nacks))
+	}
+
+	stats := wk.Tenants()
+	if len(stats) != 2 || stats[0].Tenant != "noisy" || stats[0].Started != 6 || stats[1].Started != 2 || stats[0].Queued+stats[1].Queued != 0 {
+		t.Fatalf("unexpected tenant stats %+v", stats)
+	}
+}
+
+func TestWorker_FairnessThrottlesOverQuota(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 5; i++ {
+		lines = append(lines, tenantLine(i, "noisy", "v"))
+	}
+	lines = append(lines, tenantLine(6, "other", "v"))
+
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(lines...))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var (
+		mu        sync.Mutex
+		throttled []ThrottleEvent
+	)
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		Fairness: &FairnessConfig{
+			MaxQueued: 4,
+			Rates:     map[string]TenantRate{"noisy": {PerSecond: 1, Burst: 2}},
+		},
+		Hooks: WorkerHooks{
+			OnThrottle: func(ctx context.Context, ev ThrottleEvent) {
+				mu.Lock()
+				throttled = append(throttled, ev)
+				mu.Unlock()
+			},
+		},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error { return nil }),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.acks) != 3 || len(s.nacks) != 3 {
+		t.Fatalf("expected the burst (2) and the other tenant acked, the rest nacked: %d acks / %d nacks", len(s.acks), len(s.nacks))
+	}
+	for _, n := range s.nacks {
+		if n.DelayMS <= 0 || n.DelayMS > 1000 {
+			t.Fatalf("expected a nack delay until the next token, got %#v", n)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(throttled) != 3 || throttled[0].Tenant != "noisy" || throttled[0].Reason != ThrottleRate {
+		t.Fatalf("unexpected throttle events %+v", throttled)
+	}
+
+	for _, ts := range wk.Tenants() {
+		if ts.Tenant == "noisy" && (ts.Throttled != 3 || ts.Started != 2) {
+			t.Fatalf("unexpected noisy stats %+v", ts)
+		}
+	}
+
+	if _, err := NewWorker(WorkerConfig{
+		Client:   c,
+		Consume:  ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		Handler:  StepFunc(func(ctx context.Context, msg ConsumeMessage) error { return nil }),
+		Fairness: &FairnessConfig{Weights: map[string]int{"a": 0}},
+	}); err == nil {
+		t.Fatalf("expected a zero weight to be rejected")
+	}
+}
+
+func TestWorker_FairnessThrottleKeepsAttempts(t *testing.T) {
+	line := func(offset, attempts int) string {
+		return fmt.Sprintf(`{"partition":0,"offset":%d,"attempts":%d,"value":"v","envelope":{"tenant_id":"a"}}`, offset, attempts)
+	}
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(line(1, 1), line(2, 1), line(2, 2), line(2, 3)))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var (
+		mu       sync.Mutex
+		clock    = time.Unix(1000, 0)
+		attempts []int
+	)
+	wk, err := NewWorker(WorkerConfig{
+		Client:     c,
+		Consume:    ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		DeadLetter: &DeadLetterConfig{Topic: "demo.dlq", MaxAttempts: 3},
+		Fairness: &FairnessConfig{
+			DefaultRate: &TenantRate{PerSecond: 1, Burst: 1},
+			TenantOf: func(msg ConsumeMessage) string {
+				// The third delivery of offset 2 comes after the bucket refilled
+				if msg.Offset == 2 && msg.Attempts == 3 {
+					mu.Lock()
+					clock = clock.Add(time.Second)
+					mu.Unlock()
+				}
+				return msg.Envelope.TenantID
+			},
+		},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			mu.Lock()
+			attempts = append(attempts, msg.Attempts)
+			mu.Unlock()
+			if msg.Offset == 2 {
+				return fmt.Errorf("boom")
+			}
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+	wk.fair.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return clock
+	}
+
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if fmt.Sprint(attempts) != "[1 1]" {
+		t.Fatalf("expected the handler to see a first attempt for both offsets, got %v", attempts)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.produced) != 0 {
+		t.Fatalf("throttle nacks used up MaxAttempts: dead-lettered %#v", s.produced)
+	}
+	if len(s.nacks) != 3 || !strings.HasSuffix(s.nacks[0].Reason, ThrottleRate) || s.nacks[2].Reason != "boom" {
+		t.Fatalf("expected two throttles then a handler nack, got %#v", s.nacks)
+	}
+}
+
+func TestFairScheduler_EvictsIdleTenants(t *testing.T) {
+	now := time.Unix(1000, 0)
+	fc, err := FairnessConfig{
+		MaxQueued:          8,
+		MaxQueuedPerTenant: 8,
+		Rates:              map[string]TenantRate{"a": {PerSecond: 0.001, Burst: 5}},
+	}.withDefaults(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newFairScheduler(fc)
+	s.now = func() time.Time { return now }
+
+	for i := range 5 {
+		if reason, _ := s.admit("a", ConsumeMessage{Offset: int64(i)}); reason != "" {
+			t.Fatalf("admit %d: throttled (%s)", i, reason)
+		}
+	}
+	s.admit("b", ConsumeMessage{Offset: 9})
+	for range 6 {
+		if _, _, ok := s.next(); !ok {
+			t.Fatal("expected a queued message")
+		}
+	}
+	s.done("b")
+	for range 5 {
+		s.done("a")
+	}
+
+	// Idle long enough, but a's bucket is still short of a full burst
+	now = now.Add(fc.TenantIdleTimeout)
+	s.admit("c", ConsumeMessage{})
+	if got := s.stats(); len(got) != 2 || got[0].Tenant != "a" || got[1].Tenant != "c" {
+		t.Fatalf("expected b evicted and a kept, got %+v", got)
+	}
+}
+
+func TestFairScheduler_ReleasedMessagesKeepAttempts(t *testing.T) {
+	fc, err := FairnessConfig{MaxQueued: 4, MaxQueuedPerTenant: 4}.withDefaults(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newFairScheduler(fc)
+
+	s.admit("a", ConsumeMessage{Offset: 1, Attempts: 1})
+	if got := s.takeQueued(); len(got) != 1 {
+		t.Fatalf("expected the queued message back, got %v", got)
+	}
+
+	// The stop's nack made the broker count an attempt; the next Run doesn't
+	s.admit("a", ConsumeMessage{Offset: 1, Attempts: 2})
+	msg, _, ok := s.next()
+	if !ok || msg.Attempts != 1 {
+		t.Fatalf("expected attempt 1 after the release, got %+v", msg)
+	}
+	s.done("a")
+
+	// Once started, the message is charged normally again
+	s.admit("a", ConsumeMessage{Offset: 1, Attempts: 3})
+	if msg, _, _ := s.next(); msg.Attempts != 3 {
+		t.Fatalf("expected the handler nack to count, got %+v", msg)
+	}
+}
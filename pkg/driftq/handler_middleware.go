@@ -0,0 +1,163 @@
+package driftq
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// HandlerMiddleware wraps a StepHandler (the handler-side twin of RoundTripperMiddleware)
+type HandlerMiddleware func(next StepHandler) StepHandler
+
+// ChainHandler applies middleware in the order provided:
+// outermost is the first middleware in the list.
+func ChainHandler(h StepHandler, mws ...HandlerMiddleware) StepHandler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		if mws[i] == nil {
+			continue
+		}
+		h = mws[i](h)
+	}
+	return h
+}
+
+// ---- Recover middleware ----
+
+// PanicError is returned by RecoverHandler when the wrapped handler panics
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	if e == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("handler panic: %v\n%s", e.Value, e.Stack)
+}
+
+// RecoverHandler turns a handler panic into a *PanicError, so the message is nacked
+// (with the stack trace in the reason) instead of taking the process down
+func RecoverHandler() HandlerMiddleware {
+	return func(next StepHandler) StepHandler {
+		return StepFunc(func(ctx context.Context, msg ConsumeMessage) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					err = &PanicError{Value: v, Stack: debug.Stack()}
+				}
+			}()
+			return next.Handle(ctx, msg)
+		})
+	}
+}
+
+// ---- Timeout middleware ----
+
+// TimeoutHandler bounds each Handle call to d (an earlier ctx deadline still wins).
+// d <= 0 disables it
+func TimeoutHandler(d time.Duration) HandlerMiddleware {
+	return func(next StepHandler) StepHandler {
+		if d <= 0 {
+			return next
+		}
+		return StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next.Handle(ctx, msg)
+		})
+	}
+}
+
+// ---- Logging middleware ----
+
+// LoggingHandler logs the outcome of every Handle call.
+// Successes are logged at Debug, failures at Warn. A nil logger uses slog.Default()
+func LoggingHandler(logger *slog.Logger) HandlerMiddleware {
+	return func(next StepHandler) StepHandler {
+		return StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			l := logger
+			if l == nil {
+				l = slog.Default()
+			}
+
+			start := time.Now()
+			err := next.Handle(ctx, msg)
+
+			attrs := []slog.Attr{
+				slog.Int("partition", msg.Partition),
+				slog.Int64("offset", msg.Offset),
+				slog.Int("attempts", msg.Attempts),
+				slog.String("key", msg.Key),
+				slog.Duration("duration", time.Since(start)),
+			}
+
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+				l.LogAttrs(ctx, slog.LevelWarn, "driftq handler failed", attrs...)
+				return err
+			}
+
+			l.LogAttrs(ctx, slog.LevelDebug, "driftq handler done", attrs...)
+			return nil
+		})
+	}
+}
+
+// ---- Deadline-expired middleware ----
+
+type DeadlineExpiredPolicy int
+
+const (
+	// ExpiredAck acks (drops) a message whose envelope deadline already passed, without calling the handler
+	ExpiredAck DeadlineExpiredPolicy = iota
+	// ExpiredNack nacks it with ErrDeadlineExpired as the reason
+	ExpiredNack
+	// ExpiredSkip acks it as skipped (Skip(ErrDeadlineExpired)): not counted as processed
+	ExpiredSkip
+	// ExpiredDeadLetter fails it permanently, so a Worker with DeadLetter parks it on the DLQ
+	ExpiredDeadLetter
+)
+
+func (p DeadlineExpiredPolicy) String() string {
+	switch p {
+	case ExpiredAck:
+		return "ack"
+	case ExpiredNack:
+		return "nack"
+	case ExpiredSkip:
+		return "skip"
+	case ExpiredDeadLetter:
+		return "dead_letter"
+	}
+	return "unknown"
+}
+
+// err is what a handler returns to settle an expired message under p
+func (p DeadlineExpiredPolicy) err() error {
+	switch p {
+	case ExpiredNack:
+		return ErrDeadlineExpired
+	case ExpiredSkip:
+		return Skip(ErrDeadlineExpired)
+	case ExpiredDeadLetter:
+		return Permanent(ErrDeadlineExpired)
+	}
+	return nil
+}
+
+// DeadlineExpiredHandler skips messages whose Envelope.Deadline is already in the past.
+// Messages without a deadline are always handled. WorkerConfig.Expired does the same
+// before any middleware runs, and also reports it (metrics, OnExpired)
+func DeadlineExpiredHandler(policy DeadlineExpiredPolicy) HandlerMiddleware {
+	return func(next StepHandler) StepHandler {
+		return StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			dl := envelopeDeadline(msg)
+			if dl.IsZero() || time.Now().Before(dl) {
+				return next.Handle(ctx, msg)
+			}
+			return policy.err()
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package driftq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChainHandler_OrderOutermostFirst(t *testing.T) {
+	var order []string
+
+	mw := func(name string) HandlerMiddleware {
+		return func(next StepHandler) StepHandler {
+			return StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+				order = append(order, name)
+				return next.Handle(ctx, msg)
+			})
+		}
+	}
+
+	h := ChainHandler(StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+		order = append(order, "handler")
+		return nil
+	}), mw("a"), mw("b"))
+
+	if err := h.Handle(context.Background(), ConsumeMessage{}); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	if got := strings.Join(order, ","); got != "a,b,handler" {
+		t.Fatalf("unexpected order: %s", got)
+	}
+}
+
+func TestRecoverHandler_ConvertsPanic(t *testing.T) {
+	h := ChainHandler(StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+		panic("kaboom")
+	}), RecoverHandler())
+
+	err := h.Handle(context.Background(), ConsumeMessage{})
+
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected *PanicError, got %v", err)
+	}
+
+	if pe.Value != "kaboom" || len(pe.Stack) == 0 {
+		t.Fatalf("unexpected panic error: %#v", pe)
+	}
+
+	if !strings.Contains(err.Error(), "kaboom") || !strings.Contains(err.Error(), "goroutine") {
+		t.Fatalf("expected panic value and stack in error, got %q", err.Error())
+	}
+}
+
+func TestTimeoutHandler_SetsDeadline(t *testing.T) {
+	h := ChainHandler(StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Fatalf("expected ctx deadline")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}), TimeoutHandler(20*time.Millisecond))
+
+	if err := h.Handle(context.Background(), ConsumeMessage{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestLoggingHandler_LogsFailures(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	h := ChainHandler(StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+		return errors.New("boom")
+	}), LoggingHandler(logger))
+
+	_ = h.Handle(context.Background(), ConsumeMessage{Partition: 3, Offset: 42})
+
+	out := buf.String()
+	if !strings.Contains(out, "level=WARN") || !strings.Contains(out, "offset=42") || !strings.Contains(out, "error=boom") {
+		t.Fatalf("unexpected log output: %s", out)
+	}
+}
+
+func TestDeadlineExpiredHandler_Policies(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Minute)
+
+	var called int
+	next := StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+		called++
+		return nil
+	})
+
+	expired := ConsumeMessage{Envelope: &Envelope{Deadline: &past}}
+	fresh := ConsumeMessage{Envelope: &Envelope{Deadline: &future}}
+
+	if err := ChainHandler(next, DeadlineExpiredHandler(ExpiredAck)).Handle(context.Background(), expired); err != nil {
+		t.Fatalf("ExpiredAck: expected nil, got %v", err)
+	}
+
+	if err := ChainHandler(next, DeadlineExpiredHandler(ExpiredNack)).Handle(context.Background(), expired); !errors.Is(err, ErrDeadlineExpired) {
+		t.Fatalf("ExpiredNack: expected ErrDeadlineExpired, got %v", err)
+	}
+
+	if called != 0 {
+		t.Fatalf("handler should not run for expired messages, ran %d times", called)
+	}
+
+	if err := ChainHandler(next, DeadlineExpiredHandler(ExpiredNack)).Handle(context.Background(), fresh); err != nil || called != 1 {
+		t.Fatalf("fresh message: err=%v called=%d", err, called)
+	}
+}
@@ -0,0 +1,200 @@
+package driftq
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// WorkerHooks are lifecycle callbacks for dashboards, audit logs and tests.
+// Every hook is optional. Hooks run synchronously on the goroutine doing the work, so keep them fast;
+// a panicking hook is recovered and reported via OnError instead of crashing the worker
+type WorkerHooks struct {
+	OnStreamOpen      func(ctx context.Context, ev StreamEvent)
+	OnMessageReceived func(ctx context.Context, ev MessageEvent)
+	OnHandlerStart    func(ctx context.Context, ev MessageEvent)
+	OnHandlerEnd      func(ctx context.Context, ev HandlerEndEvent)
+	OnAck             func(ctx context.Context, ev SettleEvent)
+	OnNack            func(ctx context.Context, ev SettleEvent)
+	OnDeadLetter      func(ctx context.Context, ev SettleEvent)
+	OnSettled         func(ctx context.Context, ev SettledEvent)
+	OnThrottle        func(ctx context.Context, ev ThrottleEvent)
+	OnExpired         func(ctx context.Context, ev ExpiredEvent)
+	OnQuarantine      func(ctx context.Context, ev QuarantineEvent)
+	OnReconnect       func(ctx context.Context, ev ReconnectEvent)
+	OnShutdown        func(ctx context.Context, res DrainResult)
+}
+
+// StreamEvent describes a consume stream the worker opened
+type StreamEvent struct {
+	Topic string
+	Group string
+	Owner string
+	Opens int // 1 for the first stream, 2+ after reconnects
+}
+
+// MessageEvent identifies a message and the deadline its handler runs under
+type MessageEvent struct {
+	Topic    string
+	Group    string
+	Owner    string
+	Message  ConsumeMessage
+	Deadline time.Time // handler ctx deadline derived from the envelope; zero = none
+}
+
+type HandlerEndEvent struct {
+	MessageEvent
+	Duration time.Duration
+	Err      error // what the handler returned
+}
+
+// SettleEvent reports an Ack, Nack or dead-letter call and its result
+type SettleEvent struct {
+	MessageEvent
+	Reason          string        // Nack / dead-letter reason
+	Delay           time.Duration // requested redelivery delay (Nack)
+	DeadLetterTopic string        // OnDeadLetter only
+	Err             error         // nil when the call succeeded
+}
+
+// SettledEvent reports how the Worker finally dealt with a delivery, once the Ack, Nack or
+// dead-letter has gone out. Status is what a status update would carry (see StatusConfig),
+// so it reflects OnExhausted, Skip, ExpiredConfig and PoisonConfig, not just the handler error
+type SettledEvent struct {
+	MessageEvent
+	Step   *StepContext
+	Status StepStatus // never StepStarted
+	Reason string     // nack, dead-letter or quarantine reason
+	Err    error      // the handler (or expiry / poison) error; nil on success
+}
+
+// ThrottleEvent reports a message nacked for being over its tenant's quota (see FairnessConfig)
+type ThrottleEvent struct {
+	MessageEvent
+	Tenant string
+	Reason string        // ThrottleRate or ThrottleQueueFull
+	Delay  time.Duration // redelivery delay asked for
+}
+
+// ExpiredEvent reports a message settled without handling because its envelope deadline passed (see ExpiredConfig)
+type ExpiredEvent struct {
+	MessageEvent
+	Deadline time.Time // the envelope deadline
+	Policy   DeadlineExpiredPolicy
+}
+
+// ReconnectEvent reports a Worker opening its consume stream again (Run called after it returned)
+type ReconnectEvent struct {
+	Topic string
+	Group string
+	Owner string
+	Opens int   // streams opened so far, this one included
+	Err   error // what the previous Run returned (nil = the server closed the stream, or a stop)
+}
+
+func (w *Worker) messageEvent(ctx context.Context, msg ConsumeMessage) MessageEvent {
+	ev := MessageEvent{Topic: w.opt.Topic, Group: w.opt.Group, Owner: w.opt.Owner, Message: msg}
+	if dl, ok := ctx.Deadline(); ok {
+		ev.Deadline = dl
+	}
+	return ev
+}
+
+// receivedEvent is messageEvent for a message that has no handler ctx yet: the deadline is the envelope's
+func (w *Worker) receivedEvent(msg ConsumeMessage) MessageEvent {
+	return MessageEvent{Topic: w.opt.Topic, Group: w.opt.Group, Owner: w.opt.Owner, Message: msg, Deadline: envelopeDeadline(msg)}
+}
+
+// callHook runs fn, turning a panic into a reported error
+func (w *Worker) callHook(ctx context.Context, name string, fn func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			err := fmt.Errorf("worker: %s hook panicked: %v", name, v)
+			w.log.LogAttrs(ctx, slog.LevelError, "driftq worker hook panicked",
+				w.logAttrs(slog.String("hook", name), slog.String("error", err.Error()))...)
+			w.report(err)
+		}
+	}()
+	fn()
+}
+
+func (w *Worker) hookStreamOpen(ctx context.Context, opens int) {
+	if h := w.hooks.OnStreamOpen; h != nil {
+		w.callHook(ctx, "OnStreamOpen", func() {
+			h(ctx, StreamEvent{Topic: w.opt.Topic, Group: w.opt.Group, Owner: w.opt.Owner, Opens: opens})
+		})
+	}
+}
+
+func (w *Worker) hookMessageReceived(ctx context.Context, msg ConsumeMessage) {
+	if h := w.hooks.OnMessageReceived; h != nil {
+		w.callHook(ctx, "OnMessageReceived", func() { h(ctx, w.receivedEvent(msg)) })
+	}
+}
+
+func (w *Worker) hookHandlerStart(ctx context.Context, ev MessageEvent) {
+	if h := w.hooks.OnHandlerStart; h != nil {
+		w.callHook(ctx, "OnHandlerStart", func() { h(ctx, ev) })
+	}
+}
+
+func (w *Worker) hookHandlerEnd(ctx context.Context, ev HandlerEndEvent) {
+	if h := w.hooks.OnHandlerEnd; h != nil {
+		w.callHook(ctx, "OnHandlerEnd", func() { h(ctx, ev) })
+	}
+}
+
+func (w *Worker) hookAck(ctx context.Context, ev SettleEvent) {
+	if h := w.hooks.OnAck; h != nil {
+		w.callHook(ctx, "OnAck", func() { h(ctx, ev) })
+	}
+}
+
+func (w *Worker) hookNack(ctx context.Context, ev SettleEvent) {
+	if h := w.hooks.OnNack; h != nil {
+		w.callHook(ctx, "OnNack", func() { h(ctx, ev) })
+	}
+}
+
+func (w *Worker) hookDeadLetter(ctx context.Context, ev SettleEvent) {
+	if h := w.hooks.OnDeadLetter; h != nil {
+		w.callHook(ctx, "OnDeadLetter", func() { h(ctx, ev) })
+	}
+}
+
+func (w *Worker) hookSettled(ctx context.Context, ev SettledEvent) {
+	if h := w.hooks.OnSettled; h != nil {
+		w.callHook(ctx, "OnSettled", func() { h(ctx, ev) })
+	}
+}
+
+func (w *Worker) hookThrottle(ctx context.Context, ev ThrottleEvent) {
+	if h := w.hooks.OnThrottle; h != nil {
+		w.callHook(ctx, "OnThrottle", func() { h(ctx, ev) })
+	}
+}
+
+func (w *Worker) hookExpired(ctx context.Context, ev ExpiredEvent) {
+	if h := w.hooks.OnExpired; h != nil {
+		w.callHook(ctx, "OnExpired", func() { h(ctx, ev) })
+	}
+}
+
+func (w *Worker) hookQuarantine(ctx context.Context, ev QuarantineEvent) {
+	if h := w.hooks.OnQuarantine; h != nil {
+		w.callHook(ctx, "OnQuarantine", func() { h(ctx, ev) })
+	}
+}
+
+func (w *Worker) hookReconnect(ctx context.Context, ev ReconnectEvent) {
+	if h := w.hooks.OnReconnect; h != nil {
+		w.callHook(ctx, "OnReconnect", func() { h(ctx, ev) })
+	}
+}
+
+func (w *Worker) hookShutdown(ctx context.Context, res DrainResult) {
+	if h := w.hooks.OnShutdown; h != nil {
+		w.callHook(ctx, "OnShutdown", func() { h(ctx, res) })
+	}
+}
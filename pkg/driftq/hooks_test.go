@@ -0,0 +1,126 @@
+package driftq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkerHooks_LifecycleAndPanicSafety(t *testing.T) {
+	deadline := time.Now().Add(time.Minute).UTC().Truncate(time.Millisecond)
+
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(
+		`{"partition":0,"offset":1,"attempts":1,"value":"ok","envelope":{"deadline":"`+deadline.Format(time.RFC3339Nano)+`"}}`,
+		`{"partition":0,"offset":2,"attempts":2,"value":"fail"}`,
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var mu sync.Mutex
+	var events []string
+	var reported []error
+	record := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, fmt.Sprintf(format, args...))
+	}
+
+	var receivedDeadline, startDeadline time.Time
+	var endErr error
+
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		OnError: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, err)
+		},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			if msg.Value == "fail" {
+				return errors.New("boom")
+			}
+			return nil
+		}),
+		Hooks: WorkerHooks{
+			OnStreamOpen: func(ctx context.Context, ev StreamEvent) {
+				record("open %s/%s #%d", ev.Topic, ev.Group, ev.Opens)
+			},
+			OnMessageReceived: func(ctx context.Context, ev MessageEvent) {
+				if ev.Message.Offset == 1 {
+					receivedDeadline = ev.Deadline
+				}
+				record("received %d", ev.Message.Offset)
+				panic("hook bug")
+			},
+			OnHandlerStart: func(ctx context.Context, ev MessageEvent) {
+				if ev.Message.Offset == 1 {
+					startDeadline = ev.Deadline
+				}
+				record("start %d attempts=%d", ev.Message.Offset, ev.Message.Attempts)
+			},
+			OnHandlerEnd: func(ctx context.Context, ev HandlerEndEvent) {
+				if ev.Message.Offset == 2 {
+					endErr = ev.Err
+				}
+				record("end %d", ev.Message.Offset)
+			},
+			OnAck: func(ctx context.Context, ev SettleEvent) {
+				record("ack %d err=%v", ev.Message.Offset, ev.Err)
+			},
+			OnNack: func(ctx context.Context, ev SettleEvent) {
+				record("nack %d reason=%s err=%v", ev.Message.Offset, ev.Reason, ev.Err)
+			},
+			OnSettled: func(ctx context.Context, ev SettledEvent) {
+				record("settled %d %s err=%v", ev.Message.Offset, ev.Status, ev.Err)
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+
+	// Run's own deadline must not leak into the events; the handler's is the envelope's (the earlier one)
+	runCtx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	if err := wk.Run(runCtx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	want := []string{
+		"open demo/g #1",
+		"received 1", "start 1 attempts=1", "end 1", "ack 1 err=<nil>", "settled 1 succeeded err=<nil>",
+		"received 2", "start 2 attempts=2", "end 2", "nack 2 reason=boom err=<nil>", "settled 2 nacked err=boom",
+	}
+	if got := strings.Join(events, " | "); got != strings.Join(want, " | ") {
+		t.Fatalf("unexpected hook sequence:\n got: %s\nwant: %s", got, strings.Join(want, " | "))
+	}
+
+	if !startDeadline.Equal(deadline) || !receivedDeadline.Equal(deadline) {
+		t.Fatalf("expected envelope deadline %v, got %v on receive and %v on start", deadline, receivedDeadline, startDeadline)
+	}
+
+	if endErr == nil || endErr.Error() != "boom" {
+		t.Fatalf("expected OnHandlerEnd to see the handler error, got %v", endErr)
+	}
+
+	if len(reported) != 2 || !strings.Contains(reported[0].Error(), "OnMessageReceived hook panicked") {
+		t.Fatalf("expected both hook panics to be reported, got %v", reported)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.acks) != 1 || len(s.nacks) != 1 {
+		t.Fatalf("panicking hook must not stop processing: acks=%d nacks=%d", len(s.acks), len(s.nacks))
+	}
+}
@@ -0,0 +1,110 @@
+package driftq
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// LagMonitorConfig configures a LagMonitor
+type LagMonitorConfig struct {
+	Group    string
+	Topic    string        // optional: only this topic
+	Interval time.Duration // default 10s
+
+	// OnLag receives every successful poll (optional)
+	OnLag func(ctx context.Context, lag GroupLag)
+
+	// OnError receives poll failures; Run keeps polling after them (optional)
+	OnError func(err error)
+}
+
+// GroupLag is one LagMonitor sample
+type GroupLag struct {
+	Group      string
+	Partitions []GroupPartition
+	Total      int64
+	At         time.Time
+}
+
+// LagMonitor polls DescribeGroup on an interval and reports lag to a callback and,
+// when the client has a MeterProvider, to the driftq.consumer.lag gauge
+type LagMonitor struct {
+	a   *Admin
+	cfg LagMonitorConfig
+	now func() time.Time
+}
+
+func (a *Admin) NewLagMonitor(cfg LagMonitorConfig) (*LagMonitor, error) {
+	cfg.Group = strings.TrimSpace(cfg.Group)
+	if cfg.Group == "" {
+		return nil, errors.New("group is required")
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+
+	return &LagMonitor{a: a, cfg: cfg, now: time.Now}, nil
+}
+
+// Poll takes one sample, records it and hands it to OnLag
+func (m *LagMonitor) Poll(ctx context.Context) (GroupLag, error) {
+	d, err := m.a.DescribeGroup(ctx, m.cfg.Group, m.cfg.Topic)
+	if err != nil {
+		return GroupLag{}, err
+	}
+
+	lag := GroupLag{Group: m.cfg.Group, At: m.now()}
+	for _, p := range d.Partitions {
+		if m.cfg.Topic != "" && p.Topic != "" && p.Topic != m.cfg.Topic {
+			continue
+		}
+
+		lag.Partitions = append(lag.Partitions, p)
+		lag.Total += p.Lag
+
+		topic := p.Topic
+		if topic == "" {
+			topic = m.cfg.Topic
+		}
+		m.a.c.metrics.consumerLag.Record(ctx, p.Lag, messagingAttrs(topic,
+			attribute.String(attrMessagingConsumerGroup, m.cfg.Group),
+			attribute.String(attrMessagingPartition, strconv.Itoa(p.Partition)),
+		))
+	}
+
+	if m.cfg.OnLag != nil {
+		m.cfg.OnLag(ctx, lag)
+	}
+	return lag, nil
+}
+
+// Run polls immediately and then every Interval until ctx is done.
+// It returns ctx.Err(); failed polls go to OnError (or the client logger) and do not stop it
+func (m *LagMonitor) Run(ctx context.Context) error {
+	t := time.NewTicker(m.cfg.Interval)
+	defer t.Stop()
+
+	for {
+		if _, err := m.Poll(ctx); err != nil && ctx.Err() == nil {
+			if m.cfg.OnError != nil {
+				m.cfg.OnError(err)
+			} else {
+				m.a.c.log.LogAttrs(ctx, slog.LevelWarn, "driftq lag poll failed",
+					slog.String("group", m.cfg.Group), slog.String("error", err.Error()))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
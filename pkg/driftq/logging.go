@@ -0,0 +1,59 @@
+package driftq
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newLogger wraps the configured logger so every record carries trace_id/span_id from ctx.
+// A nil logger means silent
+func newLogger(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	if _, ok := l.Handler().(traceLogHandler); ok {
+		return l
+	}
+	return slog.New(traceLogHandler{l.Handler()})
+}
+
+// traceLogHandler adds the active span's IDs to each record
+type traceLogHandler struct{ slog.Handler }
+
+func (h traceLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceLogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceLogHandler) WithGroup(name string) slog.Handler {
+	return traceLogHandler{h.Handler.WithGroup(name)}
+}
+
+// messageLogAttrs identifies msg in a log record. The value is only included when
+// Config.LogPayloads is set; otherwise just its size is logged
+func (c *Client) messageLogAttrs(msg ConsumeMessage) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.Int("partition", msg.Partition),
+		slog.Int64("offset", msg.Offset),
+		slog.Int("attempts", msg.Attempts),
+		slog.String("key", msg.Key),
+	}
+
+	if c.cfg.LogPayloads {
+		attrs = append(attrs, slog.String("value", msg.Value))
+	} else {
+		attrs = append(attrs, slog.Int("value_bytes", len(msg.Value)))
+	}
+	return attrs
+}
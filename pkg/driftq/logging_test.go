@@ -0,0 +1,155 @@
+package driftq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// logRecords is a concurrency-safe buffer of JSON log lines
+type logRecords struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *logRecords) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+func (l *logRecords) find(t *testing.T, msg string) map[string]any {
+	t.Helper()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, line := range strings.Split(strings.TrimSpace(l.buf.String()), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			continue
+		}
+		if rec["msg"] == msg {
+			return rec
+		}
+	}
+	t.Fatalf("no %q record in logs:\n%s", msg, l.buf.String())
+	return nil
+}
+
+func newTestLogger(recs *logRecords) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(recs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func TestLogging_RetryCarriesTraceIDs(t *testing.T) {
+	t.Run("Config.Logger", func(t *testing.T) {
+		var recs logRecords
+		testRetryTraceIDs(t, &recs, Config{Logger: newTestLogger(&recs)})
+	})
+	t.Run("RetryConfig.Logger", func(t *testing.T) {
+		var recs logRecords
+		testRetryTraceIDs(t, &recs, Config{Retry: RetryConfig{Logger: newTestLogger(&recs)}})
+	})
+}
+
+func testRetryTraceIDs(t *testing.T, recs *logRecords, cfg Config) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(HealthzResponse{Status: "ok"})
+	}))
+	defer srv.Close()
+
+	cfg.BaseURL = srv.URL
+	cfg.Retry.MaxAttempts = 2
+	cfg.Retry.BaseDelay = time.Millisecond
+	c, err := Dial(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	ctx := trace.ContextWithSpanContext(context.Background(), testProducerSpan)
+	if _, err := c.Healthz(ctx); err != nil {
+		t.Fatalf("Healthz: %v", err)
+	}
+
+	rec := recs.find(t, "driftq retrying request")
+	if rec["level"] != "INFO" || rec["path"] != "/v1/healthz" || rec["attempt"] != float64(1) || rec["status"] != float64(503) {
+		t.Fatalf("unexpected retry record: %v", rec)
+	}
+
+	if rec["trace_id"] != testProducerSpan.TraceID().String() || rec["span_id"] != testProducerSpan.SpanID().String() {
+		t.Fatalf("expected trace/span ids on record: %v", rec)
+	}
+}
+
+func TestLogging_WorkerRedactsPayloads(t *testing.T) {
+	for _, logPayloads := range []bool{false, true} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v1/consume":
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				_, _ = w.Write([]byte(`{"partition":0,"offset":5,"attempts":1,"key":"k","value":"secret"}` + "\n"))
+			case "/v1/nack":
+				w.WriteHeader(http.StatusInternalServerError)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+
+		var recs logRecords
+		c, err := Dial(context.Background(), Config{
+			BaseURL:     srv.URL,
+			Retry:       RetryConfig{MaxAttempts: 1},
+			Logger:      newTestLogger(&recs),
+			LogPayloads: logPayloads,
+		})
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+
+		wk, err := NewWorker(WorkerConfig{
+			Client:  c,
+			Consume: ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+			Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error { return errors.New("boom") }),
+		})
+		if err != nil {
+			t.Fatalf("NewWorker: %v", err)
+		}
+
+		if err := wk.Run(context.Background()); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		srv.Close()
+
+		rec := recs.find(t, "driftq nack failed")
+		if rec["level"] != "ERROR" || rec["offset"] != float64(5) || rec["reason"] != "boom" {
+			t.Fatalf("unexpected nack record: %v", rec)
+		}
+
+		_, hasValue := rec["value"]
+		if hasValue != logPayloads {
+			t.Fatalf("LogPayloads=%v but value present=%v: %v", logPayloads, hasValue, rec)
+		}
+		if !logPayloads && rec["value_bytes"] != float64(len("secret")) {
+			t.Fatalf("expected value_bytes on redacted record: %v", rec)
+		}
+
+		recs.find(t, "driftq consume stream opened")
+		recs.find(t, "driftq consume stream closed")
+	}
+}
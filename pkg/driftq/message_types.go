@@ -18,6 +18,9 @@ type Envelope struct {
 	Deadline          *time.Time   `json:"deadline,omitempty"`
 	PartitionOverride *int         `json:"partition_override,omitempty"`
 	RetryPolicy       *RetryPolicy `json:"retry_policy,omitempty"`
+
+	// TraceContext carries the producer's OTel propagation fields (e.g. traceparent); Produce fills it in
+	TraceContext map[string]string `json:"trace_context,omitempty"`
 }
 
 type ProduceRequest struct {
@@ -47,4 +50,5 @@ type NackRequest struct {
 	Partition int    `json:"partition"`
 	Offset    int64  `json:"offset"`
 	Reason    string `json:"reason,omitempty"`
+	DelayMS   int64  `json:"delay_ms,omitempty"` // requested redelivery delay; 0 = redeliver right away
 }
@@ -0,0 +1,203 @@
+package driftq
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// MetricsConfig turns on OpenTelemetry metrics for the client, ConsumeStream and Worker
+type MetricsConfig struct {
+	// MeterProvider records everything; nil disables metrics
+	MeterProvider metric.MeterProvider
+	MeterName     string // default: the module path
+}
+
+const (
+	attrHTTPMethod     = "http.request.method"
+	attrHTTPRoute      = "http.route"
+	attrHTTPStatusCode = "http.response.status_code"
+	attrErrorType      = "error.type"
+)
+
+// clientMetrics holds every instrument the SDK records. It is never nil on a Client:
+// without a MeterProvider it is backed by a no-op meter
+type clientMetrics struct {
+	reqDuration      metric.Float64Histogram
+	requests         metric.Int64Counter
+	retries          metric.Int64Counter
+	sentMessages     metric.Int64Counter
+	sentBytes        metric.Int64Counter
+	consumedMessages metric.Int64Counter
+	processDuration  metric.Float64Histogram
+	inflight         metric.Int64UpDownCounter
+	tenantQueued     metric.Int64UpDownCounter
+	tenantThrottled  metric.Int64Counter
+	expired          metric.Int64Counter
+	quarantined      metric.Int64Counter
+	reconnects       metric.Int64Counter
+	consumerLag      metric.Int64Gauge
+}
+
+func newClientMetrics(cfg MetricsConfig) *clientMetrics {
+	mp := cfg.MeterProvider
+	if mp == nil {
+		mp = noop.NewMeterProvider()
+	}
+
+	name := cfg.MeterName
+	if name == "" {
+		name = "github.com/driftq-org/DriftQ-Clients-Go"
+	}
+	m := mp.Meter(name, metric.WithInstrumentationVersion(Version))
+
+	// Instrument errors only happen on invalid names/units; the API still hands back a usable instrument
+	var err error
+	handle := func() {
+		if err != nil {
+			otel.Handle(err)
+		}
+	}
+
+	cm := &clientMetrics{}
+
+	cm.reqDuration, err = m.Float64Histogram("driftq.client.request.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of HTTP requests to the DriftQ API (per attempt)"))
+	handle()
+	cm.requests, err = m.Int64Counter("driftq.client.requests",
+		metric.WithUnit("{request}"), metric.WithDescription("HTTP requests to the DriftQ API (per attempt)"))
+	handle()
+	cm.retries, err = m.Int64Counter("driftq.client.retries",
+		metric.WithUnit("{retry}"), metric.WithDescription("Requests re-sent by the retry middleware"))
+	handle()
+	cm.sentMessages, err = m.Int64Counter("messaging.client.sent.messages",
+		metric.WithUnit("{message}"), metric.WithDescription("Messages produced"))
+	handle()
+	cm.sentBytes, err = m.Int64Counter("driftq.client.sent.bytes",
+		metric.WithUnit("By"), metric.WithDescription("Message value bytes produced"))
+	handle()
+	cm.consumedMessages, err = m.Int64Counter("messaging.client.consumed.messages",
+		metric.WithUnit("{message}"), metric.WithDescription("Messages received on consume streams"))
+	handle()
+	cm.processDuration, err = m.Float64Histogram("messaging.process.duration",
+		metric.WithUnit("s"), metric.WithDescription("Worker handler duration, including Ack/Nack, by outcome"))
+	handle()
+	cm.inflight, err = m.Int64UpDownCounter("driftq.worker.inflight",
+		metric.WithUnit("{message}"), metric.WithDescription("Messages currently being handled by workers"))
+	handle()
+	cm.tenantQueued, err = m.Int64UpDownCounter("driftq.worker.tenant.queued",
+		metric.WithUnit("{message}"), metric.WithDescription("Messages waiting for a worker slot, per tenant (Fairness)"))
+	handle()
+	cm.tenantThrottled, err = m.Int64Counter("driftq.worker.tenant.throttled",
+		metric.WithUnit("{message}"), metric.WithDescription("Messages nacked for being over their tenant's quota (Fairness)"))
+	handle()
+	cm.expired, err = m.Int64Counter("driftq.messages.expired",
+		metric.WithUnit("{message}"), metric.WithDescription("Messages past their envelope deadline: rejected by Produce or settled by a Worker without handling"))
+	handle()
+	cm.quarantined, err = m.Int64Counter("driftq.messages.quarantined",
+		metric.WithUnit("{message}"), metric.WithDescription("Poison messages moved to the poison topic instead of being handled again"))
+	handle()
+	cm.reconnects, err = m.Int64Counter("driftq.consumer.reconnects",
+		metric.WithUnit("{reconnect}"), metric.WithDescription("Consume streams reopened by workers"))
+	handle()
+	cm.consumerLag, err = m.Int64Gauge("driftq.consumer.lag",
+		metric.WithUnit("{message}"), metric.WithDescription("Messages behind the high-water mark, per group partition (LagMonitor)"))
+	handle()
+
+	return cm
+}
+
+func messagingAttrs(topic string, extra ...attribute.KeyValue) metric.MeasurementOption {
+	attrs := append([]attribute.KeyValue{
+		attribute.String(attrMessagingSystem, messagingSystem),
+		attribute.String(attrMessagingDestination, topic),
+	}, extra...)
+	return metric.WithAttributes(attrs...)
+}
+
+func (w *Worker) metricAttrs(extra ...attribute.KeyValue) metric.MeasurementOption {
+	return messagingAttrs(w.opt.Topic, append([]attribute.KeyValue{
+		attribute.String(attrMessagingConsumerGroup, w.opt.Group),
+	}, extra...)...)
+}
+
+// ---- Metrics middleware ----
+
+// httpRoute is req's path as a route template: topic and group names become {name} and {group}
+// (/v1/topics/{name}/config), so http.route stays low-cardinality
+func httpRoute(req *http.Request) string {
+	segs := strings.Split(strings.TrimPrefix(req.URL.EscapedPath(), "/"), "/")
+	if len(segs) > 2 && segs[0] == "v1" {
+		switch segs[1] {
+		case "topics":
+			segs[2] = "{name}"
+		case "groups":
+			segs[2] = "{group}"
+		}
+	}
+	return "/" + strings.Join(segs, "/")
+}
+
+// metricsMiddleware records request count/duration per HTTP attempt, and counts retries.
+// It sits inside RetryMiddleware so every attempt is observed
+func metricsMiddleware(cm *clientMetrics) RoundTripperMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			if retryAttempt(ctx) > 1 {
+				cm.retries.Add(ctx, 1, metric.WithAttributes(
+					attribute.String(attrHTTPMethod, req.Method),
+					attribute.String(attrHTTPRoute, httpRoute(req)),
+				))
+			}
+
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+
+			attrs := []attribute.KeyValue{
+				attribute.String(attrHTTPMethod, req.Method),
+				attribute.String(attrHTTPRoute, httpRoute(req)),
+			}
+			switch {
+			case err != nil:
+				attrs = append(attrs, attribute.String(attrErrorType, errorType(err)))
+			case resp != nil:
+				attrs = append(attrs, attribute.Int(attrHTTPStatusCode, resp.StatusCode))
+				if resp.StatusCode >= 400 {
+					attrs = append(attrs, attribute.String(attrErrorType, strconv.Itoa(resp.StatusCode)))
+				}
+			}
+
+			opt := metric.WithAttributes(attrs...)
+			cm.reqDuration.Record(ctx, time.Since(start).Seconds(), opt)
+			cm.requests.Add(ctx, 1, opt)
+
+			return resp, err
+		})
+	}
+}
+
+func errorType(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		var ae *APIError
+		if errors.As(err, &ae) {
+			return strconv.Itoa(ae.Status)
+		}
+		return "_OTHER"
+	}
+}
@@ -0,0 +1,327 @@
+package driftq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func collectMetrics(t *testing.T, r *sdkmetric.ManualReader) map[string]metricdata.Aggregation {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := r.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+
+	out := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			out[m.Name] = m.Data
+		}
+	}
+	return out
+}
+
+// sumWhere adds up Int64 sum data points whose attributes include every kv in match
+func sumWhere(t *testing.T, agg metricdata.Aggregation, match ...attribute.KeyValue) int64 {
+	t.Helper()
+
+	s, ok := agg.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("expected Sum[int64], got %T", agg)
+	}
+
+	var total int64
+	for _, dp := range s.DataPoints {
+		if hasAttrs(dp.Attributes, match) {
+			total += dp.Value
+		}
+	}
+	return total
+}
+
+func histCountWhere(t *testing.T, agg metricdata.Aggregation, match ...attribute.KeyValue) uint64 {
+	t.Helper()
+
+	h, ok := agg.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("expected Histogram[float64], got %T", agg)
+	}
+
+	var total uint64
+	for _, dp := range h.DataPoints {
+		if hasAttrs(dp.Attributes, match) {
+			total += dp.Count
+		}
+	}
+	return total
+}
+
+func hasAttrs(set attribute.Set, match []attribute.KeyValue) bool {
+	for _, kv := range match {
+		v, ok := set.Value(kv.Key)
+		if !ok || v != kv.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMetrics_ClientConsumerAndWorker(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(
+		`{"partition":0,"offset":1,"attempts":1,"value":"ok"}`,
+		`{"partition":0,"offset":2,"attempts":1,"value":"fail"}`,
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL, Metrics: MetricsConfig{MeterProvider: mp}})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	if _, err := c.Produce(context.Background(), ProduceRequest{Topic: "demo", Value: "hello"}); err != nil {
+		t.Fatalf("Produce: %v", err)
+	}
+
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			if msg.Value == "fail" {
+				return errors.New("boom")
+			}
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	got := collectMetrics(t, reader)
+	topic := attribute.String(attrMessagingDestination, "demo")
+	group := attribute.String(attrMessagingConsumerGroup, "g")
+
+	if n := sumWhere(t, got["messaging.client.sent.messages"], topic); n != 1 {
+		t.Fatalf("sent messages = %d, want 1", n)
+	}
+	if n := sumWhere(t, got["driftq.client.sent.bytes"], topic); n != 5 {
+		t.Fatalf("sent bytes = %d, want 5", n)
+	}
+	if n := sumWhere(t, got["messaging.client.consumed.messages"], topic, group); n != 2 {
+		t.Fatalf("consumed messages = %d, want 2", n)
+	}
+	if n := sumWhere(t, got["driftq.worker.inflight"], topic, group); n != 0 {
+		t.Fatalf("inflight = %d, want 0", n)
+	}
+
+	for _, outcome := range []string{"ack", "nack"} {
+		if n := histCountWhere(t, got["messaging.process.duration"], group, attribute.String(attrMessagingOutcome, outcome)); n != 1 {
+			t.Fatalf("process.duration count for %s = %d, want 1", outcome, n)
+		}
+	}
+
+	ack := []attribute.KeyValue{attribute.String(attrHTTPRoute, "/v1/ack"), attribute.Int(attrHTTPStatusCode, 204)}
+	if n := sumWhere(t, got["driftq.client.requests"], ack...); n != 1 {
+		t.Fatalf("ack requests = %d, want 1", n)
+	}
+	if n := histCountWhere(t, got["driftq.client.request.duration"], ack...); n != 1 {
+		t.Fatalf("ack request duration count = %d, want 1", n)
+	}
+}
+
+func TestMetrics_RouteIsTemplated(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL, Metrics: MetricsConfig{MeterProvider: mp}})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	a := c.Admin()
+	for _, name := range []string{"orders", "a/b"} {
+		if _, err := a.DescribeTopic(context.Background(), name); err != nil {
+			t.Fatalf("DescribeTopic: %v", err)
+		}
+		if _, err := a.UpdateTopicConfig(context.Background(), name, TopicConfigUpdate{}); err != nil {
+			t.Fatalf("UpdateTopicConfig: %v", err)
+		}
+	}
+	if _, err := a.DescribeGroup(context.Background(), "billing", ""); err != nil {
+		t.Fatalf("DescribeGroup: %v", err)
+	}
+
+	got := collectMetrics(t, reader)
+	for route, want := range map[string]int64{"/v1/topics/{name}": 2, "/v1/topics/{name}/config": 2, "/v1/groups/{group}": 1} {
+		if n := sumWhere(t, got["driftq.client.requests"], attribute.String(attrHTTPRoute, route)); n != want {
+			t.Fatalf("requests for %s = %d, want %d", route, n, want)
+		}
+	}
+}
+
+func TestMetrics_RetriesAndReconnects(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	var consumeHits, healthzFailed int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/healthz":
+			// first attempt fails, the retry succeeds
+			if atomic.CompareAndSwapInt32(&healthzFailed, 0, 1) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(HealthzResponse{Status: "ok"})
+
+		case "/v1/consume":
+			// every stream closes right away
+			atomic.AddInt32(&consumeHits, 1)
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{
+		BaseURL: srv.URL,
+		Retry:   RetryConfig{MaxAttempts: 2, BaseDelay: time.Millisecond},
+		Metrics: MetricsConfig{MeterProvider: mp},
+	})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	if _, err := c.Healthz(context.Background()); err != nil {
+		t.Fatalf("Healthz: %v", err)
+	}
+
+	var reconnects []ReconnectEvent
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error { return nil }),
+		Hooks: WorkerHooks{
+			OnReconnect: func(ctx context.Context, ev ReconnectEvent) { reconnects = append(reconnects, ev) },
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+
+	// The worker's Run is called again each time the server closes the stream
+	for range 3 {
+		if err := wk.Run(ctx); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	}
+	if len(reconnects) != 2 || reconnects[1].Opens != 3 || reconnects[1].Err != nil {
+		t.Fatalf("unexpected OnReconnect calls %+v", reconnects)
+	}
+
+	got := collectMetrics(t, reader)
+
+	if n := sumWhere(t, got["driftq.client.retries"], attribute.String(attrHTTPRoute, "/v1/healthz")); n != 1 {
+		t.Fatalf("retries = %d, want 1", n)
+	}
+	if n := sumWhere(t, got["driftq.consumer.reconnects"], attribute.String(attrMessagingDestination, "demo")); n != 2 {
+		t.Fatalf("reconnects = %d, want 2", n)
+	}
+}
+
+func TestLagMonitor_CallbackAndGauge(t *testing.T) {
+	var polls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if polls.Add(1) == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"partitions":[
+			{"topic":"demo","partition":0,"committed_offset":5,"high_watermark":9},
+			{"topic":"demo","partition":1,"committed_offset":1,"high_watermark":3}]}`))
+	}))
+	defer srv.Close()
+
+	reader := sdkmetric.NewManualReader()
+	c, err := Dial(context.Background(), Config{
+		BaseURL: srv.URL,
+		Retry:   RetryConfig{MaxAttempts: 1},
+		Metrics: MetricsConfig{MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))},
+	})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	samples := make(chan GroupLag, 4)
+	errs := make(chan error, 4)
+	m, err := c.Admin().NewLagMonitor(LagMonitorConfig{
+		Group:    "workers",
+		Topic:    "demo",
+		Interval: 10 * time.Millisecond,
+		OnLag:    func(_ context.Context, l GroupLag) { samples <- l },
+		OnError:  func(err error) { errs <- err },
+	})
+	if err != nil {
+		t.Fatalf("NewLagMonitor: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+
+	first := <-samples
+	if first.Total != 6 || len(first.Partitions) != 2 {
+		t.Fatalf("unexpected sample %#v", first)
+	}
+	var ae *APIError
+	if err := <-errs; !errors.As(err, &ae) || ae.Status != http.StatusServiceUnavailable {
+		t.Fatalf("expected poll error to be reported, got %v", err)
+	}
+	<-samples // keeps polling after a failure
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run: %v", err)
+	}
+
+	g, ok := collectMetrics(t, reader)["driftq.consumer.lag"].(metricdata.Gauge[int64])
+	if !ok {
+		t.Fatalf("expected driftq.consumer.lag gauge")
+	}
+	byPartition := map[string]int64{}
+	for _, dp := range g.DataPoints {
+		v, _ := dp.Attributes.Value(attrMessagingPartition)
+		byPartition[v.Emit()] = dp.Value
+	}
+	if byPartition["0"] != 4 || byPartition["1"] != 2 {
+		t.Fatalf("unexpected gauge values %v", byPartition)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
 	"math/rand"
 	"net"
@@ -43,7 +44,10 @@ func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { r
 
 type ctxKey int
 
-const ctxKeyNoDefaultTimeout ctxKey = iota
+const (
+	ctxKeyNoDefaultTimeout ctxKey = iota
+	ctxKeyRetryAttempt
+)
 
 // WithNoDefaultTimeout disables the client's default timeout middleware for this ctx so we
 // can use it for long-lived streaming calls where the caller controls lifetime via ctx cancel
@@ -75,19 +79,45 @@ func DeadlineMiddleware(defaultTimeout time.Duration) RoundTripperMiddleware {
 			}
 
 			ctx, cancel := context.WithTimeout(req.Context(), defaultTimeout)
-			defer cancel()
 
-			return next.RoundTrip(req.Clone(ctx))
+			// The body is read after RoundTrip returns, so the timeout ends when it's closed
+			resp, err := next.RoundTrip(req.Clone(ctx))
+			if err != nil || resp == nil || resp.Body == nil {
+				cancel()
+				return resp, err
+			}
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
 		})
 	}
 }
 
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
 // ---- Retry middleware ----
 
+// retryAttempt is the 1-based attempt number RetryMiddleware stamped on the request ctx (0 = not retried)
+func retryAttempt(ctx context.Context) int {
+	n, _ := ctx.Value(ctxKeyRetryAttempt).(int)
+	return n
+}
+
 type RetryConfig struct {
 	MaxAttempts int
 	BaseDelay   time.Duration
 	MaxDelay    time.Duration
+
+	// Logger gets an event per retry and when retries are exhausted (nil = silent; Dial uses Config.Logger)
+	Logger *slog.Logger
 }
 
 func (c RetryConfig) withDefaults() RetryConfig {
@@ -111,6 +141,10 @@ func (c RetryConfig) withDefaults() RetryConfig {
 		c.MaxDelay = 2 * time.Second
 	}
 
+	if c.Logger == nil {
+		c.Logger = slog.New(slog.DiscardHandler)
+	}
+
 	return c
 }
 
@@ -210,6 +244,28 @@ func backoff(base, max time.Duration, attempt int) time.Duration {
 	return jitter(d)
 }
 
+func retryLogAttrs(req *http.Request, attempt int, resp *http.Response, err error) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.Int("attempt", attempt),
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	return attrs
+}
+
+func statusCode(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
 func sleepCtx(ctx context.Context, d time.Duration) error {
 	if d <= 0 {
 		return nil
@@ -252,7 +308,7 @@ func RetryMiddleware(cfg RetryConfig) RoundTripperMiddleware {
 				// Re-create body for retries if possible
 				r := req
 				if attempt > 1 {
-					r = req.Clone(req.Context())
+					r = req.Clone(context.WithValue(req.Context(), ctxKeyRetryAttempt, attempt))
 					if req.GetBody != nil {
 						b, err := req.GetBody()
 						if err != nil {
@@ -273,6 +329,8 @@ func RetryMiddleware(cfg RetryConfig) RoundTripperMiddleware {
 				}
 
 				if attempt == cfg.MaxAttempts {
+					cfg.Logger.LogAttrs(req.Context(), slog.LevelWarn, "driftq retries exhausted",
+						retryLogAttrs(req, attempt, resp, err)...)
 					if err != nil {
 						return nil, err
 					}
@@ -298,14 +356,12 @@ func RetryMiddleware(cfg RetryConfig) RoundTripperMiddleware {
 					attribute.String("url", req.URL.String()),
 					attribute.Int64("wait_ms", wait.Milliseconds()),
 					attribute.String("error", fmt.Sprintf("%v", err)),
-					attribute.Int("status_code", func() int {
-						if resp == nil {
-							return 0
-						}
-						return resp.StatusCode
-					}()),
+					attribute.Int("status_code", statusCode(resp)),
 				))
 
+				cfg.Logger.LogAttrs(req.Context(), slog.LevelInfo, "driftq retrying request",
+					append(retryLogAttrs(req, attempt, resp, err), slog.Duration("wait", wait))...)
+
 				if serr := sleepCtx(req.Context(), wait); serr != nil {
 					return nil, serr
 				}
@@ -324,6 +380,18 @@ type TracingConfig struct {
 	Disable    bool
 	StartSpans bool
 	TracerName string
+
+	// ConsumerSpan controls how a Worker's per-message span relates to the producer's trace
+	// (carried in Envelope.TraceContext). Default: ConsumerSpanChild
+	ConsumerSpan ConsumerSpanMode
+}
+
+func (c TracingConfig) withDefaults() TracingConfig {
+	if c.TracerName == "" {
+		c.TracerName = "github.com/driftq-org/DriftQ-Clients-Go"
+	}
+
+	return c
 }
 
 func TracingMiddleware(cfg TracingConfig) RoundTripperMiddleware {
@@ -331,9 +399,7 @@ func TracingMiddleware(cfg TracingConfig) RoundTripperMiddleware {
 		return func(next http.RoundTripper) http.RoundTripper { return next }
 	}
 
-	if cfg.TracerName == "" {
-		cfg.TracerName = "github.com/driftq-org/DriftQ-Clients-Go"
-	}
+	cfg = cfg.withDefaults()
 
 	return func(next http.RoundTripper) http.RoundTripper {
 		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
@@ -375,5 +441,5 @@ func spanName(req *http.Request) string {
 	if req == nil || req.URL == nil {
 		return "driftq.http"
 	}
-	return fmt.Sprintf("driftq.http %s %s", req.Method, req.URL.Path)
+	return fmt.Sprintf("driftq.http %s %s", req.Method, httpRoute(req))
 }
@@ -3,8 +3,11 @@ package driftq
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -115,6 +118,36 @@ func TestDeadlineMiddleware_DoesNotApplyToConsumeStream(t *testing.T) {
 	}
 }
 
+func TestDeadlineMiddleware_CancelsOnlyOnBodyClose(t *testing.T) {
+	var reqCtx context.Context
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		reqCtx = req.Context()
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "http://driftq.invalid/v1/healthz", nil)
+	resp, err := DeadlineMiddleware(time.Minute)(next).RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+
+	// The body is still unread here, so the timeout ctx must still be live
+	if err := reqCtx.Err(); err != nil {
+		t.Fatalf("ctx ended before the body was read: %v", err)
+	}
+	if b, err := io.ReadAll(resp.Body); err != nil || string(b) != "ok" {
+		t.Fatalf("ReadAll: %q %v", b, err)
+	}
+	if err := reqCtx.Err(); err != nil {
+		t.Fatalf("ctx ended before Close: %v", err)
+	}
+
+	_ = resp.Body.Close()
+	if !errors.Is(reqCtx.Err(), context.Canceled) {
+		t.Fatalf("expected Close to cancel the timeout ctx, got %v", reqCtx.Err())
+	}
+}
+
 func TestTracingMiddleware_InjectsTraceparent(t *testing.T) {
 	old := otel.GetTextMapPropagator()
 	otel.SetTextMapPropagator(propagation.TraceContext{})
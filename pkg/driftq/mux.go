@@ -0,0 +1,247 @@
+package driftq
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// UnmatchedPolicy decides what Mux does with a message no route (and no fallback) accepts
+type UnmatchedPolicy int
+
+const (
+	// UnmatchedNack nacks the message with ErrNoRoute (default)
+	UnmatchedNack UnmatchedPolicy = iota
+	// UnmatchedAck acks (drops) the message
+	UnmatchedAck
+	// UnmatchedDeadLetter fails it with Permanent(ErrNoRoute), so a Worker with DeadLetter set parks it on the DLQ
+	UnmatchedDeadLetter
+)
+
+func (p UnmatchedPolicy) String() string {
+	switch p {
+	case UnmatchedNack:
+		return "nack"
+	case UnmatchedAck:
+		return "ack"
+	case UnmatchedDeadLetter:
+		return "dead-letter"
+	default:
+		return fmt.Sprintf("UnmatchedPolicy(%d)", int(p))
+	}
+}
+
+// RoutePredicate reports whether a message belongs to a route
+type RoutePredicate func(msg ConsumeMessage) bool
+
+// MetaEquals matches messages whose Routing.Meta[key] == value
+func MetaEquals(key, value string) RoutePredicate {
+	return func(msg ConsumeMessage) bool {
+		if msg.Routing == nil {
+			return false
+		}
+		v, ok := msg.Routing.Meta[key]
+		return ok && v == value
+	}
+}
+
+// HasMeta matches messages that carry Routing.Meta[key] (any value)
+func HasMeta(key string) RoutePredicate {
+	return func(msg ConsumeMessage) bool {
+		if msg.Routing == nil {
+			return false
+		}
+		_, ok := msg.Routing.Meta[key]
+		return ok
+	}
+}
+
+// RouteInfo describes a registered route (for debugging / introspection)
+type RouteInfo struct {
+	Kind       string // "label", "prefix", "glob", "match" or "fallback"
+	Pattern    string // label, prefix, glob pattern or predicate name
+	Middleware int    // number of per-route middlewares
+}
+
+func (r RouteInfo) String() string {
+	return fmt.Sprintf("%s %q", r.Kind, r.Pattern)
+}
+
+type muxRoute struct {
+	info  RouteInfo
+	match func(label string, msg ConsumeMessage) bool
+	h     StepHandler
+}
+
+// Mux is a StepHandler that dispatches on ConsumeMessage.Routing.
+//
+// Exact label routes win; otherwise prefix, glob and predicate routes are tried in registration order.
+// Messages nothing matches go to the fallback handler, or are handled per the UnmatchedPolicy.
+//
+// Like http.ServeMux, registering an invalid route (nil handler, duplicate label, bad glob) panics
+type Mux struct {
+	mu        sync.RWMutex
+	labels    map[string]*muxRoute
+	routes    []*muxRoute // non-exact routes, in registration order
+	order     []*muxRoute // every route, in registration order (for Routes)
+	fallback  *muxRoute
+	unmatched UnmatchedPolicy
+}
+
+func NewMux() *Mux {
+	return &Mux{labels: make(map[string]*muxRoute)}
+}
+
+// Label routes messages whose Routing.Label equals label exactly
+func (m *Mux) Label(label string, h StepHandler, mws ...HandlerMiddleware) *Mux {
+	r := m.newRoute("label", label, h, mws, nil)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, dup := m.labels[label]; dup {
+		panic(fmt.Sprintf("driftq: mux: duplicate label route %q", label))
+	}
+	m.labels[label] = r
+	m.order = append(m.order, r)
+	return m
+}
+
+// Prefix routes messages whose Routing.Label starts with prefix
+func (m *Mux) Prefix(prefix string, h StepHandler, mws ...HandlerMiddleware) *Mux {
+	return m.add(m.newRoute("prefix", prefix, h, mws, func(label string, _ ConsumeMessage) bool {
+		return strings.HasPrefix(label, prefix)
+	}))
+}
+
+// Glob routes messages whose Routing.Label matches pattern (path.Match syntax, e.g. "orders.*")
+func (m *Mux) Glob(pattern string, h StepHandler, mws ...HandlerMiddleware) *Mux {
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic(fmt.Sprintf("driftq: mux: invalid glob %q: %v", pattern, err))
+	}
+	return m.add(m.newRoute("glob", pattern, h, mws, func(label string, _ ConsumeMessage) bool {
+		ok, _ := path.Match(pattern, label)
+		return ok
+	}))
+}
+
+// Match routes messages accepted by pred (which can look at Routing.Meta, Envelope, ...).
+// name only shows up in Routes()
+func (m *Mux) Match(name string, pred RoutePredicate, h StepHandler, mws ...HandlerMiddleware) *Mux {
+	if pred == nil {
+		panic("driftq: mux: nil predicate")
+	}
+	return m.add(m.newRoute("match", name, h, mws, func(_ string, msg ConsumeMessage) bool {
+		return pred(msg)
+	}))
+}
+
+// Fallback handles messages no route matches (takes precedence over the UnmatchedPolicy)
+func (m *Mux) Fallback(h StepHandler, mws ...HandlerMiddleware) *Mux {
+	r := m.newRoute("fallback", "", h, mws, nil)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.fallback = r
+	return m
+}
+
+// Unmatched sets what happens to messages no route matches when there is no fallback
+func (m *Mux) Unmatched(p UnmatchedPolicy) *Mux {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.unmatched = p
+	return m
+}
+
+// Routes lists registered routes in registration order (fallback last, if set)
+func (m *Mux) Routes() []RouteInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]RouteInfo, 0, len(m.order)+1)
+	for _, r := range m.order {
+		out = append(out, r.info)
+	}
+	if m.fallback != nil {
+		out = append(out, m.fallback.info)
+	}
+	return out
+}
+
+// Route reports which route msg would be dispatched to; ok is false when the UnmatchedPolicy applies
+func (m *Mux) Route(msg ConsumeMessage) (RouteInfo, bool) {
+	r := m.lookup(msg)
+	if r == nil {
+		return RouteInfo{}, false
+	}
+	return r.info, true
+}
+
+func (m *Mux) Handle(ctx context.Context, msg ConsumeMessage) error {
+	if r := m.lookup(msg); r != nil {
+		return r.h.Handle(ctx, msg)
+	}
+
+	m.mu.RLock()
+	policy := m.unmatched
+	m.mu.RUnlock()
+
+	err := fmt.Errorf("%w: label=%q", ErrNoRoute, routingLabel(msg))
+	switch policy {
+	case UnmatchedAck:
+		return nil
+	case UnmatchedDeadLetter:
+		return Permanent(err)
+	default:
+		return err
+	}
+}
+
+func (m *Mux) lookup(msg ConsumeMessage) *muxRoute {
+	label := routingLabel(msg)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if r, ok := m.labels[label]; ok {
+		return r
+	}
+	for _, r := range m.routes {
+		if r.match(label, msg) {
+			return r
+		}
+	}
+	return m.fallback
+}
+
+func (m *Mux) newRoute(kind, pattern string, h StepHandler, mws []HandlerMiddleware, match func(string, ConsumeMessage) bool) *muxRoute {
+	if h == nil {
+		panic(fmt.Sprintf("driftq: mux: nil handler for %s %q", kind, pattern))
+	}
+	return &muxRoute{
+		info:  RouteInfo{Kind: kind, Pattern: pattern, Middleware: len(mws)},
+		match: match,
+		h:     ChainHandler(h, mws...),
+	}
+}
+
+func (m *Mux) add(r *muxRoute) *Mux {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.routes = append(m.routes, r)
+	m.order = append(m.order, r)
+	return m
+}
+
+func routingLabel(msg ConsumeMessage) string {
+	if msg.Routing == nil {
+		return ""
+	}
+	return msg.Routing.Label
+}
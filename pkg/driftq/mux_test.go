@@ -0,0 +1,113 @@
+package driftq
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func labeled(label string, meta map[string]string) ConsumeMessage {
+	return ConsumeMessage{Routing: &Routing{Label: label, Meta: meta}}
+}
+
+func TestMux_DispatchOrder(t *testing.T) {
+	var got string
+	named := func(name string) StepHandler {
+		return StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			got = name
+			return nil
+		})
+	}
+
+	mux := NewMux().
+		Prefix("orders.", named("prefix")).
+		Label("orders.created", named("exact")).
+		Glob("billing.*.v2", named("glob")).
+		Match("vip", MetaEquals("tier", "vip"), named("vip")).
+		Fallback(named("fallback"))
+
+	cases := []struct {
+		msg  ConsumeMessage
+		want string
+	}{
+		{labeled("orders.created", nil), "exact"}, // exact wins over an earlier prefix
+		{labeled("orders.updated", nil), "prefix"},
+		{labeled("billing.invoice.v2", nil), "glob"},
+		{labeled("other", map[string]string{"tier": "vip"}), "vip"},
+		{labeled("other", nil), "fallback"},
+		{ConsumeMessage{}, "fallback"},
+	}
+
+	for _, tc := range cases {
+		got = ""
+		if err := mux.Handle(context.Background(), tc.msg); err != nil {
+			t.Fatalf("Handle(%v): %v", tc.msg.Routing, err)
+		}
+		if got != tc.want {
+			t.Fatalf("Handle(%v): routed to %q, want %q", tc.msg.Routing, got, tc.want)
+		}
+	}
+
+	routes := mux.Routes()
+	if len(routes) != 5 || routes[0].Kind != "prefix" || routes[1].Kind != "label" || routes[4].Kind != "fallback" {
+		t.Fatalf("unexpected routes: %v", routes)
+	}
+
+	if ri, ok := mux.Route(labeled("orders.x", nil)); !ok || ri.Pattern != "orders." {
+		t.Fatalf("unexpected Route result: %v %v", ri, ok)
+	}
+}
+
+func TestMux_UnmatchedPolicies(t *testing.T) {
+	h := StepFunc(func(ctx context.Context, msg ConsumeMessage) error { return nil })
+	msg := labeled("nope", nil)
+
+	err := NewMux().Label("a", h).Handle(context.Background(), msg)
+	if !errors.Is(err, ErrNoRoute) || IsPermanent(err) {
+		t.Fatalf("nack policy: unexpected err %v", err)
+	}
+
+	if err := NewMux().Label("a", h).Unmatched(UnmatchedAck).Handle(context.Background(), msg); err != nil {
+		t.Fatalf("ack policy: unexpected err %v", err)
+	}
+
+	err = NewMux().Label("a", h).Unmatched(UnmatchedDeadLetter).Handle(context.Background(), msg)
+	if !errors.Is(err, ErrNoRoute) || !IsPermanent(err) {
+		t.Fatalf("dead-letter policy: unexpected err %v", err)
+	}
+}
+
+func TestMux_PerRouteMiddleware(t *testing.T) {
+	var wrapped bool
+	mw := func(next StepHandler) StepHandler {
+		return StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			wrapped = true
+			return next.Handle(ctx, msg)
+		})
+	}
+
+	mux := NewMux().
+		Label("a", StepFunc(func(ctx context.Context, msg ConsumeMessage) error { return nil }), mw).
+		Label("b", StepFunc(func(ctx context.Context, msg ConsumeMessage) error { return nil }))
+
+	_ = mux.Handle(context.Background(), labeled("b", nil))
+	if wrapped {
+		t.Fatalf("middleware for route a ran for route b")
+	}
+
+	_ = mux.Handle(context.Background(), labeled("a", nil))
+	if !wrapped {
+		t.Fatalf("expected route middleware to run")
+	}
+}
+
+func TestMux_DuplicateLabelPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic on duplicate label")
+		}
+	}()
+
+	h := StepFunc(func(ctx context.Context, msg ConsumeMessage) error { return nil })
+	NewMux().Label("a", h).Label("a", h)
+}
@@ -0,0 +1,424 @@
+package driftq
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// PoisonConfig turns on poison message detection for a Worker.
+//
+// The worker recovers handler panics and counts crashes per message (topic, partition, offset):
+// a recovered panic is one, and so is a message that was being handled when the process died
+// (an OOM kill, a panic on another goroutine, ...). Those are found through Journal: the worker
+// records every message before Handle starts and when it returns, and on the next Run every
+// message still open in the journal counts as a crash.
+//
+// Once a message reaches MaxCrashes it is quarantined: produced to Topic as a DeadLetterRecord
+// (so Client.Redrive can replay it after a fix) and acked, without running the handler again
+type PoisonConfig struct {
+	Topic string
+
+	// Journal is the crash journal file (created if missing). Without it only recovered panics
+	// are counted, in memory. Each Worker needs its own file
+	Journal string
+
+	MaxCrashes int // default 3
+
+	// Forget drops crash counts of messages that haven't come back for this long (default 24h)
+	Forget time.Duration
+}
+
+func (c PoisonConfig) withDefaults() PoisonConfig {
+	if c.MaxCrashes <= 0 {
+		c.MaxCrashes = 3
+	}
+	if c.Forget <= 0 {
+		c.Forget = 24 * time.Hour
+	}
+	return c
+}
+
+// QuarantineEvent reports a message sent to the poison topic instead of its handler
+type QuarantineEvent struct {
+	MessageEvent
+	Crashes int
+	Topic   string
+	Err     error // nil when the quarantine produce succeeded
+}
+
+type poisonKey struct {
+	Topic     string
+	Partition int
+	Offset    int64
+}
+
+type crashRecord struct {
+	Crashes   int
+	LastError string
+	Seen      time.Time
+}
+
+// journalEntry is one line of the crash journal
+type journalEntry struct {
+	Op        string    `json:"op"` // start, end or crashes (a compacted count)
+	Topic     string    `json:"topic"`
+	Partition int       `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Attempts  int       `json:"attempts,omitempty"`
+	Crashed   bool      `json:"crashed,omitempty"`
+	OK        bool      `json:"ok,omitempty"` // the handler succeeded: forget the message
+	Crashes   int       `json:"crashes,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+func (e journalEntry) key() poisonKey {
+	return poisonKey{Topic: e.Topic, Partition: e.Partition, Offset: e.Offset}
+}
+
+// compactEvery is how many entries are appended before the journal is rewritten
+const compactEvery = 10000
+
+// crashJournal counts crashes per message and (with a path) persists them
+type crashJournal struct {
+	cfg PoisonConfig
+
+	mu      sync.Mutex
+	f       *os.File
+	crashes map[poisonKey]*crashRecord
+	open    map[poisonKey]journalEntry
+	written int
+}
+
+func newCrashJournal(cfg PoisonConfig) *crashJournal {
+	return &crashJournal{
+		cfg:     cfg,
+		crashes: map[poisonKey]*crashRecord{},
+		open:    map[poisonKey]journalEntry{},
+	}
+}
+
+// load replays the journal (counting messages left open as crashes) and compacts it
+func (j *crashJournal) load() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.cfg.Journal == "" || j.f != nil {
+		return nil
+	}
+
+	// The file is the source of truth; what an earlier Run counted is in it too
+	clear(j.crashes)
+	clear(j.open)
+
+	f, err := os.Open(j.cfg.Journal)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("poison journal: %w", err)
+	default:
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			var e journalEntry
+			if json.Unmarshal(sc.Bytes(), &e) != nil {
+				continue // a torn last line
+			}
+			j.replay(e)
+		}
+		f.Close()
+		if err := sc.Err(); err != nil {
+			return fmt.Errorf("poison journal: %w", err)
+		}
+	}
+
+	for k, e := range j.open {
+		j.crash(k, "in flight when the worker died", e.At)
+	}
+	clear(j.open)
+
+	return j.compact()
+}
+
+func (j *crashJournal) replay(e journalEntry) {
+	k := e.key()
+	switch e.Op {
+	case "start":
+		j.open[k] = e
+	case "end":
+		delete(j.open, k)
+		if e.OK {
+			delete(j.crashes, k)
+		} else if e.Crashed {
+			j.crash(k, e.Error, e.At)
+		}
+	case "crashes":
+		j.crashes[k] = &crashRecord{Crashes: e.Crashes, LastError: e.Error, Seen: e.At}
+	}
+}
+
+func (j *crashJournal) crash(k poisonKey, reason string, at time.Time) int {
+	r := j.crashes[k]
+	if r == nil {
+		r = &crashRecord{}
+		j.crashes[k] = r
+	}
+	r.Crashes++
+	r.LastError = reason
+	r.Seen = at
+	return r.Crashes
+}
+
+// compact rewrites the journal with just the live crash counts; j.mu must be held
+func (j *crashJournal) compact() error {
+	if j.f != nil {
+		j.f.Close()
+		j.f = nil
+	}
+
+	dir := filepath.Dir(j.cfg.Journal)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("poison journal: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".poison-*")
+	if err != nil {
+		return fmt.Errorf("poison journal: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	cutoff := time.Now().Add(-j.cfg.Forget)
+	for k, r := range j.crashes {
+		if r.Seen.Before(cutoff) {
+			delete(j.crashes, k)
+			continue
+		}
+		_ = enc.Encode(journalEntry{Op: "crashes", Topic: k.Topic, Partition: k.Partition, Offset: k.Offset, Crashes: r.Crashes, Error: r.LastError, At: r.Seen})
+	}
+	for _, e := range j.open {
+		_ = enc.Encode(e)
+	}
+
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), j.cfg.Journal)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("poison journal: %w", err)
+	}
+
+	j.f, err = os.OpenFile(j.cfg.Journal, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("poison journal: %w", err)
+	}
+	j.written = 0
+	return nil
+}
+
+// append writes e; j.mu must be held. A write survives the process dying (it's in the page cache),
+// which is what the journal is for, so there is no fsync per entry
+func (j *crashJournal) append(e journalEntry) error {
+	if j.f == nil {
+		return nil
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("poison journal: %w", err)
+	}
+	if _, err := j.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("poison journal: %w", err)
+	}
+
+	j.written++
+	if j.written >= compactEvery {
+		return j.compact()
+	}
+	return nil
+}
+
+// count is how many times msg has crashed a handler
+func (j *crashJournal) count(k poisonKey) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if r := j.crashes[k]; r != nil {
+		return r.Crashes
+	}
+	return 0
+}
+
+func (j *crashJournal) lastError(k poisonKey) string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if r := j.crashes[k]; r != nil {
+		return r.LastError
+	}
+	return ""
+}
+
+// start records msg as being handled
+func (j *crashJournal) start(k poisonKey, attempts int) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	e := journalEntry{Op: "start", Topic: k.Topic, Partition: k.Partition, Offset: k.Offset, Attempts: attempts, At: time.Now().UTC()}
+	j.open[k] = e
+	if r := j.crashes[k]; r != nil {
+		r.Seen = e.At
+	}
+	return j.append(e)
+}
+
+// end records that the handler returned and gives the message's crash count
+func (j *crashJournal) end(k poisonKey, crashed, ok bool, reason string) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now().UTC()
+	delete(j.open, k)
+	n := 0
+	switch {
+	case ok:
+		delete(j.crashes, k)
+	case crashed:
+		n = j.crash(k, reason, now)
+	default:
+		if r := j.crashes[k]; r != nil {
+			n = r.Crashes
+		}
+	}
+
+	err := j.append(journalEntry{Op: "end", Topic: k.Topic, Partition: k.Partition, Offset: k.Offset, Crashed: crashed, OK: ok, Error: reason, At: now})
+	return n, err
+}
+
+// forget drops a message that left the worker for good (quarantined)
+func (j *crashJournal) forget(k poisonKey) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	delete(j.crashes, k)
+	return j.append(journalEntry{Op: "end", Topic: k.Topic, Partition: k.Partition, Offset: k.Offset, OK: true, At: time.Now().UTC()})
+}
+
+func (j *crashJournal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.f == nil {
+		return nil
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
+
+// ---- Worker side ----
+
+func (w *Worker) poisonKey(msg ConsumeMessage) poisonKey {
+	return poisonKey{Topic: w.opt.Topic, Partition: msg.Partition, Offset: msg.Offset}
+}
+
+// handle runs the handler. With Poison set a panic is recovered into a *PanicError,
+// the call is bracketed in the crash journal, and crashes is the message's count after a panic
+func (w *Worker) handle(ctx context.Context, msg ConsumeMessage) (crashes int, err error) {
+	if w.poison == nil {
+		return 0, w.h.Handle(ctx, msg)
+	}
+
+	k := w.poisonKey(msg)
+	if jerr := w.poison.start(k, msg.Attempts); jerr != nil {
+		w.report(jerr)
+	}
+
+	func() {
+		defer func() {
+			if v := recover(); v != nil {
+				err = &PanicError{Value: v, Stack: debug.Stack()}
+			}
+		}()
+		err = w.h.Handle(ctx, msg)
+	}()
+
+	var pe *PanicError
+	crashed := errors.As(err, &pe)
+	reason := ""
+	if crashed {
+		reason = fmt.Sprint(pe.Value)
+		w.log.LogAttrs(ctx, slog.LevelError, "driftq handler panicked",
+			append(w.c.messageLogAttrs(msg), slog.String("panic", reason))...)
+	}
+
+	crashes, jerr := w.poison.end(k, crashed, err == nil, reason)
+	if jerr != nil {
+		w.report(jerr)
+	}
+	if !crashed {
+		crashes = 0
+	}
+	return crashes, err
+}
+
+// poisoned reports whether msg already crashed its handler MaxCrashes times
+func (w *Worker) poisoned(msg ConsumeMessage) (int, bool) {
+	if w.poison == nil {
+		return 0, false
+	}
+	n := w.poison.count(w.poisonKey(msg))
+	return n, n >= w.poison.cfg.MaxCrashes
+}
+
+// quarantine parks msg on the poison topic and acks it; if that fails the message is nacked so it isn't lost
+func (w *Worker) quarantine(ctx, hctx context.Context, ev MessageEvent, crashes int) (settleOutcome, string) {
+	msg := ev.Message
+	k := w.poisonKey(msg)
+	reason := fmt.Sprintf("%s: crashed %d times", ErrPoisonMessage, crashes)
+	if last := w.poison.lastError(k); last != "" {
+		reason += ": " + last
+	}
+	if w.vetoGiveUp(hctx, ev, StepQuarantined, reason, ErrPoisonMessage) {
+		w.nack(ctx, ev, reason, 0)
+		return settledNack, reason
+	}
+
+	handler := ""
+	if w.dlq != nil {
+		handler = w.dlq.HandlerName
+	}
+	err := w.park(ctx, w.poison.cfg.Topic, "poison", handler, msg, reason)
+	w.metrics.quarantined.Add(ctx, 1, w.metricAttrs())
+	w.hookQuarantine(ctx, QuarantineEvent{MessageEvent: ev, Crashes: crashes, Topic: w.poison.cfg.Topic, Err: err})
+
+	if err != nil {
+		w.log.LogAttrs(ctx, slog.LevelError, "driftq quarantine failed",
+			append(w.c.messageLogAttrs(msg), slog.String("poison_topic", w.poison.cfg.Topic), slog.String("error", err.Error()))...)
+		w.report(err)
+		w.nack(ctx, ev, reason, 0)
+		return settledNack, reason
+	}
+
+	w.log.LogAttrs(ctx, slog.LevelWarn, "driftq message quarantined",
+		append(w.c.messageLogAttrs(msg), slog.String("poison_topic", w.poison.cfg.Topic), slog.Int("crashes", crashes))...)
+	if jerr := w.poison.forget(k); jerr != nil {
+		w.report(jerr)
+	}
+	w.ack(ctx, ev)
+	return settledQuarantine, reason
+}
@@ -0,0 +1,169 @@
+package driftq
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWorker_PoisonQuarantinesAfterPanics(t *testing.T) {
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(
+		`{"partition":0,"offset":1,"attempts":1,"value":"boom"}`,
+		`{"partition":0,"offset":1,"attempts":2,"value":"boom"}`,
+		`{"partition":0,"offset":2,"attempts":1,"value":"ok"}`,
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var quarantined []QuarantineEvent
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		Poison:  &PoisonConfig{Topic: "demo.poison", MaxCrashes: 2},
+		Hooks: WorkerHooks{
+			OnQuarantine: func(ctx context.Context, ev QuarantineEvent) { quarantined = append(quarantined, ev) },
+		},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			if msg.Value == "boom" {
+				panic("nil map")
+			}
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.nacks) != 1 || s.nacks[0].Offset != 1 || !strings.Contains(s.nacks[0].Reason, "nil map") {
+		t.Fatalf("expected the first panic to be nacked, got %#v", s.nacks)
+	}
+	if len(s.acks) != 2 || len(s.produced) != 1 || s.produced[0].Topic != "demo.poison" {
+		t.Fatalf("expected the second panic to be quarantined: %d acks, produced %#v", len(s.acks), s.produced)
+	}
+	if !strings.HasPrefix(s.prodKeys[0], "poison:") {
+		t.Fatalf("unexpected idempotency key %q", s.prodKeys[0])
+	}
+
+	rec, err := DecodeDeadLetter(ConsumeMessage{Value: s.produced[0].Value})
+	if err != nil || !strings.Contains(rec.LastError, "crashed 2 times: nil map") {
+		t.Fatalf("unexpected poison record %#v (err=%v)", rec, err)
+	}
+	if len(quarantined) != 1 || quarantined[0].Crashes != 2 || quarantined[0].Err != nil {
+		t.Fatalf("unexpected OnQuarantine calls %+v", quarantined)
+	}
+}
+
+func TestWorker_PoisonJournalDetectsCrashOnRestart(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "demo.journal")
+
+	// What a worker killed while handling offset 5 leaves behind
+	at := time.Now().UTC().Format(time.RFC3339Nano)
+	left := `{"op":"start","topic":"demo","partition":0,"offset":5,"attempts":1,"at":"` + at + `"}` + "\n"
+	if err := os.WriteFile(journal, []byte(left), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(
+		`{"partition":0,"offset":5,"attempts":2,"value":"oom"}`,
+		`{"partition":0,"offset":6,"attempts":1,"value":"ok"}`,
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var handled []int64
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		Poison:  &PoisonConfig{Topic: "demo.poison", Journal: journal, MaxCrashes: 1},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			handled = append(handled, msg.Offset)
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(handled) != 1 || handled[0] != 6 {
+		t.Fatalf("expected only offset 6 to be handled, got %v", handled)
+	}
+	if len(s.produced) != 1 || s.produced[0].Topic != "demo.poison" || len(s.acks) != 2 {
+		t.Fatalf("expected offset 5 to be quarantined: %d acks, produced %#v", len(s.acks), s.produced)
+	}
+	rec, err := DecodeDeadLetter(ConsumeMessage{Value: s.produced[0].Value})
+	if err != nil || rec.Offset != 5 || !strings.Contains(rec.LastError, "in flight") {
+		t.Fatalf("unexpected poison record %#v (err=%v)", rec, err)
+	}
+
+	// Both messages are done with, so a restart has nothing to count
+	j := newCrashJournal(PoisonConfig{Journal: journal}.withDefaults())
+	if err := j.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	defer j.close()
+	if len(j.crashes) != 0 || len(j.open) != 0 {
+		t.Fatalf("expected a clean journal, got crashes=%v open=%v", j.crashes, j.open)
+	}
+}
+
+func TestWorker_PoisonIgnoresNackedRedeliveries(t *testing.T) {
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(
+		// Nacked four times before this worker started: retries, not crashes
+		`{"partition":0,"offset":1,"attempts":5,"value":"retry","last_error":"boom"}`,
+		`{"partition":0,"offset":2,"attempts":3,"value":"retry"}`,
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var handled []int64
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		Poison:  &PoisonConfig{Topic: "demo.poison", MaxCrashes: 1},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			handled = append(handled, msg.Offset)
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(handled) != 2 || len(s.produced) != 0 || len(s.acks) != 2 {
+		t.Fatalf("expected both redeliveries handled: handled %v, produced %#v", handled, s.produced)
+	}
+}
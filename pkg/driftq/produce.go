@@ -3,11 +3,21 @@ package driftq
 import (
 	"context"
 	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func (c *Client) Produce(ctx context.Context, req ProduceRequest) (ProduceResponse, error) {
 	var out ProduceResponse
 
+	if err := c.checkProduceDeadline(ctx, req); err != nil {
+		return out, err
+	}
+
+	if !c.cfg.Tracing.Disable {
+		req.Envelope = injectTraceContext(ctx, req.Envelope)
+	}
+
 	hdr := make(http.Header)
 	if req.Envelope != nil {
 		if k := req.Envelope.IdempotencyKey; k != "" {
@@ -16,5 +26,16 @@ func (c *Client) Produce(ctx context.Context, req ProduceRequest) (ProduceRespon
 	}
 
 	err := c.doJSONWithHeaders(ctx, http.MethodPost, "/v1/produce", nil, hdr, req, &out)
+
+	var extra []attribute.KeyValue
+	if err != nil {
+		extra = append(extra, attribute.String(attrErrorType, errorType(err)))
+	}
+	opt := messagingAttrs(req.Topic, extra...)
+	c.metrics.sentMessages.Add(ctx, 1, opt)
+	if err == nil {
+		c.metrics.sentBytes.Add(ctx, int64(len(req.Value)), opt)
+	}
+
 	return out, err
 }
@@ -0,0 +1,459 @@
+package driftq
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RunTrackerConfig configures a RunTracker
+type RunTrackerConfig struct {
+	Client *Client
+
+	// Consume reads the status topic (see StatusConfig). Topic, Group and Owner are required for Run
+	Consume ConsumeOptions
+
+	// Path is an optional journal file. Every applied event is appended to it and the view is
+	// rebuilt from it by NewRunTracker, so a restarted tracker doesn't need to re-read the topic.
+	// It is compacted to one snapshot per run on open and every so often after
+	Path string
+
+	// Retention drops done and failed runs once they haven't changed for this long (default 24h).
+	// Running runs are kept; Forget drops a run right away
+	Retention time.Duration
+
+	OnError func(error)
+}
+
+func (c RunTrackerConfig) withDefaults() RunTrackerConfig {
+	if c.Retention <= 0 {
+		c.Retention = 24 * time.Hour
+	}
+	return c
+}
+
+// RunState summarizes a run from the step events seen so far
+type RunState string
+
+const (
+	RunRunning RunState = "running" // some step is in flight or will be redelivered
+	RunFailed  RunState = "failed"  // some step was dead-lettered or failed permanently
+	RunDone    RunState = "done"    // every step seen so far has settled without failing
+)
+
+// RunStep is the latest known status of one step of a run
+type RunStep struct {
+	Step         string        `json:"step"`
+	ParentStepID string        `json:"parent_step_id,omitempty"`
+	Status       StepStatus    `json:"status"`
+	Reason       string        `json:"reason,omitempty"`
+	Attempts     int           `json:"attempts"`
+	Topic        string        `json:"topic"`
+	StartedAt    time.Time     `json:"started_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Duration     time.Duration `json:"duration,omitempty"` // last handler run time
+}
+
+// RunStatus is the tracked view of one run
+type RunStatus struct {
+	RunID     string    `json:"run_id"`
+	TenantID  string    `json:"tenant_id,omitempty"`
+	State     RunState  `json:"state"`
+	Steps     []RunStep `json:"steps"` // in the order they were first seen
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Step returns the named step
+func (r RunStatus) Step(name string) (RunStep, bool) {
+	for _, s := range r.Steps {
+		if s.Step == name {
+			return s, true
+		}
+	}
+	return RunStep{}, false
+}
+
+func (r RunStatus) clone() RunStatus {
+	r.Steps = slices.Clone(r.Steps)
+	return r
+}
+
+// RunFilter selects runs for ListRuns; zero fields match everything
+type RunFilter struct {
+	TenantID string
+	State    RunState
+	Since    time.Time // updated at or after
+	Limit    int       // 0 = no limit
+}
+
+func (f RunFilter) match(r *RunStatus) bool {
+	if f.TenantID != "" && r.TenantID != f.TenantID {
+		return false
+	}
+	if f.State != "" && r.State != f.State {
+		return false
+	}
+	if !f.Since.IsZero() && r.UpdatedAt.Before(f.Since) {
+		return false
+	}
+	return true
+}
+
+// RunTracker keeps a live view of runs built from StepEvents: Run consumes the status topic,
+// Apply adds events directly. It is safe for concurrent use
+type RunTracker struct {
+	cfg RunTrackerConfig
+
+	mu       sync.Mutex
+	runs     map[string]*RunStatus
+	watchers map[string][]chan RunStatus
+	journal  *os.File
+	applied  int // events since the last prune / compaction
+}
+
+// trackerRecord is one journal line: a StepEvent, a compacted run snapshot, or a forgotten run
+type trackerRecord struct {
+	*StepEvent
+	Snapshot *RunStatus `json:"snapshot,omitempty"`
+	Forget   string     `json:"forget,omitempty"`
+}
+
+// NewRunTracker builds a tracker, replaying cfg.Path when it exists
+func NewRunTracker(cfg RunTrackerConfig) (*RunTracker, error) {
+	cfg = cfg.withDefaults()
+	t := &RunTracker{
+		cfg:      cfg,
+		runs:     map[string]*RunStatus{},
+		watchers: map[string][]chan RunStatus{},
+	}
+	if cfg.Path == "" {
+		return t, nil
+	}
+
+	if err := t.replay(cfg.Path); err != nil {
+		return nil, fmt.Errorf("run tracker: %w", err)
+	}
+	t.prune(time.Now())
+	if err := t.compact(); err != nil {
+		return nil, fmt.Errorf("run tracker: %w", err)
+	}
+	return t, nil
+}
+
+func (t *RunTracker) replay(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var rec trackerRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			// A torn last line after a crash; the event is still on the topic
+			continue
+		}
+		switch {
+		case rec.Snapshot != nil:
+			r := rec.Snapshot.clone()
+			t.runs[r.RunID] = &r
+		case rec.Forget != "":
+			delete(t.runs, rec.Forget)
+		case rec.StepEvent != nil && rec.RunID != "":
+			t.apply(*rec.StepEvent)
+		}
+	}
+	return sc.Err()
+}
+
+// compact rewrites the journal as one snapshot per run and reopens it for appending; t.mu must be held
+func (t *RunTracker) compact() error {
+	if t.journal != nil {
+		t.journal.Close()
+		t.journal = nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(t.cfg.Path), ".runs-*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, r := range t.runs {
+		_ = enc.Encode(trackerRecord{Snapshot: r})
+	}
+
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), t.cfg.Path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	t.journal, err = os.OpenFile(t.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	t.applied = 0
+	return err
+}
+
+// prune drops settled runs that haven't changed for Retention; t.mu must be held
+func (t *RunTracker) prune(now time.Time) {
+	cutoff := now.Add(-t.cfg.Retention)
+	for id, r := range t.runs {
+		if r.State != RunRunning && r.UpdatedAt.Before(cutoff) {
+			delete(t.runs, id)
+		}
+	}
+}
+
+// Forget drops runID from the view (and the journal), e.g. once its result has been read
+func (t *RunTracker) Forget(runID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.runs, runID)
+	return t.write(trackerRecord{Forget: runID})
+}
+
+// write appends rec to the journal, if there is one; t.mu must be held
+func (t *RunTracker) write(rec trackerRecord) error {
+	if t.journal == nil {
+		return nil
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("run tracker: %w", err)
+	}
+	if _, err := t.journal.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("run tracker: journal: %w", err)
+	}
+	return nil
+}
+
+// Close closes the journal file
+func (t *RunTracker) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.journal == nil {
+		return nil
+	}
+	err := t.journal.Close()
+	t.journal = nil
+	return err
+}
+
+// Run consumes the status topic into the view until ctx is cancelled or the stream ends
+func (t *RunTracker) Run(ctx context.Context) error {
+	if t.cfg.Client == nil {
+		return errors.New("run tracker: Client is required")
+	}
+
+	w, err := NewWorker(WorkerConfig{
+		Client:  t.cfg.Client,
+		Consume: t.cfg.Consume,
+		OnError: t.cfg.OnError,
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			ev, err := DecodeStepEvent(msg)
+			if err != nil {
+				return Permanent(err)
+			}
+			return t.Apply(ev)
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("run tracker: %w", err)
+	}
+	return w.Run(ctx)
+}
+
+// Apply adds one event to the view (journaling it first when Path is set).
+// Repeated and out-of-date events are harmless: an event for an older attempt than the one
+// already recorded is ignored, and a settled status is never replaced by a start of the same attempt
+func (t *RunTracker) Apply(ev StepEvent) error {
+	if ev.RunID == "" || ev.Status == "" {
+		return errors.New("run tracker: event needs RunID and Status")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.write(trackerRecord{StepEvent: &ev}); err != nil {
+		return err
+	}
+
+	r, changed := t.apply(ev)
+	if changed {
+		t.notify(r)
+	}
+
+	// Keep the view and the journal from growing without bound
+	if t.applied++; t.applied >= compactEvery {
+		t.applied = 0
+		t.prune(time.Now())
+		if t.journal != nil {
+			if err := t.compact(); err != nil {
+				return fmt.Errorf("run tracker: journal: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// apply updates the view; t.mu must be held (or t not shared yet)
+func (t *RunTracker) apply(ev StepEvent) (*RunStatus, bool) {
+	at := ev.At
+	if at.IsZero() {
+		at = time.Now().UTC()
+	}
+
+	r := t.runs[ev.RunID]
+	if r == nil {
+		r = &RunStatus{RunID: ev.RunID, StartedAt: at}
+		t.runs[ev.RunID] = r
+	}
+	if r.TenantID == "" {
+		r.TenantID = ev.TenantID
+	}
+
+	i := slices.IndexFunc(r.Steps, func(s RunStep) bool { return s.Step == ev.Step })
+	if i < 0 {
+		r.Steps = append(r.Steps, RunStep{Step: ev.Step, ParentStepID: ev.ParentStepID, Topic: ev.Topic, StartedAt: at})
+		i = len(r.Steps) - 1
+	}
+	s := &r.Steps[i]
+
+	if s.Status != "" {
+		if ev.Attempts < s.Attempts {
+			return r, false
+		}
+		if ev.Attempts == s.Attempts && (ev.Status == s.Status || ev.Status == StepStarted) {
+			// a repeat, or a start arriving after its attempt already settled
+			return r, false
+		}
+	}
+
+	s.Status, s.Reason, s.Attempts, s.UpdatedAt = ev.Status, ev.Reason, ev.Attempts, at
+	if ev.DurationMS > 0 {
+		s.Duration = time.Duration(ev.DurationMS) * time.Millisecond
+	}
+	if at.After(r.UpdatedAt) {
+		r.UpdatedAt = at
+	}
+	r.State = runState(r.Steps)
+	return r, true
+}
+
+func runState(steps []RunStep) RunState {
+	state := RunDone
+	for _, s := range steps {
+		switch {
+		case s.Status == StepDeadLettered || s.Status == StepFailed || s.Status == StepQuarantined:
+			return RunFailed
+		case !s.Status.Settled():
+			state = RunRunning
+		}
+	}
+	return state
+}
+
+// GetRun returns the current view of runID
+func (t *RunTracker) GetRun(runID string) (RunStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r := t.runs[runID]
+	if r == nil {
+		return RunStatus{}, false
+	}
+	return r.clone(), true
+}
+
+// ListRuns returns the runs matching f, most recently updated first
+func (t *RunTracker) ListRuns(f RunFilter) []RunStatus {
+	t.mu.Lock()
+	out := make([]RunStatus, 0, len(t.runs))
+	for _, r := range t.runs {
+		if f.match(r) {
+			out = append(out, r.clone())
+		}
+	}
+	t.mu.Unlock()
+
+	slices.SortFunc(out, func(a, b RunStatus) int {
+		if c := b.UpdatedAt.Compare(a.UpdatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.RunID, b.RunID)
+	})
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out
+}
+
+// Watch streams runID's status: the current one (if the run is known) and then every change,
+// until ctx is done and the channel is closed. A slow reader only misses intermediate states;
+// the latest one is always delivered
+func (t *RunTracker) Watch(ctx context.Context, runID string) <-chan RunStatus {
+	ch := make(chan RunStatus, 1)
+
+	t.mu.Lock()
+	if r := t.runs[runID]; r != nil {
+		ch <- r.clone()
+	}
+	t.watchers[runID] = append(t.watchers[runID], ch)
+	t.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		ws := slices.DeleteFunc(t.watchers[runID], func(c chan RunStatus) bool { return c == ch })
+		if len(ws) == 0 {
+			delete(t.watchers, runID)
+		} else {
+			t.watchers[runID] = ws
+		}
+		close(ch)
+	}()
+	return ch
+}
+
+// notify hands r to runID's watchers, replacing a state they haven't read yet; t.mu must be held
+func (t *RunTracker) notify(r *RunStatus) {
+	for _, ch := range t.watchers[r.RunID] {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- r.clone()
+	}
+}
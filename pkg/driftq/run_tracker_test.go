@@ -0,0 +1,213 @@
+package driftq
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWorker_PublishesStatusIntoRunTracker(t *testing.T) {
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(
+		`{"partition":0,"offset":1,"attempts":1,"value":"ok","envelope":{"run_id":"r1","step_id":"fetch","tenant_id":"acme"}}`,
+		`{"partition":0,"offset":2,"attempts":1,"value":"retry","envelope":{"run_id":"r1","step_id":"parse","parent_step_id":"fetch","tenant_id":"acme"}}`,
+		`{"partition":0,"offset":3,"attempts":3,"value":"fail","envelope":{"run_id":"r2","step_id":"parse","retry_policy":{"max_attempts":3}}}`,
+		`{"partition":0,"offset":4,"attempts":1,"value":"ok"}`, // not part of a run: no events
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	wk, err := NewWorker(WorkerConfig{
+		Client:     c,
+		Consume:    ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		DeadLetter: &DeadLetterConfig{Topic: "demo.dlq"},
+		Status:     &StatusConfig{Topic: "demo.status"},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			if msg.Value == "ok" {
+				return nil
+			}
+			return errors.New("bad input")
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "runs.jsonl")
+	tr, err := NewRunTracker(RunTrackerConfig{Path: path})
+	if err != nil {
+		t.Fatalf("NewRunTracker: %v", err)
+	}
+	defer tr.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watch := tr.Watch(ctx, "r1")
+
+	s.mu.Lock()
+	var events []StepEvent
+	for i, p := range s.produced {
+		if p.Topic != "demo.status" {
+			continue
+		}
+		ev, err := DecodeStepEvent(ConsumeMessage{Value: p.Value})
+		if err != nil {
+			t.Fatalf("DecodeStepEvent: %v", err)
+		}
+		if p.Key != ev.RunID || s.prodKeys[i] == "" {
+			t.Fatalf("status event must be keyed by run and idempotent: key=%q idem=%q", p.Key, s.prodKeys[i])
+		}
+		events = append(events, ev)
+	}
+	s.mu.Unlock()
+
+	if len(events) != 6 {
+		t.Fatalf("expected a start and a settle event per run message, got %d: %+v", len(events), events)
+	}
+	for _, ev := range events {
+		if err := tr.Apply(ev); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+	}
+	// A redelivered duplicate and a late start change nothing
+	_ = tr.Apply(events[1])
+	_ = tr.Apply(events[0])
+
+	r1, ok := tr.GetRun("r1")
+	if !ok || r1.State != RunRunning || r1.TenantID != "acme" || len(r1.Steps) != 2 {
+		t.Fatalf("unexpected r1 %+v", r1)
+	}
+	if st, _ := r1.Step("fetch"); st.Status != StepSucceeded {
+		t.Fatalf("expected fetch to have succeeded, got %+v", st)
+	}
+	if st, _ := r1.Step("parse"); st.Status != StepNacked || st.Reason != "bad input" || st.ParentStepID != "fetch" {
+		t.Fatalf("expected parse to be nacked, got %+v", st)
+	}
+
+	r2, _ := tr.GetRun("r2")
+	if st, _ := r2.Step("parse"); r2.State != RunFailed || st.Status != StepDeadLettered || st.Attempts != 3 {
+		t.Fatalf("expected r2 to have failed, got %+v", r2)
+	}
+
+	if got := tr.ListRuns(RunFilter{State: RunFailed}); len(got) != 1 || got[0].RunID != "r2" {
+		t.Fatalf("unexpected failed runs %+v", got)
+	}
+	if got := tr.ListRuns(RunFilter{TenantID: "acme"}); len(got) != 1 || got[0].RunID != "r1" {
+		t.Fatalf("unexpected acme runs %+v", got)
+	}
+	if got := tr.ListRuns(RunFilter{Limit: 1}); len(got) != 1 {
+		t.Fatalf("expected Limit to cap the list, got %d", len(got))
+	}
+
+	// The watcher may have missed intermediate states, never the latest one
+	select {
+	case got := <-watch:
+		if st, _ := got.Step("parse"); st.Status != StepNacked {
+			t.Fatalf("expected the latest r1 state, got %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("watch delivered nothing")
+	}
+
+	// The redelivery succeeds and the watcher sees the run finish
+	retry := events[3]
+	retry.Attempts, retry.Status, retry.Reason = 2, StepSucceeded, ""
+	if err := tr.Apply(retry); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got := <-watch; got.State != RunDone {
+		t.Fatalf("expected r1 to be done, got %+v", got)
+	}
+	cancel()
+	for range watch {
+	}
+
+	// A restarted tracker rebuilds the same view from its journal
+	tr.Close()
+	tr2, err := NewRunTracker(RunTrackerConfig{Path: path})
+	if err != nil {
+		t.Fatalf("NewRunTracker: %v", err)
+	}
+	defer tr2.Close()
+	if r, _ := tr2.GetRun("r1"); r.State != RunDone || len(r.Steps) != 2 {
+		t.Fatalf("expected r1 from the journal, got %+v", r)
+	}
+	if r, _ := tr2.GetRun("r2"); r.State != RunFailed {
+		t.Fatalf("expected r2 from the journal, got %+v", r)
+	}
+}
+
+func TestRunTracker_CompactsAndForgets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs.jsonl")
+	now := time.Now().UTC()
+
+	tr, err := NewRunTracker(RunTrackerConfig{Path: path})
+	if err != nil {
+		t.Fatalf("NewRunTracker: %v", err)
+	}
+	for _, ev := range []StepEvent{
+		{RunID: "r1", Step: "a", Status: StepStarted, Attempts: 1, At: now},
+		{RunID: "r1", Step: "a", Status: StepNacked, Attempts: 1, At: now},
+		{RunID: "r1", Step: "a", Status: StepStarted, Attempts: 2, At: now},
+		{RunID: "r1", Step: "a", Status: StepSucceeded, Attempts: 2, At: now},
+		{RunID: "r2", Step: "a", Status: StepStarted, Attempts: 1, At: now.Add(-48 * time.Hour)},
+		{RunID: "old", Step: "a", Status: StepSucceeded, Attempts: 1, At: now.Add(-48 * time.Hour)},
+	} {
+		if err := tr.Apply(ev); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+	}
+	tr.Close()
+
+	lines := func() int {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Count(string(b), "\n")
+	}
+
+	// Reopening keeps one snapshot per run and drops the settled run past Retention
+	tr2, err := NewRunTracker(RunTrackerConfig{Path: path})
+	if err != nil {
+		t.Fatalf("NewRunTracker: %v", err)
+	}
+	if n := lines(); n != 2 {
+		t.Fatalf("expected 2 snapshots in the compacted journal, got %d lines", n)
+	}
+	if r, ok := tr2.GetRun("r1"); !ok || r.State != RunDone || r.Steps[0].Attempts != 2 {
+		t.Fatalf("unexpected r1 after compaction %+v", r)
+	}
+	if _, ok := tr2.GetRun("r2"); !ok {
+		t.Fatalf("expected a running run to be kept however old")
+	}
+	if _, ok := tr2.GetRun("old"); ok {
+		t.Fatalf("expected the old settled run to be dropped")
+	}
+
+	if err := tr2.Forget("r1"); err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+	tr2.Close()
+
+	tr3, err := NewRunTracker(RunTrackerConfig{Path: path})
+	if err != nil {
+		t.Fatalf("NewRunTracker: %v", err)
+	}
+	defer tr3.Close()
+	if _, ok := tr3.GetRun("r1"); ok || len(tr3.ListRuns(RunFilter{})) != 1 || lines() != 1 {
+		t.Fatalf("expected only r2 after Forget, got %+v", tr3.ListRuns(RunFilter{}))
+	}
+}
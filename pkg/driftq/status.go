@@ -0,0 +1,152 @@
+package driftq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// StatusConfig turns on step status events for a Worker.
+//
+// The worker produces a StepEvent to Topic when a handler starts and again when the message
+// is settled (succeeded, skipped, nacked, dead-lettered or failed). Messages without an envelope
+// run id publish nothing. Events are keyed by run id, so one run's events stay in order on one
+// partition. A RunTracker turns them into a queryable view.
+// Publishing is best effort: a failed status produce is logged and reported via OnError,
+// it never changes what happens to the message
+type StatusConfig struct {
+	Topic string
+}
+
+// StepStatus is where one step of a run stands
+type StepStatus string
+
+const (
+	StepStarted      StepStatus = "started"
+	StepSucceeded    StepStatus = "succeeded"
+	StepSkipped      StepStatus = "skipped"
+	StepNacked       StepStatus = "nacked" // will be redelivered
+	StepDeadLettered StepStatus = "dead_lettered"
+	StepFailed       StepStatus = "failed"      // permanent error, acked without a DLQ
+	StepQuarantined  StepStatus = "quarantined" // poison message (see PoisonConfig)
+)
+
+// Settled reports whether the step is finished for good (no redelivery is coming)
+func (s StepStatus) Settled() bool {
+	switch s {
+	case StepSucceeded, StepSkipped, StepDeadLettered, StepFailed, StepQuarantined:
+		return true
+	}
+	return false
+}
+
+// StepEvent is the value produced to the status topic
+type StepEvent struct {
+	RunID        string     `json:"run_id"`
+	Step         string     `json:"step"` // envelope StepID, or the topic for messages without one
+	ParentStepID string     `json:"parent_step_id,omitempty"`
+	TenantID     string     `json:"tenant_id,omitempty"`
+	Status       StepStatus `json:"status"`
+	Reason       string     `json:"reason,omitempty"` // handler error for nacked / dead-lettered / failed
+
+	Topic     string `json:"topic"`
+	Group     string `json:"group"`
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Attempts  int    `json:"attempts"`
+
+	At         time.Time `json:"at"`
+	DurationMS int64     `json:"duration_ms,omitempty"` // handler run time (settled events)
+}
+
+// DecodeStepEvent parses a status topic message value back into a StepEvent
+func DecodeStepEvent(msg ConsumeMessage) (StepEvent, error) {
+	var ev StepEvent
+	if err := json.Unmarshal([]byte(msg.Value), &ev); err != nil {
+		return StepEvent{}, fmt.Errorf("decode step event: %w", err)
+	}
+	if ev.RunID == "" || ev.Status == "" {
+		return StepEvent{}, errors.New("decode step event: missing run_id or status")
+	}
+	return ev, nil
+}
+
+func settledStatus(outcome settleOutcome, err error) StepStatus {
+	switch outcome {
+	case settledSkip:
+		return StepSkipped
+	case settledNack:
+		return StepNacked
+	case settledDeadLetter:
+		return StepDeadLettered
+	case settledQuarantine:
+		return StepQuarantined
+	}
+	if err != nil {
+		return StepFailed
+	}
+	return StepSucceeded
+}
+
+func (w *Worker) publishStatus(ctx context.Context, sc *StepContext, msg ConsumeMessage, status StepStatus, reason string, took time.Duration) {
+	// Only messages that belong to a run are tracked: sc.RunID is made up for messages
+	// without one, which would turn every plain message into a run of its own
+	if w.status == nil || sc.RunID == "" || msg.Envelope == nil || msg.Envelope.RunID == "" {
+		return
+	}
+
+	ev := StepEvent{
+		RunID:        sc.RunID,
+		Step:         sc.StepID,
+		ParentStepID: sc.ParentStepID,
+		TenantID:     sc.TenantID,
+		Status:       status,
+		Reason:       reason,
+		Topic:        w.opt.Topic,
+		Group:        w.opt.Group,
+		Partition:    msg.Partition,
+		Offset:       msg.Offset,
+		Attempts:     msg.Attempts,
+		At:           time.Now().UTC(),
+		DurationMS:   took.Milliseconds(),
+	}
+	if ev.Step == "" {
+		ev.Step = w.opt.Topic
+	}
+
+	err := w.produceStatus(ctx, ev)
+	if err != nil {
+		w.log.LogAttrs(ctx, slog.LevelWarn, "driftq status event failed",
+			append(w.c.messageLogAttrs(msg), slog.String("status", string(status)), slog.String("error", err.Error()))...)
+		w.report(err)
+	}
+}
+
+func (w *Worker) produceStatus(ctx context.Context, ev StepEvent) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("status event: %w", err)
+	}
+
+	env := &Envelope{
+		RunID:        ev.RunID,
+		StepID:       ev.Step,
+		ParentStepID: ev.ParentStepID,
+		TenantID:     ev.TenantID,
+		// One event per delivery attempt and status; a retried produce is deduplicated
+		IdempotencyKey: fmt.Sprintf("status:%s:%s:%d:%d:%d:%s", w.opt.Group, w.opt.Topic, ev.Partition, ev.Offset, ev.Attempts, ev.Status),
+	}
+
+	if _, err := w.c.Produce(ctx, ProduceRequest{
+		Topic:    w.status.Topic,
+		Key:      ev.RunID,
+		Value:    string(b),
+		Envelope: env,
+	}); err != nil {
+		return fmt.Errorf("status event to %q: %w", w.status.Topic, err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package driftq
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StepContext is the workflow position of the message being handled, taken from its envelope.
+// The Worker puts one in every handler ctx (see StepFromContext and StepContextFunc).
+//
+// Emit produces the next step of the same run: RunID and TenantID are carried over,
+// the new message gets its own StepID and ParentStepID = this StepID.
+//
+// Emits are keyed idempotently. The key is derived from the run, this step, the emit's
+// position within the handler call (first Emit, second Emit, ...) and its topic, so a redelivered
+// step that emits the same things in the same order re-sends the same keys and the broker
+// drops the duplicates instead of starting the downstream work twice
+type StepContext struct {
+	RunID        string
+	StepID       string
+	ParentStepID string
+	TenantID     string
+	Deadline     time.Time // the message's envelope deadline; zero = none
+
+	c      *Client
+	source string // stable id of this step for keys: run/step, or the source position for root messages
+
+	mu      sync.Mutex
+	emitted int
+}
+
+// NewStepContext builds the StepContext for msg consumed from topic by group.
+// Messages without a RunID start a run whose id is derived from their position, so a redelivery
+// lands in the same run. Workers do this for you; it's exported for handlers run elsewhere (tests, custom loops)
+func NewStepContext(c *Client, topic, group string, msg ConsumeMessage) *StepContext {
+	sc := &StepContext{c: c}
+	if e := msg.Envelope; e != nil {
+		sc.RunID = e.RunID
+		sc.StepID = e.StepID
+		sc.ParentStepID = e.ParentStepID
+		sc.TenantID = e.TenantID
+		if e.Deadline != nil {
+			sc.Deadline = *e.Deadline
+		}
+	}
+
+	pos := fmt.Sprintf("%s:%s:%d:%d", group, topic, msg.Partition, msg.Offset)
+	if sc.RunID == "" {
+		sc.RunID = "run-" + shortHash(pos)
+	}
+
+	if sc.StepID != "" {
+		sc.source = sc.RunID + "/" + sc.StepID
+	} else {
+		sc.source = sc.RunID + "/@" + pos
+	}
+	return sc
+}
+
+type stepCtxKey struct{}
+
+// WithStepContext returns a ctx carrying sc
+func WithStepContext(ctx context.Context, sc *StepContext) context.Context {
+	return context.WithValue(ctx, stepCtxKey{}, sc)
+}
+
+// StepFromContext returns the StepContext the Worker attached to a handler ctx
+func StepFromContext(ctx context.Context) (*StepContext, bool) {
+	sc, ok := ctx.Value(stepCtxKey{}).(*StepContext)
+	return sc, ok && sc != nil
+}
+
+// StepContextFunc is a StepHandler that also gets the message's StepContext
+type StepContextFunc func(ctx context.Context, step *StepContext, msg ConsumeMessage) error
+
+func (f StepContextFunc) Handle(ctx context.Context, msg ConsumeMessage) error {
+	sc, ok := StepFromContext(ctx)
+	if !ok {
+		// Not run by a Worker (and nobody called WithStepContext)
+		return Permanent(ErrNoStepContext)
+	}
+	return f(ctx, sc, msg)
+}
+
+// EmitRequest is a next-step message. Envelope may carry extra fields (Deadline, RetryPolicy,
+// TargetTopic, ...); RunID, ParentStepID and (unless set) StepID, TenantID and IdempotencyKey are filled in.
+// A Deadline later than this step's own (or none) is cut down to it
+type EmitRequest struct {
+	Topic    string
+	Key      string
+	Value    string
+	Envelope *Envelope
+}
+
+// Emit produces value to topic as the next step of this run
+func (s *StepContext) Emit(ctx context.Context, topic, value string) (ProduceResponse, error) {
+	return s.EmitMessage(ctx, EmitRequest{Topic: topic, Value: value})
+}
+
+// EmitMessage is Emit with a key and extra envelope fields
+func (s *StepContext) EmitMessage(ctx context.Context, req EmitRequest) (ProduceResponse, error) {
+	if s.c == nil {
+		return ProduceResponse{}, errors.New("step: StepContext has no Client")
+	}
+	if strings.TrimSpace(req.Topic) == "" {
+		return ProduceResponse{}, errors.New("step: emit topic is required")
+	}
+
+	env := s.NextEnvelope(req.Topic, req.Envelope)
+	return s.c.Produce(ctx, ProduceRequest{Topic: req.Topic, Key: req.Key, Value: req.Value, Envelope: env})
+}
+
+// NextEnvelope returns the envelope the next Emit to topic would use and counts it as emitted.
+// It's for producing a step by other means (a batch, another client) with the same guarantees
+func (s *StepContext) NextEnvelope(topic string, base *Envelope) *Envelope {
+	s.mu.Lock()
+	n := s.emitted
+	s.emitted++
+	s.mu.Unlock()
+
+	var env Envelope
+	if base != nil {
+		env = *base
+	}
+
+	// A redelivery that branches to another topic must not reuse the first delivery's key
+	key := fmt.Sprintf("%s#%d>%s", s.source, n, topic)
+
+	env.RunID = s.RunID
+	env.ParentStepID = s.StepID
+	if env.StepID == "" {
+		env.StepID = "step-" + shortHash(key)
+	}
+	if env.TenantID == "" {
+		env.TenantID = s.TenantID
+	}
+	if env.IdempotencyKey == "" {
+		env.IdempotencyKey = "emit:" + key
+	}
+	// The child gets what is left of this step's budget at most
+	if !s.Deadline.IsZero() && (env.Deadline == nil || env.Deadline.After(s.Deadline)) {
+		dl := s.Deadline
+		env.Deadline = &dl
+	}
+	return &env
+}
+
+// Emitted is how many next steps this handler call has produced (or reserved via NextEnvelope)
+func (s *StepContext) Emitted() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.emitted
+}
+
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,141 @@
+package driftq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestStepContext_EmitFromWorkerIsIdempotentAcrossRedelivery(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		produced []ProduceRequest
+		keys     []string
+	)
+
+	// The same step is delivered twice (a redelivery after a lost ack)
+	line := `{"partition":0,"offset":7,"attempts":%d,"value":"v","envelope":{"run_id":"r1","step_id":"s1","parent_step_id":"s0","tenant_id":"acme"}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/consume":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			_, _ = w.Write([]byte(fmt.Sprintf(line, 1) + "\n" + fmt.Sprintf(line, 2) + "\n"))
+		case "/v1/produce":
+			var req ProduceRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			mu.Lock()
+			produced = append(produced, req)
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			mu.Unlock()
+			_ = json.NewEncoder(w).Encode(ProduceResponse{Status: "produced", Topic: req.Topic})
+		case "/v1/ack", "/v1/nack":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL, Tracing: TracingConfig{Disable: true}})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "steps", Group: "g", Owner: "w1"},
+		Handler: StepContextFunc(func(ctx context.Context, step *StepContext, msg ConsumeMessage) error {
+			if step.RunID != "r1" || step.StepID != "s1" || step.ParentStepID != "s0" || step.TenantID != "acme" {
+				t.Errorf("unexpected step context %+v", step)
+			}
+			if _, err := step.Emit(ctx, "charge", "c"); err != nil {
+				return err
+			}
+			_, err := step.EmitMessage(ctx, EmitRequest{Topic: "notify", Key: "k", Value: "n"})
+			return err
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(produced) != 4 {
+		t.Fatalf("expected 4 produces (2 per delivery), got %d", len(produced))
+	}
+	for i, p := range produced {
+		e := p.Envelope
+		if e == nil || e.RunID != "r1" || e.ParentStepID != "s1" || e.TenantID != "acme" || e.StepID == "" {
+			t.Fatalf("produce %d: unexpected envelope %#v", i, e)
+		}
+		if keys[i] == "" || keys[i] != e.IdempotencyKey {
+			t.Fatalf("produce %d: header key %q, envelope key %q", i, keys[i], e.IdempotencyKey)
+		}
+	}
+
+	// Redelivery re-emits with identical keys and step ids; the two emits differ from each other
+	if keys[0] != keys[2] || keys[1] != keys[3] || keys[0] == keys[1] {
+		t.Fatalf("expected stable, distinct keys per emit, got %v", keys)
+	}
+	if produced[0].Envelope.StepID != produced[2].Envelope.StepID || produced[0].Envelope.StepID == produced[1].Envelope.StepID {
+		t.Fatalf("expected stable, distinct step ids")
+	}
+	if produced[1].Topic != "notify" || produced[1].Key != "k" {
+		t.Fatalf("unexpected second emit %#v", produced[1])
+	}
+}
+
+func TestStepContext_RootMessagesAndOverrides(t *testing.T) {
+	root := ConsumeMessage{Partition: 1, Offset: 42}
+
+	a := NewStepContext(nil, "orders", "billing", root)
+	b := NewStepContext(nil, "orders", "billing", root)
+	other := NewStepContext(nil, "orders", "billing", ConsumeMessage{Partition: 1, Offset: 43})
+
+	if a.RunID == "" || a.RunID != b.RunID || a.RunID == other.RunID {
+		t.Fatalf("root run ids should be stable per position: %q %q %q", a.RunID, b.RunID, other.RunID)
+	}
+	if a.NextEnvelope("next", nil).IdempotencyKey != b.NextEnvelope("next", nil).IdempotencyKey {
+		t.Fatalf("root emits should be keyed by position")
+	}
+
+	// Same position, different topic: a redelivery that branches elsewhere gets its own key
+	first, redelivered := NewStepContext(nil, "orders", "billing", root), NewStepContext(nil, "orders", "billing", root)
+	if k := first.NextEnvelope("next", nil).IdempotencyKey; k == redelivered.NextEnvelope("refunds", nil).IdempotencyKey {
+		t.Fatalf("emits to different topics share key %q", k)
+	}
+
+	// Explicit envelope fields win; run linkage is always set
+	env := a.NextEnvelope("next", &Envelope{StepID: "charge", TenantID: "other", TargetTopic: "t", RunID: "ignored"})
+	if env.StepID != "charge" || env.TenantID != "other" || env.TargetTopic != "t" || env.RunID != a.RunID || env.ParentStepID != "" {
+		t.Fatalf("unexpected envelope %#v", env)
+	}
+	if a.Emitted() != 2 {
+		t.Fatalf("expected 2 emitted, got %d", a.Emitted())
+	}
+
+	if _, err := a.Emit(context.Background(), "x", "v"); err == nil {
+		t.Fatalf("expected an error without a Client")
+	}
+}
+
+func TestStepContextFunc_OutsideWorker(t *testing.T) {
+	h := StepContextFunc(func(ctx context.Context, step *StepContext, msg ConsumeMessage) error { return nil })
+
+	err := h.Handle(context.Background(), ConsumeMessage{})
+	if !errors.Is(err, ErrNoStepContext) || !IsPermanent(err) {
+		t.Fatalf("expected a permanent ErrNoStepContext, got %v", err)
+	}
+
+	ctx := WithStepContext(context.Background(), NewStepContext(nil, "t", "g", ConsumeMessage{}))
+	if err := h.Handle(ctx, ConsumeMessage{}); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+}
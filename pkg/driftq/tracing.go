@@ -0,0 +1,135 @@
+package driftq
+
+import (
+	"context"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ConsumerSpanMode decides how the Worker's span relates to the producer's span
+type ConsumerSpanMode int
+
+const (
+	// ConsumerSpanChild continues the producer's trace (the consumer span is its child)
+	ConsumerSpanChild ConsumerSpanMode = iota
+	// ConsumerSpanLink starts a new trace per message, linked to the producer's span
+	ConsumerSpanLink
+)
+
+// Messaging semantic-convention attribute names (plus a few driftq-specific ones)
+const (
+	attrMessagingSystem        = "messaging.system"
+	attrMessagingOperationName = "messaging.operation.name"
+	attrMessagingOperationType = "messaging.operation.type"
+	attrMessagingDestination   = "messaging.destination.name"
+	attrMessagingPartition     = "messaging.destination.partition.id"
+	attrMessagingConsumerGroup = "messaging.consumer.group.name"
+	attrMessagingOffset        = "messaging.driftq.message.offset"
+	attrMessagingAttempts      = "messaging.driftq.message.attempts"
+	attrMessagingOutcome       = "messaging.driftq.outcome"
+
+	messagingSystem = "driftq"
+)
+
+// settleOutcome is what the Worker finally did with a message
+type settleOutcome string
+
+const (
+	settledAck        settleOutcome = "ack"
+	settledNack       settleOutcome = "nack"
+	settledDeadLetter settleOutcome = "dead_letter"
+	settledSkip       settleOutcome = "skip"
+	settledQuarantine settleOutcome = "quarantine"
+)
+
+// injectTraceContext returns env with the current trace context in TraceContext.
+// The caller's envelope is never mutated; nil is returned unchanged when ctx carries no trace
+func injectTraceContext(ctx context.Context, env *Envelope) *Envelope {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return env
+	}
+
+	out := &Envelope{}
+	if env != nil {
+		cp := *env
+		out = &cp
+	}
+
+	tc := make(map[string]string, len(out.TraceContext)+len(carrier))
+	for k, v := range out.TraceContext {
+		tc[k] = v
+	}
+	for k, v := range carrier {
+		tc[k] = v
+	}
+	out.TraceContext = tc
+	return out
+}
+
+// extractTraceContext returns ctx carrying the producer's span context from msg (if any)
+func extractTraceContext(ctx context.Context, msg ConsumeMessage) context.Context {
+	if msg.Envelope == nil || len(msg.Envelope.TraceContext) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(msg.Envelope.TraceContext))
+}
+
+// startConsumerSpan starts the per-message consumer span. Like TracingMiddleware it only creates spans
+// with StartSpans set; otherwise the handler ctx just carries the producer's span context (ConsumerSpanChild)
+// so logs and emitted messages stay in its trace. The returned span is a no-op when none was started
+func (w *Worker) startConsumerSpan(ctx context.Context, msg ConsumeMessage) (context.Context, trace.Span) {
+	noSpan := trace.SpanFromContext(context.Background())
+	if w.tracing.Disable {
+		return ctx, noSpan
+	}
+
+	if !w.tracing.StartSpans {
+		producer := trace.SpanContextFromContext(extractTraceContext(context.Background(), msg))
+		if producer.IsValid() && w.tracing.ConsumerSpan == ConsumerSpanChild {
+			ctx = trace.ContextWithRemoteSpanContext(ctx, producer)
+		}
+		return ctx, noSpan
+	}
+
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String(attrMessagingSystem, messagingSystem),
+			attribute.String(attrMessagingOperationName, "process"),
+			attribute.String(attrMessagingOperationType, "process"),
+			attribute.String(attrMessagingDestination, w.opt.Topic),
+			attribute.String(attrMessagingPartition, strconv.Itoa(msg.Partition)),
+			attribute.String(attrMessagingConsumerGroup, w.opt.Group),
+			attribute.Int64(attrMessagingOffset, msg.Offset),
+			attribute.Int(attrMessagingAttempts, msg.Attempts),
+		),
+	}
+
+	producer := trace.SpanContextFromContext(extractTraceContext(context.Background(), msg))
+	if producer.IsValid() {
+		switch w.tracing.ConsumerSpan {
+		case ConsumerSpanLink:
+			opts = append(opts, trace.WithNewRoot(), trace.WithLinks(trace.Link{SpanContext: producer}))
+		default:
+			ctx = trace.ContextWithRemoteSpanContext(ctx, producer)
+		}
+	}
+
+	return otel.Tracer(w.tracing.TracerName).Start(ctx, "process "+w.opt.Topic, opts...)
+}
+
+func endConsumerSpan(span trace.Span, outcome settleOutcome, handlerErr error) {
+	span.SetAttributes(attribute.String(attrMessagingOutcome, string(outcome)))
+	if handlerErr != nil && outcome != settledSkip {
+		span.RecordError(handlerErr)
+		span.SetStatus(codes.Error, handlerErr.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,214 @@
+package driftq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func withTestTracing(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	oldProp := otel.GetTextMapPropagator()
+	oldTP := otel.GetTracerProvider()
+
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	t.Cleanup(func() {
+		otel.SetTextMapPropagator(oldProp)
+		otel.SetTracerProvider(oldTP)
+	})
+	return rec
+}
+
+var testProducerSpan = trace.NewSpanContext(trace.SpanContextConfig{
+	TraceID:    trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+	SpanID:     trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+	TraceFlags: trace.FlagsSampled,
+})
+
+func TestProduce_InjectsTraceContextIntoEnvelope(t *testing.T) {
+	withTestTracing(t)
+
+	var got ProduceRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_ = json.NewEncoder(w).Encode(ProduceResponse{Status: "ok", Topic: got.Topic})
+	}))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	env := &Envelope{TenantID: "t1"}
+	ctx := trace.ContextWithSpanContext(context.Background(), testProducerSpan)
+	if _, err := c.Produce(ctx, ProduceRequest{Topic: "demo", Value: "v", Envelope: env}); err != nil {
+		t.Fatalf("Produce: %v", err)
+	}
+
+	if got.Envelope == nil || got.Envelope.TenantID != "t1" || got.Envelope.TraceContext["traceparent"] == "" {
+		t.Fatalf("expected traceparent in envelope, got %#v", got.Envelope)
+	}
+
+	if env.TraceContext != nil {
+		t.Fatalf("caller's envelope was mutated: %#v", env)
+	}
+}
+
+func runTracedWorker(t *testing.T, tracing TracingConfig, handlerErr error) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	rec := withTestTracing(t)
+
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(trace.ContextWithSpanContext(context.Background(), testProducerSpan), carrier)
+
+	m := ConsumeMessage{Partition: 2, Offset: 9, Attempts: 3, Value: "v", Envelope: &Envelope{TraceContext: carrier}}
+	line, _ := json.Marshal(m)
+
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(string(line)))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL, Tracing: tracing})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			if !trace.SpanContextFromContext(ctx).IsValid() {
+				t.Errorf("expected handler ctx to carry the consumer span")
+			}
+			return handlerErr
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	for _, sp := range rec.Ended() {
+		if sp.SpanKind() == trace.SpanKindConsumer {
+			return sp
+		}
+	}
+	t.Fatalf("no consumer span recorded")
+	return nil
+}
+
+func spanAttr(sp sdktrace.ReadOnlySpan, key string) attribute.Value {
+	for _, kv := range sp.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestWorker_ConsumerSpanIsChildOfProducer(t *testing.T) {
+	sp := runTracedWorker(t, TracingConfig{StartSpans: true}, nil)
+
+	if sp.Parent().SpanID() != testProducerSpan.SpanID() || sp.SpanContext().TraceID() != testProducerSpan.TraceID() {
+		t.Fatalf("expected child of producer span, parent=%v trace=%v", sp.Parent(), sp.SpanContext().TraceID())
+	}
+
+	if sp.Name() != "process demo" {
+		t.Fatalf("unexpected span name %q", sp.Name())
+	}
+
+	checks := map[string]string{
+		attrMessagingSystem:        "driftq",
+		attrMessagingDestination:   "demo",
+		attrMessagingPartition:     "2",
+		attrMessagingConsumerGroup: "g",
+		attrMessagingOutcome:       "ack",
+	}
+	for k, want := range checks {
+		if got := spanAttr(sp, k).AsString(); got != want {
+			t.Fatalf("attribute %s = %q, want %q", k, got, want)
+		}
+	}
+
+	if spanAttr(sp, attrMessagingOffset).AsInt64() != 9 || spanAttr(sp, attrMessagingAttempts).AsInt64() != 3 {
+		t.Fatalf("unexpected offset/attempts attributes: %v", sp.Attributes())
+	}
+}
+
+func TestWorker_ConsumerSpanLinksProducer(t *testing.T) {
+	sp := runTracedWorker(t, TracingConfig{StartSpans: true, ConsumerSpan: ConsumerSpanLink}, errors.New("boom"))
+
+	if sp.SpanContext().TraceID() == testProducerSpan.TraceID() {
+		t.Fatalf("expected a new trace in link mode")
+	}
+
+	if len(sp.Links()) != 1 || sp.Links()[0].SpanContext.SpanID() != testProducerSpan.SpanID() {
+		t.Fatalf("expected a link to the producer span, got %v", sp.Links())
+	}
+
+	if spanAttr(sp, attrMessagingOutcome).AsString() != "nack" || sp.Status().Code != codes.Error {
+		t.Fatalf("expected nack outcome with error status, got %v %v", sp.Attributes(), sp.Status())
+	}
+}
+
+func TestWorker_NoConsumerSpanWithoutStartSpans(t *testing.T) {
+	rec := withTestTracing(t)
+
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(trace.ContextWithSpanContext(context.Background(), testProducerSpan), carrier)
+	line, _ := json.Marshal(ConsumeMessage{Offset: 1, Attempts: 1, Value: "v", Envelope: &Envelope{TraceContext: carrier}})
+
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(string(line)))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var inTrace trace.SpanContext
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			inTrace = trace.SpanContextFromContext(ctx)
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	for _, sp := range rec.Ended() {
+		if sp.SpanKind() == trace.SpanKindConsumer {
+			t.Fatalf("unexpected consumer span %q without StartSpans", sp.Name())
+		}
+	}
+	if inTrace.TraceID() != testProducerSpan.TraceID() {
+		t.Fatalf("expected the handler ctx to stay in the producer's trace, got %v", inTrace.TraceID())
+	}
+}
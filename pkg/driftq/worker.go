@@ -3,8 +3,13 @@ package driftq
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type StepHandler interface {
@@ -23,6 +28,50 @@ type WorkerConfig struct {
 	OnError            func(error)
 	NackReason         func(ctx context.Context, msg ConsumeMessage, err error) string
 	MaxNackReasonBytes int
+
+	// Middleware wraps Handler; the first entry is the outermost (see ChainHandler)
+	Middleware []HandlerMiddleware
+
+	// DeadLetter parks messages that keep failing (or fail terminally) on a DLQ topic instead of nacking forever
+	DeadLetter *DeadLetterConfig
+
+	// Hooks are optional lifecycle callbacks (see WorkerHooks)
+	Hooks WorkerHooks
+
+	// BeforeGiveUp runs when the Worker is about to give up on a message for good (dead-letter it,
+	// quarantine it, or ack it after a permanent failure), before anything is produced or acked.
+	// An error nacks the message instead, so it comes back and the Worker decides again.
+	// ev.Status is the status the message is about to settle with
+	BeforeGiveUp func(ctx context.Context, ev SettledEvent) error
+
+	// Backoff delays the redelivery of failed messages and stops retrying them at their attempt limit.
+	// nil keeps the default: failed messages are nacked for immediate redelivery
+	Backoff *BackoffConfig
+
+	// Expired settles messages whose Envelope.Deadline already passed without calling Handler.
+	// nil keeps the default: the handler runs with an already expired ctx
+	Expired *ExpiredConfig
+
+	// Fairness shares the Concurrency slots between tenants and enforces per-tenant quotas (see FairnessConfig).
+	// nil keeps the default: messages are handled in the order they arrive
+	Fairness *FairnessConfig
+
+	// Poison recovers handler panics and quarantines messages that keep crashing the worker (see PoisonConfig).
+	// nil keeps the default: a panicking handler crashes the process
+	Poison *PoisonConfig
+
+	// Status publishes a StepEvent per handler start and settlement (see StatusConfig and RunTracker)
+	Status *StatusConfig
+
+	// DrainTimeout is how long in-flight handlers may keep running once Run's ctx is cancelled.
+	// 0 means no grace period: in-flight handlers are cancelled right away (their Ack/Nack still goes out)
+	DrainTimeout time.Duration
+}
+
+// DrainResult summarizes what happened to in-flight messages when the worker stopped
+type DrainResult struct {
+	Completed int // handlers that finished (and were acked/nacked) inside the drain window
+	Abandoned int // handlers still running when the drain window closed; their ctx was cancelled
 }
 
 type Worker struct {
@@ -35,6 +84,32 @@ type Worker struct {
 
 	nackReason func(ctx context.Context, msg ConsumeMessage, err error) string
 	maxReason  int
+
+	dlq      *DeadLetterConfig
+	status   *StatusConfig
+	expiry   *ExpiredConfig
+	backoff  *BackoffConfig
+	poison   *crashJournal
+	fair     *fairScheduler
+	tracing  TracingConfig
+	metrics  *clientMetrics
+	log      *slog.Logger
+	hooks    WorkerHooks
+	giveUpFn func(ctx context.Context, ev SettledEvent) error
+
+	drainTimeout time.Duration
+	inflight     atomic.Int64
+	opens        atomic.Int64 // consume streams opened, over every Run
+
+	mu         sync.Mutex
+	running    bool
+	stop       chan struct{}   // closed by Shutdown
+	stopCtx    context.Context // Shutdown's ctx; bounds the drain window
+	settled    chan struct{}   // closed once the drain window is over
+	draining   bool
+	abandoning bool
+	drained    DrainResult
+	lastEnd    error // what ended the previous Run's stream
 }
 
 func NewWorker(cfg WorkerConfig) (*Worker, error) {
@@ -50,6 +125,30 @@ func NewWorker(cfg WorkerConfig) (*Worker, error) {
 		return nil, errors.New("worker: ConsumeOptions requires Topic, Group, Owner")
 	}
 
+	if cfg.DeadLetter != nil && cfg.DeadLetter.Topic == "" {
+		return nil, errors.New("worker: DeadLetter requires Topic")
+	}
+
+	if cfg.Status != nil && cfg.Status.Topic == "" {
+		return nil, errors.New("worker: Status requires Topic")
+	}
+
+	if cfg.Poison != nil && cfg.Poison.Topic == "" {
+		return nil, errors.New("worker: Poison requires Topic")
+	}
+
+	if cfg.Poison != nil && cfg.Poison.Topic == cfg.Consume.Topic {
+		return nil, errors.New("worker: Poison.Topic must differ from the consumed topic")
+	}
+
+	if cfg.Expired != nil && cfg.Expired.Grace < 0 {
+		return nil, errors.New("worker: Expired.Grace must be >= 0")
+	}
+
+	if cfg.DrainTimeout < 0 {
+		return nil, errors.New("worker: DrainTimeout must be >= 0")
+	}
+
 	conc := cfg.Concurrency
 	if conc <= 0 {
 		conc = 1
@@ -70,72 +169,366 @@ func NewWorker(cfg WorkerConfig) (*Worker, error) {
 		}
 	}
 
+	var bo *BackoffConfig
+	if cfg.Backoff != nil {
+		b := cfg.Backoff.withDefaults()
+		bo = &b
+	}
+
+	var poison *crashJournal
+	if cfg.Poison != nil {
+		poison = newCrashJournal(cfg.Poison.withDefaults())
+	}
+
+	var fair *fairScheduler
+	if cfg.Fairness != nil {
+		fc, err := cfg.Fairness.withDefaults(conc)
+		if err != nil {
+			return nil, err
+		}
+		fair = newFairScheduler(fc)
+	}
+
 	return &Worker{
-		c:           cfg.Client,
-		opt:         cfg.Consume,
-		h:           cfg.Handler,
-		concurrency: conc,
-		onError:     cfg.OnError,
-		nackReason:  nrf,
-		maxReason:   maxReason,
+		c:            cfg.Client,
+		opt:          cfg.Consume,
+		h:            ChainHandler(cfg.Handler, cfg.Middleware...),
+		concurrency:  conc,
+		onError:      cfg.OnError,
+		nackReason:   nrf,
+		maxReason:    maxReason,
+		dlq:          cfg.DeadLetter,
+		status:       cfg.Status,
+		expiry:       cfg.Expired,
+		backoff:      bo,
+		poison:       poison,
+		fair:         fair,
+		tracing:      cfg.Client.cfg.Tracing.withDefaults(),
+		metrics:      cfg.Client.metrics,
+		log:          cfg.Client.log,
+		hooks:        cfg.Hooks,
+		giveUpFn:     cfg.BeforeGiveUp,
+		drainTimeout: cfg.DrainTimeout,
 	}, nil
 }
 
-// Run starts consuming and processing until ctx is cancelled or the server closes the stream
+// Run starts consuming and processing until ctx is cancelled, Shutdown is called or the server closes the stream
 // ctx cancellation is treated as a normal shutdown (Run returns nil)
+//
+// Handlers and their Ack/Nack run on a ctx detached from ctx, so in-flight work can still finish
+// and be acked while the worker drains (see DrainTimeout and Shutdown)
+//
+// Once Run has returned it may be called again to reopen the stream; every open after the
+// first is counted as a reconnect (OnReconnect and the driftq.consumer.reconnects metric)
 func (w *Worker) Run(ctx context.Context) error {
-	msgs, errs, err := w.c.ConsumeStream(ctx, w.opt)
+	if err := w.begin(); err != nil {
+		return err
+	}
+	defer w.end()
+
+	if w.poison != nil {
+		if err := w.poison.load(); err != nil {
+			return err
+		}
+		defer func() { w.report(w.poison.close()) }()
+	}
+
+	streamCtx, stopStream := context.WithCancel(ctx)
+	defer stopStream()
+
+	msgs, errs, err := w.c.ConsumeStream(streamCtx, w.opt)
 	if err != nil {
+		w.setLastEnd(err)
 		return err
 	}
+	w.streamOpened(ctx)
+
+	hctx, abandon := context.WithCancel(context.WithoutCancel(ctx))
+	defer abandon()
 
 	sem := make(chan struct{}, w.concurrency)
 	var wg sync.WaitGroup
 
-	wait := func() {
+	err = w.pump(ctx, hctx, msgs, errs, sem, &wg)
+	stopStream()
+	if errors.Is(err, errWorkerStopped) {
+		w.drain(ctx, &wg, abandon)
+		err = nil
+	} else {
 		wg.Wait()
 	}
 
+	w.setLastEnd(err)
+	return err
+}
+
+func (w *Worker) setLastEnd(err error) {
+	w.mu.Lock()
+	w.lastEnd = err
+	w.mu.Unlock()
+}
+
+// streamOpened reports a new consume stream; after the first it's a reconnect
+func (w *Worker) streamOpened(ctx context.Context) {
+	opens := int(w.opens.Add(1))
+	if opens > 1 {
+		w.mu.Lock()
+		prev := w.lastEnd
+		w.mu.Unlock()
+
+		w.metrics.reconnects.Add(ctx, 1, w.metricAttrs())
+		attrs := w.logAttrs(slog.Int("opens", opens))
+		if prev != nil {
+			attrs = append(attrs, slog.String("error", prev.Error()))
+		}
+		w.log.LogAttrs(ctx, slog.LevelInfo, "driftq worker reconnected", attrs...)
+		w.hookReconnect(ctx, ReconnectEvent{Topic: w.opt.Topic, Group: w.opt.Group, Owner: w.opt.Owner, Opens: opens, Err: prev})
+	}
+	w.hookStreamOpen(ctx, opens)
+}
+
+// errWorkerStopped is returned by pump when ctx was cancelled or Shutdown was called
+var errWorkerStopped = errors.New("worker stopped")
+
+// pump dispatches messages from one consume stream until it ends.
+// It returns nil when the server closed the stream, the (reported) stream error, or errWorkerStopped
+func (w *Worker) pump(ctx, hctx context.Context, msgs <-chan ConsumeMessage, errs <-chan error, sem chan struct{}, wg *sync.WaitGroup) error {
+	if w.fair != nil {
+		return w.fairPump(ctx, hctx, msgs, errs, sem, wg)
+	}
+
 	for {
+		// Reserve a slot before pulling so a message is never received and then stranded by shutdown
+		select {
+		case <-ctx.Done():
+			return errWorkerStopped
+		case <-w.stop:
+			return errWorkerStopped
+		case sem <- struct{}{}:
+		}
+
 		select {
 		case <-ctx.Done():
-			wait()
-			return nil
+			<-sem
+			return errWorkerStopped
+
+		case <-w.stop:
+			<-sem
+			return errWorkerStopped
 
 		case err, ok := <-errs:
+			<-sem
 			if !ok || err == nil {
+				errs = nil
 				continue
 			}
 
 			w.report(err)
-			wait()
 			return err
 
 		case m, ok := <-msgs:
 			if !ok {
-				wait()
+				<-sem
+				// ConsumeStream buffers its error before closing msgs; don't lose it to select order
+				select {
+				case err := <-errs:
+					if err != nil {
+						w.report(err)
+						return err
+					}
+				default:
+				}
 				return nil
 			}
 
-			sem <- struct{}{}
-			wg.Add(1)
+			w.hookMessageReceived(ctx, m)
+			w.launch(ctx, hctx, m, sem, wg, nil)
+		}
+	}
+}
+
+// launch handles m on its own goroutine in the slot already reserved in sem.
+// release (optional) runs once the slot is given back
+func (w *Worker) launch(ctx, hctx context.Context, m ConsumeMessage, sem chan struct{}, wg *sync.WaitGroup, release func()) {
+	wg.Add(1)
+	w.inflight.Add(1)
+	w.metrics.inflight.Add(ctx, 1, w.metricAttrs())
 
-			go func(msg ConsumeMessage) {
-				defer wg.Done()
-				defer func() { <-sem }()
+	go func() {
+		defer wg.Done()
 
-				w.handleOne(ctx, msg)
-			}(m)
+		w.handleOne(hctx, m)
+		w.metrics.inflight.Add(hctx, -1, w.metricAttrs())
+		w.noteDone()
+
+		<-sem
+		if release != nil {
+			release()
 		}
+	}()
+}
+
+// Shutdown stops pulling new messages and waits for in-flight handlers to finish.
+// The drain window ends when ctx is done or DrainTimeout (if set) elapses, whichever is first;
+// handlers still running then are cancelled and counted as abandoned.
+//
+// Shutdown returns ctx.Err() if ctx ended the window before every handler finished
+// If the worker is not running it returns the result of the last drain
+func (w *Worker) Shutdown(ctx context.Context) (DrainResult, error) {
+	w.mu.Lock()
+	if !w.running {
+		res := w.drained
+		w.mu.Unlock()
+		return res, nil
+	}
+
+	if w.stopCtx == nil {
+		w.stopCtx = ctx
+		close(w.stop)
+	}
+	settled := w.settled
+	w.mu.Unlock()
+
+	<-settled
+
+	w.mu.Lock()
+	res := w.drained
+	w.mu.Unlock()
+
+	if res.Abandoned > 0 && ctx.Err() != nil {
+		return res, ctx.Err()
+	}
+	return res, nil
+}
+
+func (w *Worker) begin() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.running {
+		return errors.New("worker: already running")
+	}
+
+	w.running = true
+	w.stop = make(chan struct{})
+	w.stopCtx = nil
+	w.settled = make(chan struct{})
+	w.draining = false
+	w.abandoning = false
+	w.drained = DrainResult{}
+	return nil
+}
+
+func (w *Worker) end() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.running = false
+	select {
+	case <-w.settled:
+	default:
+		close(w.settled)
+	}
+}
+
+// drain waits for in-flight handlers until the drain window closes, then cancels whatever is left.
+// runCtx is Run's ctx; when it (rather than Shutdown) triggered the drain, only DrainTimeout applies
+func (w *Worker) drain(runCtx context.Context, wg *sync.WaitGroup, abandon context.CancelFunc) {
+	w.mu.Lock()
+	w.draining = true
+	stopCtx := w.stopCtx
+	inflight := w.inflight.Load()
+	w.mu.Unlock()
+
+	lctx := context.WithoutCancel(runCtx)
+	w.log.LogAttrs(lctx, slog.LevelInfo, "driftq worker draining",
+		w.logAttrs(slog.Int64("in_flight", inflight), slog.Duration("drain_timeout", w.drainTimeout))...)
+
+	allDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(allDone)
+	}()
+
+	var timeout <-chan time.Time
+	if w.drainTimeout > 0 {
+		t := time.NewTimer(w.drainTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	var stopDone <-chan struct{}
+	if stopCtx != nil {
+		stopDone = stopCtx.Done()
+	} else if w.drainTimeout <= 0 && runCtx.Err() != nil {
+		// No grace period configured: cancel in-flight handlers right away
+		timeout = closedTimeCh
+	}
+
+	select {
+	case <-allDone:
+	case <-timeout:
+	case <-stopDone:
+	}
+
+	w.mu.Lock()
+	select {
+	case <-allDone:
+	default:
+		w.abandoning = true
+		w.drained.Abandoned = int(w.inflight.Load())
+	}
+	close(w.settled)
+	res := w.drained
+	w.mu.Unlock()
+
+	abandon()
+	<-allDone
+
+	w.log.LogAttrs(lctx, slog.LevelInfo, "driftq worker stopped",
+		w.logAttrs(slog.Int("completed", res.Completed), slog.Int("abandoned", res.Abandoned))...)
+	w.hookShutdown(lctx, res)
+}
+
+var closedTimeCh = func() <-chan time.Time {
+	ch := make(chan time.Time)
+	close(ch)
+	return ch
+}()
+
+func (w *Worker) noteDone() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.inflight.Add(-1)
+	if w.draining && !w.abandoning {
+		w.drained.Completed++
 	}
 }
 
 func (w *Worker) handleOne(ctx context.Context, msg ConsumeMessage) {
+	start := time.Now()
+
+	ctx, span := w.startConsumerSpan(ctx, msg)
+	outcome, err := w.process(ctx, msg)
+	endConsumerSpan(span, outcome, err)
+
+	w.metrics.processDuration.Record(ctx, time.Since(start).Seconds(), w.metricAttrs(
+		attribute.String(attrMessagingOutcome, string(outcome)),
+	))
+}
+
+// process runs the handler and settles the message; it returns what was done and the handler error
+func (w *Worker) process(ctx context.Context, msg ConsumeMessage) (settleOutcome, error) {
 	// Derive per-message ctx:
 	// - If message envelope has a deadline, honor it (earlier deadline wins)
+	// - ExpiredConfig.Grace extends it, or a message let in by Grace would start with a dead ctx
 	hctx := ctx
 	var cancel func()
 	if dl := envelopeDeadline(msg); !dl.IsZero() {
+		if w.expiry != nil {
+			dl = dl.Add(w.expiry.Grace)
+		}
 		if cur, ok := ctx.Deadline(); !ok || dl.Before(cur) {
 			hctx, cancel = context.WithDeadline(ctx, dl)
 		}
@@ -145,41 +538,197 @@ func (w *Worker) handleOne(ctx context.Context, msg ConsumeMessage) {
 		defer cancel()
 	}
 
-	err := w.h.Handle(hctx, msg)
+	sc := NewStepContext(w.c, w.opt.Topic, w.opt.Group, msg)
+	hctx = WithStepContext(hctx, sc)
+
+	// Ack/Nack must go out even if the handler was cancelled (shutdown), so they use a detached ctx
+	actx := context.WithoutCancel(ctx)
+
+	ev := w.messageEvent(hctx, msg)
+
+	var (
+		err  error
+		took time.Duration
+	)
+	crashes, poisoned := w.poisoned(msg)
+	if dl, expired := w.expired(msg); expired {
+		err = w.onExpired(actx, ev, dl)
+	} else if !poisoned {
+		w.hookHandlerStart(hctx, ev)
+		w.publishStatus(actx, sc, msg, StepStarted, "", 0)
+
+		start := time.Now()
+		crashes, err = w.handle(hctx, msg)
+		took = time.Since(start)
+		w.hookHandlerEnd(hctx, HandlerEndEvent{MessageEvent: ev, Duration: took, Err: err})
+		poisoned = crashes > 0 && crashes >= w.poison.cfg.MaxCrashes
+	}
 
+	if poisoned {
+		outcome, reason := w.quarantine(actx, hctx, ev, crashes)
+		if err == nil {
+			err = ErrPoisonMessage
+		}
+		w.finish(actx, sc, ev, outcome, reason, err, took)
+		return outcome, err
+	}
+
+	outcome, reason, serr := w.settle(ctx, actx, hctx, ev, err)
+	w.finish(actx, sc, ev, outcome, reason, serr, took)
+	return outcome, err
+}
+
+// finish reports the final outcome of a delivery: the status update and OnSettled
+func (w *Worker) finish(ctx context.Context, sc *StepContext, ev MessageEvent, outcome settleOutcome, reason string, err error, took time.Duration) {
+	status := settledStatus(outcome, err)
+	w.publishStatus(ctx, sc, ev.Message, status, reason, took)
+	w.hookSettled(ctx, SettledEvent{MessageEvent: ev, Step: sc, Status: status, Reason: reason, Err: err})
+}
+
+// vetoGiveUp runs BeforeGiveUp before the message is settled for good with status.
+// It reports whether that failed, in which case the message must be nacked instead
+func (w *Worker) vetoGiveUp(hctx context.Context, ev MessageEvent, status StepStatus, reason string, err error) bool {
+	if w.giveUpFn == nil {
+		return false
+	}
+
+	sc, _ := StepFromContext(hctx)
+	ctx := context.WithoutCancel(hctx)
+	gerr := w.giveUpFn(ctx, SettledEvent{MessageEvent: ev, Step: sc, Status: status, Reason: reason, Err: err})
+	if gerr == nil {
+		return false
+	}
+	w.log.LogAttrs(ctx, slog.LevelError, "driftq BeforeGiveUp failed, nacking instead",
+		append(w.c.messageLogAttrs(ev.Message), slog.String("status", string(status)), slog.String("error", gerr.Error()))...)
+	w.report(fmt.Errorf("worker: BeforeGiveUp partition=%d offset=%d: %w", ev.Message.Partition, ev.Message.Offset, gerr))
+	return true
+}
+
+// settle acks, nacks or dead-letters the message after its handler returned err.
+// It returns what was done, the nack / dead-letter reason and the error the message was
+// settled with: nil when OnExhausted took the failure and acked
+func (w *Worker) settle(ctx, actx, hctx context.Context, ev MessageEvent, err error) (settleOutcome, string, error) {
+	msg := ev.Message
 	if err == nil {
-		ackErr := w.c.Ack(ctx, AckRequest{
-			Topic:     w.opt.Topic,
-			Group:     w.opt.Group,
-			Owner:     w.opt.Owner,
-			Partition: msg.Partition,
-			Offset:    msg.Offset,
-		})
-		if ackErr != nil {
-			w.report(ackErr)
+		w.ack(actx, ev)
+		return settledAck, "", nil
+	}
+
+	outcome, cause, delay := classifyHandlerErr(err)
+
+	if w.backoff != nil && (outcome == outcomeNack || outcome == outcomeRetryAfter) {
+		if w.backoff.exhausted(msg) {
+			if err = w.giveUp(context.WithoutCancel(hctx), msg, err); err == nil {
+				w.ack(actx, ev)
+				return settledAck, "", nil
+			}
+			outcome, cause, delay = classifyHandlerErr(err)
+		} else if outcome == outcomeNack {
+			delay = w.backoff.retryDelay(msg)
 		}
-		return
 	}
 
-	reason := w.nackReason(hctx, msg, err)
-	if len(reason) > w.maxReason {
-		reason = reason[:w.maxReason]
+	if outcome == outcomeSkip {
+		// Not a failure, so NackReason isn't asked; the cause (if any) is only informational
+		w.ack(actx, ev)
+		if cause == nil {
+			return settledSkip, "", err
+		}
+		return settledSkip, w.truncateReason(cause.Error()), err
+	}
+
+	if cause == nil {
+		cause = err // RetryAfter(nil, d): NackReason still gets a non-nil error
 	}
+	reason := w.truncateReason(w.nackReason(hctx, msg, cause))
+
+	if outcome == outcomePermanent && w.dlq == nil && !w.vetoGiveUp(hctx, ev, StepFailed, reason, err) {
+		w.ack(actx, ev)
+		w.log.LogAttrs(ctx, slog.LevelWarn, "driftq acked permanently failed message",
+			append(w.c.messageLogAttrs(msg), slog.String("error", reason))...)
+		w.report(fmt.Errorf("worker: acked permanently failed message partition=%d offset=%d: %w", msg.Partition, msg.Offset, err))
+		return settledAck, reason, err
+	}
+
+	if w.shouldDeadLetter(msg, err) && !w.vetoGiveUp(hctx, ev, StepDeadLettered, reason, err) {
+		dlErr := w.deadLetter(actx, msg, reason)
+		w.hookDeadLetter(actx, SettleEvent{MessageEvent: ev, Reason: reason, DeadLetterTopic: w.dlq.Topic, Err: dlErr})
+
+		if dlErr == nil {
+			w.log.LogAttrs(ctx, slog.LevelWarn, "driftq message dead-lettered",
+				append(w.c.messageLogAttrs(msg), slog.String("dlq", w.dlq.Topic), slog.String("error", reason))...)
+			w.ack(actx, ev)
+			return settledDeadLetter, reason, err
+		}
+
+		// Could not park it; nack so the message is not lost
+		w.log.LogAttrs(ctx, slog.LevelError, "driftq dead-letter failed",
+			append(w.c.messageLogAttrs(msg), slog.String("dlq", w.dlq.Topic), slog.String("error", dlErr.Error()))...)
+		w.report(dlErr)
+	}
+
+	if w.backoff != nil && w.backoff.Hold && delay > 0 {
+		w.hold(ctx, delay)
+		delay = 0
+	}
+	w.nack(actx, ev, reason, delay)
+	return settledNack, reason, err
+}
 
-	nackErr := w.c.Nack(ctx, NackRequest{
+func (w *Worker) ack(ctx context.Context, ev MessageEvent) {
+	msg := ev.Message
+	err := w.c.Ack(ctx, AckRequest{
+		Topic:     w.opt.Topic,
+		Group:     w.opt.Group,
+		Owner:     w.opt.Owner,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+	})
+	w.hookAck(ctx, SettleEvent{MessageEvent: ev, Err: err})
+
+	if err != nil {
+		w.log.LogAttrs(ctx, slog.LevelError, "driftq ack failed",
+			append(w.c.messageLogAttrs(msg), slog.String("error", err.Error()))...)
+		w.report(err)
+	}
+}
+
+func (w *Worker) nack(ctx context.Context, ev MessageEvent, reason string, delay time.Duration) {
+	msg := ev.Message
+	err := w.c.Nack(ctx, NackRequest{
 		Topic:     w.opt.Topic,
 		Group:     w.opt.Group,
 		Owner:     w.opt.Owner,
 		Partition: msg.Partition,
 		Offset:    msg.Offset,
 		Reason:    reason,
+		DelayMS:   delay.Milliseconds(),
 	})
+	w.hookNack(ctx, SettleEvent{MessageEvent: ev, Reason: reason, Delay: delay, Err: err})
 
-	if nackErr != nil {
-		w.report(nackErr)
+	if err != nil {
+		w.log.LogAttrs(ctx, slog.LevelError, "driftq nack failed",
+			append(w.c.messageLogAttrs(msg), slog.String("reason", reason), slog.String("error", err.Error()))...)
+		w.report(err)
 	}
 }
 
+// truncateReason caps a nack / dead-letter reason at MaxNackReasonBytes
+func (w *Worker) truncateReason(reason string) string {
+	if len(reason) > w.maxReason {
+		return reason[:w.maxReason]
+	}
+	return reason
+}
+
+func (w *Worker) logAttrs(extra ...slog.Attr) []slog.Attr {
+	return append([]slog.Attr{
+		slog.String("topic", w.opt.Topic),
+		slog.String("group", w.opt.Group),
+		slog.String("owner", w.opt.Owner),
+	}, extra...)
+}
+
 func (w *Worker) report(err error) {
 	if err == nil || w.onError == nil {
 		return
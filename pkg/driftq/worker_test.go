@@ -6,6 +6,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -234,3 +235,311 @@ func TestWorker_HonorsEnvelopeDeadline(t *testing.T) {
 		t.Fatalf("Run: %v", err)
 	}
 }
+
+func TestWorker_HandlerErrorOutcomes(t *testing.T) {
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(
+		`{"partition":0,"offset":1,"attempts":1,"value":"skip"}`,
+		`{"partition":0,"offset":2,"attempts":1,"value":"permanent"}`,
+		`{"partition":0,"offset":3,"attempts":1,"value":"retry"}`,
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var reported []error
+	var reasons []error
+
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "demo", Group: "demo", Owner: "worker-1"},
+		OnError: func(err error) { reported = append(reported, err) },
+		NackReason: func(_ context.Context, _ ConsumeMessage, err error) string {
+			reasons = append(reasons, err)
+			return err.Error()
+		},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			switch msg.Value {
+			case "skip":
+				return Skip(errors.New("duplicate"))
+			case "permanent":
+				return Permanent(errors.New("invalid payload"))
+			default:
+				return RetryAfter(errors.New("busy"), 1500*time.Millisecond)
+			}
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.acks) != 2 || s.acks[0].Offset != 1 || s.acks[1].Offset != 2 {
+		t.Fatalf("expected skip and permanent to be acked, got %#v", s.acks)
+	}
+
+	if len(s.nacks) != 1 || s.nacks[0].Offset != 3 || s.nacks[0].DelayMS != 1500 || s.nacks[0].Reason != "busy" {
+		t.Fatalf("unexpected nacks: %#v", s.nacks)
+	}
+
+	if len(reported) != 1 || !IsPermanent(reported[0]) {
+		t.Fatalf("expected the permanent failure to be reported, got %v", reported)
+	}
+
+	for _, r := range reasons {
+		var pe *PermanentError
+		var se *SkipError
+		var re *RetryAfterError
+		if errors.As(r, &pe) || errors.As(r, &se) || errors.As(r, &re) {
+			t.Fatalf("NackReason got a wrapper instead of the cause: %v", r)
+		}
+	}
+}
+
+// waitDraining blocks until the worker entered its drain window
+func waitDraining(t *testing.T, w *Worker) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		w.mu.Lock()
+		draining := w.draining
+		w.mu.Unlock()
+		if draining {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("worker never started draining")
+}
+
+func TestWorker_ShutdownCountsCompletedAndAbandoned(t *testing.T) {
+	s := dlqServer{hold: true}
+	srv := httptest.NewServer(s.handler(
+		`{"partition":0,"offset":1,"attempts":1,"value":"quick"}`,
+		`{"partition":0,"offset":2,"attempts":1,"value":"stuck"}`,
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	wk, err := NewWorker(WorkerConfig{
+		Client:      c,
+		Consume:     ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		Concurrency: 2,
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			started <- struct{}{}
+			if msg.Value == "quick" {
+				<-release
+				return nil
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- wk.Run(context.Background()) }()
+	<-started
+	<-started
+
+	type shutdown struct {
+		res DrainResult
+		err error
+	}
+	done := make(chan shutdown, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+		res, err := wk.Shutdown(ctx)
+		done <- shutdown{res, err}
+	}()
+
+	waitDraining(t, wk)
+	close(release)
+
+	got := <-done
+	if got.res != (DrainResult{Completed: 1, Abandoned: 1}) || !errors.Is(got.err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %+v, %v; want 1 completed, 1 abandoned and the ctx error", got.res, got.err)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	res, err := wk.Shutdown(context.Background())
+	if res != got.res || err != nil {
+		t.Fatalf("second Shutdown = %+v, %v; want the previous result", res, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.acks) != 1 || s.acks[0].Offset != 1 || len(s.nacks) != 1 || s.nacks[0].Offset != 2 {
+		t.Fatalf("expected the finished handler acked and the abandoned one nacked: acks %#v nacks %#v", s.acks, s.nacks)
+	}
+}
+
+func TestWorker_DrainTimeoutAfterRunCancelled(t *testing.T) {
+	s := dlqServer{hold: true}
+	srv := httptest.NewServer(s.handler(
+		`{"partition":0,"offset":1,"attempts":1,"value":"quick"}`,
+		`{"partition":0,"offset":2,"attempts":1,"value":"stuck"}`,
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	stuckErr := make(chan error, 1)
+	wk, err := NewWorker(WorkerConfig{
+		Client:       c,
+		Consume:      ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		Concurrency:  2,
+		DrainTimeout: 100 * time.Millisecond,
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			started <- struct{}{}
+			if msg.Value == "quick" {
+				<-release
+				return nil
+			}
+			<-ctx.Done()
+			stuckErr <- ctx.Err()
+			return nil // acked after its ctx was cancelled
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- wk.Run(ctx) }()
+	<-started
+	<-started
+
+	cancel()
+	stoppedAt := time.Now()
+	waitDraining(t, wk)
+	close(release)
+
+	if err := <-runErr; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if took := time.Since(stoppedAt); took < 80*time.Millisecond {
+		t.Fatalf("Run returned after %s, before DrainTimeout", took)
+	}
+	if err := <-stuckErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected DrainTimeout to cancel the stuck handler, got %v", err)
+	}
+
+	res, err := wk.Shutdown(context.Background())
+	if res != (DrainResult{Completed: 1, Abandoned: 1}) || err != nil {
+		t.Fatalf("Shutdown after Run = %+v, %v", res, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.acks) != 2 {
+		t.Fatalf("expected both acks to go out on the detached ctx, got %#v", s.acks)
+	}
+}
+
+func TestWorker_NackReasonNeverGetsNil(t *testing.T) {
+	var s dlqServer
+	srv := httptest.NewServer(s.handler(
+		`{"partition":0,"offset":1,"attempts":1,"value":"skip"}`,
+		`{"partition":0,"offset":2,"attempts":1,"value":"later"}`,
+	))
+	defer srv.Close()
+
+	c, err := Dial(context.Background(), Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var asked []int64
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "demo", Group: "demo", Owner: "worker-1"},
+		NackReason: func(_ context.Context, msg ConsumeMessage, err error) string {
+			asked = append(asked, msg.Offset)
+			return err.Error()
+		},
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error {
+			if msg.Value == "skip" {
+				return Skip(nil)
+			}
+			return RetryAfter(nil, time.Second)
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+	if err := wk.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(asked) != 1 || asked[0] != 2 {
+		t.Fatalf("expected NackReason only for the nacked message, got %v", asked)
+	}
+	if len(s.acks) != 1 || len(s.nacks) != 1 || s.nacks[0].DelayMS != 1000 {
+		t.Fatalf("unexpected settlement: acks %#v nacks %#v", s.acks, s.nacks)
+	}
+}
+
+func TestWorker_PumpKeepsStreamErrorWhenMsgsClosesFirst(t *testing.T) {
+	c, err := Dial(context.Background(), Config{BaseURL: "http://driftq.invalid"})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var reported []error
+	wk, err := NewWorker(WorkerConfig{
+		Client:  c,
+		Consume: ConsumeOptions{Topic: "demo", Group: "g", Owner: "worker-1"},
+		OnError: func(err error) { reported = append(reported, err) },
+		Handler: StepFunc(func(ctx context.Context, msg ConsumeMessage) error { return nil }),
+	})
+	if err != nil {
+		t.Fatalf("NewWorker: %v", err)
+	}
+
+	// ConsumeStream buffers the error and then closes msgs, so both are ready at once
+	// and select may pick either; run it enough times to hit the msgs-first order
+	boom := errors.New("stream broke")
+	for range 50 {
+		msgs := make(chan ConsumeMessage)
+		close(msgs)
+		errs := make(chan error, 1)
+		errs <- boom
+
+		var wg sync.WaitGroup
+		if err := wk.pump(context.Background(), context.Background(), msgs, errs, make(chan struct{}, 1), &wg); !errors.Is(err, boom) {
+			t.Fatalf("expected the stream error, got %v", err)
+		}
+	}
+	if len(reported) != 50 {
+		t.Fatalf("expected every stream error to be reported, got %d", len(reported))
+	}
+}
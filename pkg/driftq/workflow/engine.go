@@ -0,0 +1,316 @@
+package workflow
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq"
+)
+
+type Config struct {
+	Client *driftq.Client
+
+	TopicPrefix string // step topics are TopicPrefix + step name (default "wf.<workflow>.")
+	Group       string // consumer group for every step (default "wf.<workflow>")
+	Owner       string // lease owner (default <hostname>-<pid>)
+	LeaseMS     int64  // 0 = server default
+
+	// Store keeps Join state (default NewMemoryJoinStore)
+	Store JoinStore
+
+	// SagaStore tracks completed and compensated steps per run; only used when a step has
+	// Compensate (default NewMemorySagaStore). CompensationTopic carries the undo messages
+	// (default TopicPrefix + "_compensate")
+	SagaStore         SagaStore
+	CompensationTopic string
+
+	// Passed to every step's Worker
+	Middleware []driftq.HandlerMiddleware
+	DeadLetter *driftq.DeadLetterConfig
+	Backoff    *driftq.BackoffConfig
+	Status     *driftq.StatusConfig // step events for a driftq.RunTracker
+	Hooks      driftq.WorkerHooks
+	OnError    func(error)
+}
+
+// Engine runs a Workflow: one Worker per step, wired together through step topics
+type Engine struct {
+	wf    *Workflow
+	cfg   Config
+	c     *driftq.Client
+	store JoinStore
+	saga  SagaStore // nil unless some step compensates
+}
+
+func NewEngine(wf *Workflow, cfg Config) (*Engine, error) {
+	if wf == nil {
+		return nil, errors.New("workflow: Workflow is required")
+	}
+	if cfg.Client == nil {
+		return nil, errors.New("workflow: Client is required")
+	}
+
+	if cfg.TopicPrefix == "" {
+		cfg.TopicPrefix = "wf." + wf.name + "."
+	}
+	if cfg.Group == "" {
+		cfg.Group = "wf." + wf.name
+	}
+	if cfg.Owner == "" {
+		host, _ := os.Hostname()
+		if host == "" {
+			host = "workflow"
+		}
+		cfg.Owner = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryJoinStore()
+	}
+
+	e := &Engine{wf: wf, cfg: cfg, c: cfg.Client, store: cfg.Store}
+
+	if wf.compensates() {
+		if e.cfg.SagaStore == nil {
+			e.cfg.SagaStore = NewMemorySagaStore()
+		}
+		if e.cfg.CompensationTopic == "" {
+			e.cfg.CompensationTopic = e.cfg.TopicPrefix + "_compensate"
+		}
+		for _, name := range wf.order {
+			if e.Topic(name) == e.cfg.CompensationTopic {
+				return nil, fmt.Errorf("workflow: step %q uses the compensation topic %q", name, e.cfg.CompensationTopic)
+			}
+		}
+		e.saga = e.cfg.SagaStore
+	}
+	return e, nil
+}
+
+func (e *Engine) Workflow() *Workflow { return e.wf }
+
+// Topic is where messages for step are produced
+func (e *Engine) Topic(step string) string {
+	if s := e.wf.steps[step]; s != nil && s.Topic != "" {
+		return s.Topic
+	}
+	return e.cfg.TopicPrefix + step
+}
+
+// EnsureTopics creates any missing step (and compensation) topics; partitions 0 = server default
+func (e *Engine) EnsureTopics(ctx context.Context, partitions int) (driftq.EnsureTopicsResult, error) {
+	specs := make([]driftq.TopicSpec, 0, len(e.wf.order)+1)
+	for _, name := range e.wf.order {
+		specs = append(specs, driftq.TopicSpec{Name: e.Topic(name), Partitions: partitions})
+	}
+	if e.saga != nil {
+		specs = append(specs, driftq.TopicSpec{Name: e.cfg.CompensationTopic, Partitions: partitions})
+	}
+	return e.c.Admin().EnsureTopics(ctx, specs)
+}
+
+// Saga returns the saga state of a run (zero value when no step compensates)
+func (e *Engine) Saga(ctx context.Context, runID string) (SagaState, error) {
+	if e.saga == nil {
+		return SagaState{RunID: runID}, nil
+	}
+	return e.saga.Saga(ctx, runID)
+}
+
+// StartRequest starts one run of the workflow
+type StartRequest struct {
+	RunID    string // default: a random id
+	Value    string // Input.Value for the entry steps
+	TenantID string
+	Deadline *time.Time
+}
+
+// Start sends req.Value to every entry step and returns the run id.
+// Starting the same RunID twice is deduplicated by the broker
+func (e *Engine) Start(ctx context.Context, req StartRequest) (string, error) {
+	runID := req.RunID
+	if runID == "" {
+		var b [12]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return "", err
+		}
+		runID = "run-" + hex.EncodeToString(b[:])
+	}
+
+	for _, to := range e.wf.entries {
+		env := &driftq.Envelope{
+			RunID:          runID,
+			StepID:         to,
+			TenantID:       req.TenantID,
+			IdempotencyKey: e.edgeKey(runID, "", to),
+			Deadline:       req.Deadline,
+			RetryPolicy:    e.wf.steps[to].Retry,
+		}
+		if _, err := e.c.Produce(ctx, driftq.ProduceRequest{Topic: e.Topic(to), Key: runID, Value: req.Value, Envelope: env}); err != nil {
+			return runID, fmt.Errorf("workflow: start %s at %q: %w", runID, to, err)
+		}
+	}
+	return runID, nil
+}
+
+// Run consumes every step topic until ctx is cancelled. If a step's Worker stops (its stream
+// failed or the server closed it), the others are stopped too and the errors are returned;
+// call Run again to reconnect
+func (e *Engine) Run(ctx context.Context) error {
+	names := slices.Clone(e.wf.order)
+	workers := make([]*driftq.Worker, 0, len(names)+1)
+	for _, name := range names {
+		s := e.wf.steps[name]
+		w, err := e.newWorker(e.Topic(name), s.Concurrency, e.stepHandler(s), e.abortOnGiveUp(s.Name))
+		if err != nil {
+			return err
+		}
+		workers = append(workers, w)
+	}
+	if e.saga != nil {
+		w, err := e.newWorker(e.cfg.CompensationTopic, 1, e.compensationHandler(), nil)
+		if err != nil {
+			return err
+		}
+		names = append(names, "compensation")
+		workers = append(workers, w)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for i, w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer cancel()
+			if err := w.Run(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("workflow: %s worker: %w", names[i], err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (e *Engine) newWorker(topic string, concurrency int, h driftq.StepHandler, giveUp func(context.Context, driftq.SettledEvent) error) (*driftq.Worker, error) {
+	return driftq.NewWorker(driftq.WorkerConfig{
+		Client: e.c,
+		Consume: driftq.ConsumeOptions{
+			Topic:   topic,
+			Group:   e.cfg.Group,
+			Owner:   e.cfg.Owner,
+			LeaseMS: e.cfg.LeaseMS,
+		},
+		Handler:     h,
+		Concurrency: concurrency,
+		OnError:     e.cfg.OnError,
+		Middleware:  e.cfg.Middleware,
+		DeadLetter:  e.cfg.DeadLetter,
+		Backoff:     e.cfg.Backoff,
+		Status:      e.cfg.Status,
+		Hooks:       e.cfg.Hooks,
+
+		BeforeGiveUp: giveUp,
+	})
+}
+
+// stepHandler runs s for one delivery: join bookkeeping, the user handler, then the downstream sends
+func (e *Engine) stepHandler(s *Step) driftq.StepHandler {
+	return driftq.StepContextFunc(func(ctx context.Context, sc *driftq.StepContext, msg driftq.ConsumeMessage) error {
+		in := Input{RunID: sc.RunID, Step: s.Name, From: sc.ParentStepID, Value: msg.Value, Message: msg}
+
+		if e.saga != nil {
+			st, err := e.saga.Saga(ctx, sc.RunID)
+			if err != nil {
+				return err
+			}
+			if st.Aborted {
+				return driftq.Skip(ErrRunAborted)
+			}
+		}
+
+		key := JoinKey{Workflow: e.wf.name, Step: s.Name, RunID: sc.RunID}
+		if len(s.Join) > 0 {
+			got, err := e.store.Arrive(ctx, key, in.From, msg.Value)
+			if err != nil {
+				return err
+			}
+			for _, j := range s.Join {
+				if _, ok := got[j]; !ok {
+					return nil // still waiting for j (this delivery is recorded), or the join already ran
+				}
+			}
+			in.Inputs = got
+		}
+
+		res, err := s.Handler(ctx, in)
+		if err == nil {
+			_, err = s.targets(res)
+			if err != nil {
+				err = driftq.Permanent(err)
+			}
+		}
+		if err != nil {
+			// Whether this fails the run is up to how the Worker settles it (see abortOnGiveUp)
+			return err
+		}
+
+		if e.saga != nil {
+			st, err := e.saga.StepDone(ctx, sc.RunID, s.Name, res.Value)
+			if err != nil {
+				return err
+			}
+			if st.Aborted {
+				// The run failed elsewhere while this step ran: undo it too, don't go further
+				return e.compensateNext(ctx, st)
+			}
+		}
+
+		targets, _ := s.targets(res)
+
+		for _, to := range targets {
+			_, err := sc.EmitMessage(ctx, driftq.EmitRequest{
+				Topic: e.Topic(to),
+				Key:   sc.RunID,
+				Value: res.Value,
+				Envelope: &driftq.Envelope{
+					StepID:         to,
+					IdempotencyKey: e.edgeKey(sc.RunID, s.Name, to),
+					RetryPolicy:    e.wf.steps[to].Retry,
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("workflow: %s -> %s: %w", s.Name, to, err)
+			}
+		}
+
+		if len(s.Join) > 0 {
+			return e.store.Delete(ctx, key)
+		}
+		return nil
+	})
+}
+
+// edgeKey makes every (run, from, to) send idempotent, so a step that runs twice
+// for the same run (redelivery, a racing join) starts each downstream step once
+func (e *Engine) edgeKey(runID, from, to string) string {
+	if from == "" {
+		from = "start"
+	}
+	return "wf:" + e.wf.name + ":" + runID + ":" + from + ">" + to
+}
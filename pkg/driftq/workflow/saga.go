@@ -0,0 +1,333 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq"
+)
+
+// ---- Sagas ----
+//
+// A step with Compensate registers how to undo it. When any step of a run fails for good
+// (a permanent error, or its last attempt under the RetryPolicy / DeadLetter limit), the run
+// is aborted and the completed steps are compensated newest first, one message at a time on
+// the compensation topic. Progress lives in a SagaStore, so a restart or a redelivered
+// compensation picks up where it left off and never undoes a step twice.
+
+// ErrRunAborted is the Skip reason for deliveries that arrive after their run was aborted
+var ErrRunAborted = errors.New("run aborted")
+
+// Compensation is what a CompensateFunc gets
+type Compensation struct {
+	RunID  string
+	Step   string // the step being undone
+	Result string // what Step returned when it completed
+
+	FailedStep string // the step whose failure aborted the run
+	Reason     string // its error
+
+	Message driftq.ConsumeMessage
+}
+
+type CompensateFunc func(ctx context.Context, c Compensation) error
+
+// SagaStep is one completed step of a run
+type SagaStep struct {
+	Name        string `json:"name"`
+	Result      string `json:"result"`
+	Compensated bool   `json:"compensated,omitempty"`
+}
+
+// SagaState is what a SagaStore knows about a run
+type SagaState struct {
+	RunID      string     `json:"run_id"`
+	Steps      []SagaStep `json:"steps"` // in completion order
+	Aborted    bool       `json:"aborted,omitempty"`
+	FailedStep string     `json:"failed_step,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+}
+
+// Pending is the next step to compensate (the newest completed one not yet undone)
+func (s SagaState) Pending(wf *Workflow) (SagaStep, bool) {
+	for i := len(s.Steps) - 1; i >= 0; i-- {
+		st := s.Steps[i]
+		if st.Compensated {
+			continue
+		}
+		if def := wf.steps[st.Name]; def != nil && def.Compensate != nil {
+			return st, true
+		}
+	}
+	return SagaStep{}, false
+}
+
+func (s *SagaState) step(name string) *SagaStep {
+	for i := range s.Steps {
+		if s.Steps[i].Name == name {
+			return &s.Steps[i]
+		}
+	}
+	return nil
+}
+
+// Every update below is idempotent, so redeliveries can repeat them safely
+func (s *SagaState) stepDone(name, result string) {
+	if s.step(name) == nil {
+		s.Steps = append(s.Steps, SagaStep{Name: name, Result: result})
+	}
+}
+
+func (s *SagaState) abort(step, reason string) {
+	if !s.Aborted {
+		s.Aborted, s.FailedStep, s.Reason = true, step, reason
+	}
+}
+
+func (s *SagaState) compensated(name string) {
+	if st := s.step(name); st != nil {
+		st.Compensated = true
+	}
+}
+
+// SagaStore keeps saga progress per run. Implementations must be safe for concurrent use,
+// and every call must be idempotent (first completion / first abort wins)
+type SagaStore interface {
+	StepDone(ctx context.Context, runID, step, result string) (SagaState, error)
+	Abort(ctx context.Context, runID, step, reason string) (SagaState, error)
+	Compensated(ctx context.Context, runID, step string) (SagaState, error)
+	Saga(ctx context.Context, runID string) (SagaState, error)
+
+	// Delete forgets a run (call it once you no longer need the run's history)
+	Delete(ctx context.Context, runID string) error
+}
+
+// MemorySagaStore keeps sagas in memory. Runs are kept until Delete
+type MemorySagaStore struct {
+	mu   sync.Mutex
+	runs map[string]*SagaState
+}
+
+func NewMemorySagaStore() *MemorySagaStore {
+	return &MemorySagaStore{runs: map[string]*SagaState{}}
+}
+
+func (s *MemorySagaStore) update(runID string, fn func(*SagaState)) SagaState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.runs[runID]
+	if st == nil {
+		st = &SagaState{RunID: runID}
+		s.runs[runID] = st
+	}
+	if fn != nil {
+		fn(st)
+	}
+	out := *st
+	out.Steps = slices.Clone(st.Steps)
+	return out
+}
+
+func (s *MemorySagaStore) StepDone(_ context.Context, runID, step, result string) (SagaState, error) {
+	return s.update(runID, func(st *SagaState) { st.stepDone(step, result) }), nil
+}
+
+func (s *MemorySagaStore) Abort(_ context.Context, runID, step, reason string) (SagaState, error) {
+	return s.update(runID, func(st *SagaState) { st.abort(step, reason) }), nil
+}
+
+func (s *MemorySagaStore) Compensated(_ context.Context, runID, step string) (SagaState, error) {
+	return s.update(runID, func(st *SagaState) { st.compensated(step) }), nil
+}
+
+func (s *MemorySagaStore) Saga(_ context.Context, runID string) (SagaState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.runs[runID]
+	if st == nil {
+		return SagaState{RunID: runID}, nil
+	}
+	out := *st
+	out.Steps = slices.Clone(st.Steps)
+	return out, nil
+}
+
+func (s *MemorySagaStore) Delete(_ context.Context, runID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.runs, runID)
+	return nil
+}
+
+// FileSagaStore keeps one JSON file per run in a directory (same rules as FileJoinStore)
+type FileSagaStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileSagaStore uses dir (created if missing)
+func NewFileSagaStore(dir string) (*FileSagaStore, error) {
+	if err := ensureDir(dir); err != nil {
+		return nil, fmt.Errorf("saga store: %w", err)
+	}
+	return &FileSagaStore{dir: dir}, nil
+}
+
+func (s *FileSagaStore) update(runID string, fn func(*SagaState)) (SagaState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := stateFile(s.dir, "saga:"+runID)
+	st := SagaState{RunID: runID}
+	if err := readJSONFile(p, &st); err != nil {
+		return SagaState{}, fmt.Errorf("saga store: %w", err)
+	}
+	if fn == nil {
+		return st, nil
+	}
+
+	fn(&st)
+	if err := writeJSONFile(p, st); err != nil {
+		return SagaState{}, fmt.Errorf("saga store: %w", err)
+	}
+	return st, nil
+}
+
+func (s *FileSagaStore) StepDone(_ context.Context, runID, step, result string) (SagaState, error) {
+	return s.update(runID, func(st *SagaState) { st.stepDone(step, result) })
+}
+
+func (s *FileSagaStore) Abort(_ context.Context, runID, step, reason string) (SagaState, error) {
+	return s.update(runID, func(st *SagaState) { st.abort(step, reason) })
+}
+
+func (s *FileSagaStore) Compensated(_ context.Context, runID, step string) (SagaState, error) {
+	return s.update(runID, func(st *SagaState) { st.compensated(step) })
+}
+
+func (s *FileSagaStore) Saga(_ context.Context, runID string) (SagaState, error) {
+	return s.update(runID, nil)
+}
+
+func (s *FileSagaStore) Delete(_ context.Context, runID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := removeFile(stateFile(s.dir, "saga:"+runID)); err != nil {
+		return fmt.Errorf("saga store: %w", err)
+	}
+	return nil
+}
+
+// ---- Engine side ----
+
+// compensationMessage is the value on the compensation topic; the run id is in the envelope
+type compensationMessage struct {
+	Step string `json:"step"`
+}
+
+// abortOnGiveUp is the step workers' BeforeGiveUp: it aborts the run when the Worker is about to
+// give up on a step message for good (dead-letter it, ack it after a permanent failure, or
+// quarantine it). Going by the Worker's decision rather than the handler error means OnExhausted,
+// Skip, expiry and poison handling count exactly as the Worker applies them. It runs before the
+// message is settled, so a failed abort or compensation produce nacks it and is retried on redelivery
+func (e *Engine) abortOnGiveUp(step string) func(ctx context.Context, ev driftq.SettledEvent) error {
+	if e.saga == nil {
+		return nil
+	}
+	return func(ctx context.Context, ev driftq.SettledEvent) error {
+		cause := ev.Err
+		if cause == nil {
+			cause = errors.New(ev.Reason)
+		}
+		if err := e.abort(ctx, ev.Step.RunID, step, cause); err != nil {
+			return fmt.Errorf("workflow: abort %s: %w", ev.Step.RunID, err)
+		}
+		return nil
+	}
+}
+
+// abort marks the run failed at step and starts compensating
+func (e *Engine) abort(ctx context.Context, runID, step string, cause error) error {
+	st, err := e.saga.Abort(ctx, runID, step, cause.Error())
+	if err != nil {
+		return err
+	}
+	return e.compensateNext(ctx, st)
+}
+
+// compensateNext sends the compensation for the newest completed, not yet undone step.
+// Each step's compensation message is keyed by run and step, so sending it twice is harmless
+func (e *Engine) compensateNext(ctx context.Context, st SagaState) error {
+	next, ok := st.Pending(e.wf)
+	if !ok {
+		return nil
+	}
+
+	b, err := json.Marshal(compensationMessage{Step: next.Name})
+	if err != nil {
+		return err
+	}
+	_, err = e.c.Produce(ctx, driftq.ProduceRequest{
+		Topic: e.cfg.CompensationTopic,
+		Key:   st.RunID,
+		Value: string(b),
+		Envelope: &driftq.Envelope{
+			RunID:          st.RunID,
+			StepID:         "compensate:" + next.Name,
+			IdempotencyKey: "wf:" + e.wf.name + ":" + st.RunID + ":compensate>" + next.Name,
+			RetryPolicy:    e.wf.steps[next.Name].Retry,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("workflow: compensate %s in %s: %w", next.Name, st.RunID, err)
+	}
+	return nil
+}
+
+// compensationHandler undoes one step, records it, then sends the next compensation
+func (e *Engine) compensationHandler() driftq.StepHandler {
+	return driftq.StepContextFunc(func(ctx context.Context, sc *driftq.StepContext, msg driftq.ConsumeMessage) error {
+		var cm compensationMessage
+		if err := json.Unmarshal([]byte(msg.Value), &cm); err != nil {
+			return driftq.Permanent(fmt.Errorf("workflow: bad compensation message: %w", err))
+		}
+		def := e.wf.steps[cm.Step]
+		if def == nil || def.Compensate == nil {
+			return driftq.Permanent(fmt.Errorf("workflow: step %q has no compensation", cm.Step))
+		}
+
+		st, err := e.saga.Saga(ctx, sc.RunID)
+		if err != nil {
+			return err
+		}
+		done := st.step(cm.Step)
+		if done == nil {
+			return driftq.Permanent(fmt.Errorf("workflow: run %s has no completed step %q", sc.RunID, cm.Step))
+		}
+
+		if !done.Compensated {
+			err := def.Compensate(ctx, Compensation{
+				RunID:      sc.RunID,
+				Step:       cm.Step,
+				Result:     done.Result,
+				FailedStep: st.FailedStep,
+				Reason:     st.Reason,
+				Message:    msg,
+			})
+			if err != nil {
+				return err
+			}
+			if st, err = e.saga.Compensated(ctx, sc.RunID, cm.Step); err != nil {
+				return err
+			}
+		}
+		return e.compensateNext(ctx, st)
+	})
+}
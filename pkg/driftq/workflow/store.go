@@ -0,0 +1,285 @@
+package workflow
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JoinKey identifies one join: a Join step within one run
+type JoinKey struct {
+	Workflow string `json:"workflow"`
+	Step     string `json:"step"`
+	RunID    string `json:"run_id"`
+}
+
+func (k JoinKey) String() string { return k.Workflow + "/" + k.Step + "/" + k.RunID }
+
+// JoinStore keeps fan-in state between deliveries. Implementations must be safe for concurrent use
+type JoinStore interface {
+	// Arrive records from's result for key (a repeat overwrites) and returns every result recorded so far.
+	// For a key that was already deleted it records nothing and returns nil, so a redelivery
+	// arriving after the join ran doesn't start a new join that never completes
+	Arrive(ctx context.Context, key JoinKey, from, value string) (map[string]string, error)
+
+	// Delete drops the state once the join step has run and its results went out,
+	// keeping a marker that key is done
+	Delete(ctx context.Context, key JoinKey) error
+}
+
+// DefaultJoinDoneTTL is how long the join stores keep a completed join's done marker
+const DefaultJoinDoneTTL = 24 * time.Hour
+
+func doneTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return DefaultJoinDoneTTL
+	}
+	return ttl
+}
+
+// MemoryJoinStore keeps joins in memory: fine for tests and single-process setups
+// where a restart may re-run a workflow from its entry steps
+type MemoryJoinStore struct {
+	// DoneTTL is how long a completed join's done marker is kept (default DefaultJoinDoneTTL).
+	// Markers older than that are dropped as joins complete; an arrival for the join after
+	// that starts a new one
+	DoneTTL time.Duration
+
+	mu     sync.Mutex
+	joins  map[JoinKey]map[string]string
+	done   map[JoinKey]time.Time
+	pruned time.Time
+}
+
+func NewMemoryJoinStore() *MemoryJoinStore {
+	return &MemoryJoinStore{joins: map[JoinKey]map[string]string{}, done: map[JoinKey]time.Time{}}
+}
+
+func (s *MemoryJoinStore) Arrive(_ context.Context, key JoinKey, from, value string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.done[key]; ok {
+		return nil, nil
+	}
+	in := s.joins[key]
+	if in == nil {
+		in = map[string]string{}
+		s.joins[key] = in
+	}
+	in[from] = value
+	return maps.Clone(in), nil
+}
+
+func (s *MemoryJoinStore) Delete(_ context.Context, key JoinKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	delete(s.joins, key)
+	s.done[key] = now
+
+	if ttl := doneTTL(s.DoneTTL); now.Sub(s.pruned) >= ttl/2 {
+		s.pruned = now
+		s.pruneDone(now.Add(-ttl))
+	}
+	return nil
+}
+
+// PruneDone drops the done markers of joins completed before before and returns how many it dropped
+func (s *MemoryJoinStore) PruneDone(before time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pruneDone(before)
+}
+
+func (s *MemoryJoinStore) pruneDone(before time.Time) int {
+	n := 0
+	for k, at := range s.done {
+		if at.Before(before) {
+			delete(s.done, k)
+			n++
+		}
+	}
+	return n
+}
+
+// Len is the number of joins still waiting for inputs
+func (s *MemoryJoinStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.joins)
+}
+
+// FileJoinStore keeps one JSON file per join in a directory, so partial joins survive a restart.
+// Writes go to a temp file and are renamed into place. A completed join leaves a small done
+// file behind until DoneTTL has passed. It serializes access within a process;
+// don't point two processes at the same directory
+type FileJoinStore struct {
+	// DoneTTL is how long a completed join's done file is kept (default DefaultJoinDoneTTL).
+	// Old ones are removed as joins complete, at most every DoneTTL/2
+	DoneTTL time.Duration
+
+	dir    string
+	mu     sync.Mutex
+	pruned time.Time
+}
+
+type joinFile struct {
+	Key    JoinKey           `json:"key"`
+	Inputs map[string]string `json:"inputs,omitempty"`
+	Done   bool              `json:"done,omitempty"`
+	DoneAt *time.Time        `json:"done_at,omitempty"`
+}
+
+// NewFileJoinStore uses dir (created if missing)
+func NewFileJoinStore(dir string) (*FileJoinStore, error) {
+	if err := ensureDir(dir); err != nil {
+		return nil, fmt.Errorf("join store: %w", err)
+	}
+	return &FileJoinStore{dir: dir}, nil
+}
+
+func (s *FileJoinStore) Arrive(_ context.Context, key JoinKey, from, value string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := stateFile(s.dir, "join:"+key.String())
+	jf := joinFile{Key: key}
+	if err := readJSONFile(p, &jf); err != nil {
+		return nil, fmt.Errorf("join store: %w", err)
+	}
+	if jf.Done {
+		return nil, nil
+	}
+	if jf.Inputs == nil {
+		jf.Inputs = map[string]string{}
+	}
+
+	jf.Inputs[from] = value
+	if err := writeJSONFile(p, jf); err != nil {
+		return nil, fmt.Errorf("join store: %w", err)
+	}
+	return maps.Clone(jf.Inputs), nil
+}
+
+func (s *FileJoinStore) Delete(_ context.Context, key JoinKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	if err := writeJSONFile(stateFile(s.dir, "join:"+key.String()), joinFile{Key: key, Done: true, DoneAt: &now}); err != nil {
+		return fmt.Errorf("join store: %w", err)
+	}
+
+	if ttl := doneTTL(s.DoneTTL); now.Sub(s.pruned) >= ttl/2 {
+		// The join itself is done; a failed sweep is retried on a later Delete
+		if _, err := s.pruneDone(now.Add(-ttl)); err == nil {
+			s.pruned = now
+		}
+	}
+	return nil
+}
+
+// PruneDone removes the done files of joins completed before before and returns how many it removed
+func (s *FileJoinStore) PruneDone(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pruneDone(before)
+}
+
+func (s *FileJoinStore) pruneDone(before time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("join store: %w", err)
+	}
+
+	n := 0
+	for _, de := range entries {
+		if de.IsDir() || !strings.HasSuffix(de.Name(), ".json") {
+			continue
+		}
+		p := filepath.Join(s.dir, de.Name())
+		var jf joinFile
+		if err := readJSONFile(p, &jf); err != nil || !jf.Done || (jf.DoneAt != nil && !jf.DoneAt.Before(before)) {
+			continue // not ours, still open, or recent
+		}
+		if err := removeFile(p); err != nil {
+			return n, fmt.Errorf("join store: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// ---- file helpers shared by the file-backed stores ----
+
+func ensureDir(dir string) error { return os.MkdirAll(dir, 0o755) }
+
+// stateFile maps an arbitrary id onto a safe file name
+func stateFile(dir, id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// readJSONFile decodes path into v; a missing file leaves v alone
+func readJSONFile(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSONFile writes to a temp file and renames it into place, so readers never see half a file
+func writeJSONFile(path string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".state-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+// Package workflow runs declarative multi-step pipelines (DAGs) on DriftQ topics.
+//
+// Each Step gets its own topic and Worker. A step's Result is produced to its downstream
+// steps (Next, plus Routes picked by Result.Route), so fan-out is just several edges.
+// A step with Join waits until every listed upstream step has delivered for the run
+// (keyed by RunID, state in a JoinStore) and then runs once with all their results.
+//
+//	wf, err := workflow.New("agent",
+//		workflow.Step{Name: "plan", Handler: plan, Next: []string{"search", "recall"}},
+//		workflow.Step{Name: "search", Handler: search, Next: []string{"answer"}},
+//		workflow.Step{Name: "recall", Handler: recall, Next: []string{"answer"}},
+//		workflow.Step{Name: "answer", Handler: answer, Join: []string{"search", "recall"}},
+//	)
+//	eng, err := workflow.NewEngine(wf, workflow.Config{Client: c})
+//	go eng.Run(ctx)
+//	runID, err := eng.Start(ctx, workflow.StartRequest{Value: question})
+//
+// Steps with Compensate make the run a saga: if a step fails for good, the completed
+// steps are undone newest first (see SagaStore).
+//
+// Delivery is at-least-once, like the Worker underneath: a handler may run again for the
+// same run and step. Its downstream messages are keyed by run, step and edge, so a re-run
+// doesn't start the downstream steps twice.
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq"
+)
+
+// Input is what a step handler gets
+type Input struct {
+	RunID string
+	Step  string // this step
+	From  string // upstream step that triggered it; "" for entry steps
+	Value string // From's result (or the Start value)
+
+	// Inputs holds every joined upstream result by step name (Join steps only)
+	Inputs map[string]string
+
+	Message driftq.ConsumeMessage
+}
+
+// Result is a step's output. Value goes to every downstream step; Route, when set,
+// must be a key of Step.Routes and adds those steps to Next
+type Result struct {
+	Value string
+	Route string
+}
+
+type Handler func(ctx context.Context, in Input) (Result, error)
+
+// Step declares one node of the graph
+type Step struct {
+	Name    string
+	Handler Handler
+
+	Next   []string            // always run after this step
+	Routes map[string][]string // run when Result.Route matches
+
+	// Join lists upstream steps that must all deliver before this step runs (once per run).
+	// Each must have this step in its Next: a route that isn't taken would leave the join
+	// waiting forever. For the same reason, joined steps that sit behind an upstream route
+	// should all sit behind the same one
+	Join []string
+
+	// Retry is put on the envelope of every message to this step (see driftq.RetryPolicy)
+	Retry *driftq.RetryPolicy
+
+	// Compensate undoes this step when a later step of the run fails for good (see SagaStore)
+	Compensate CompensateFunc
+
+	Concurrency int    // Worker concurrency (default 1)
+	Topic       string // default Config.TopicPrefix + Name
+}
+
+// Workflow is a validated graph of steps
+type Workflow struct {
+	name    string
+	steps   map[string]*Step
+	order   []string            // declaration order
+	parents map[string][]string // step -> upstream steps
+	entries []string
+}
+
+var (
+	ErrInvalidWorkflow = errors.New("invalid workflow")
+	ErrUnknownRoute    = errors.New("unknown route")
+)
+
+// New validates steps and builds the graph: names must be unique, edges must point at
+// declared steps, Join entries must reach the step through Next, and there must be no cycles
+func New(name string, steps ...Step) (*Workflow, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidWorkflow)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("%w: %s has no steps", ErrInvalidWorkflow, name)
+	}
+
+	w := &Workflow{name: name, steps: map[string]*Step{}, parents: map[string][]string{}}
+	for i := range steps {
+		s := steps[i]
+		switch {
+		case strings.TrimSpace(s.Name) == "":
+			return nil, fmt.Errorf("%w: step %d has no name", ErrInvalidWorkflow, i)
+		case s.Handler == nil:
+			return nil, fmt.Errorf("%w: step %q has no handler", ErrInvalidWorkflow, s.Name)
+		case w.steps[s.Name] != nil:
+			return nil, fmt.Errorf("%w: duplicate step %q", ErrInvalidWorkflow, s.Name)
+		}
+		w.steps[s.Name] = &s
+		w.order = append(w.order, s.Name)
+	}
+
+	for _, name := range w.order {
+		for _, to := range w.steps[name].edges() {
+			if w.steps[to] == nil {
+				return nil, fmt.Errorf("%w: step %q points at unknown step %q", ErrInvalidWorkflow, name, to)
+			}
+			if !slices.Contains(w.parents[to], name) {
+				w.parents[to] = append(w.parents[to], name)
+			}
+		}
+	}
+
+	for _, name := range w.order {
+		s := w.steps[name]
+		for _, j := range s.Join {
+			switch {
+			case !slices.Contains(w.parents[name], j):
+				return nil, fmt.Errorf("%w: step %q joins %q, which has no edge to it", ErrInvalidWorkflow, name, j)
+			case !slices.Contains(w.steps[j].Next, name):
+				return nil, fmt.Errorf("%w: step %q joins %q, which only reaches it through a route", ErrInvalidWorkflow, name, j)
+			}
+		}
+		if len(w.parents[name]) == 0 {
+			w.entries = append(w.entries, name)
+		}
+	}
+
+	if err := w.checkAcyclic(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Workflow) Name() string { return w.name }
+
+// Steps returns step names in declaration order
+func (w *Workflow) Steps() []string { return slices.Clone(w.order) }
+
+// Entries are the steps without upstream edges; Start sends to all of them
+func (w *Workflow) Entries() []string { return slices.Clone(w.entries) }
+
+// Upstream returns the steps with an edge to step
+func (w *Workflow) Upstream(step string) []string { return slices.Clone(w.parents[step]) }
+
+// compensates reports whether any step registered a compensation
+func (w *Workflow) compensates() bool {
+	for _, s := range w.steps {
+		if s.Compensate != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// edges is every possible downstream step, Next first, then Routes sorted by route name
+func (s *Step) edges() []string {
+	out := slices.Clone(s.Next)
+	routes := make([]string, 0, len(s.Routes))
+	for r := range s.Routes {
+		routes = append(routes, r)
+	}
+	slices.Sort(routes)
+	for _, r := range routes {
+		for _, to := range s.Routes[r] {
+			if !slices.Contains(out, to) {
+				out = append(out, to)
+			}
+		}
+	}
+	return out
+}
+
+// targets is where a result goes: Next plus the chosen route, without duplicates
+func (s *Step) targets(res Result) ([]string, error) {
+	out := slices.Clone(s.Next)
+	if res.Route == "" {
+		return out, nil
+	}
+	routed, ok := s.Routes[res.Route]
+	if !ok {
+		return nil, fmt.Errorf("%w %q from step %q", ErrUnknownRoute, res.Route, s.Name)
+	}
+	for _, to := range routed {
+		if !slices.Contains(out, to) {
+			out = append(out, to)
+		}
+	}
+	return out, nil
+}
+
+func (w *Workflow) checkAcyclic() error {
+	const (
+		unseen = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("%w: cycle %s", ErrInvalidWorkflow, strings.Join(append(path, name), " -> "))
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		for _, to := range w.steps[name].edges() {
+			if err := visit(to, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		return nil
+	}
+
+	for _, name := range w.order {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,644 @@
+package workflow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq"
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq/driftqtest"
+	"github.com/driftq-org/DriftQ-Clients-Go/pkg/driftq/workflow"
+)
+
+type finished struct {
+	step string
+	in   workflow.Input
+}
+
+func TestEngine_FanOutJoinAndRouting(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	srv := driftqtest.NewServer(driftqtest.Options{})
+	defer srv.Close()
+
+	c, err := driftq.Dial(ctx, driftq.Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var (
+		done       = make(chan finished, 10)
+		summarized sync.Map // run id -> *atomic.Int32
+		failedOnce atomic.Bool
+		reported   = make(chan error, 10)
+	)
+
+	terminal := func(ctx context.Context, in workflow.Input) (workflow.Result, error) {
+		done <- finished{in.Step, in}
+		return workflow.Result{}, nil
+	}
+
+	wf, err := workflow.New("triage",
+		workflow.Step{Name: "ingest", Next: []string{"enrich", "embed"}, Handler: func(ctx context.Context, in workflow.Input) (workflow.Result, error) {
+			return workflow.Result{Value: strings.TrimSpace(in.Value)}, nil
+		}},
+		workflow.Step{Name: "enrich", Next: []string{"summarize"}, Handler: func(ctx context.Context, in workflow.Input) (workflow.Result, error) {
+			// the first enrich fails once; the redelivery must still complete the join
+			if failedOnce.CompareAndSwap(false, true) {
+				return workflow.Result{}, errors.New("model timeout")
+			}
+			return workflow.Result{Value: "E(" + in.Value + ")"}, nil
+		}},
+		workflow.Step{Name: "embed", Next: []string{"summarize"}, Handler: func(ctx context.Context, in workflow.Input) (workflow.Result, error) {
+			return workflow.Result{Value: "V(" + in.Value + ")"}, nil
+		}},
+		workflow.Step{
+			Name: "summarize",
+			Join: []string{"enrich", "embed"},
+			Routes: map[string][]string{
+				"urgent": {"page"},
+				"normal": {"archive"},
+			},
+			Handler: func(ctx context.Context, in workflow.Input) (workflow.Result, error) {
+				n, _ := summarized.LoadOrStore(in.RunID, new(atomic.Int32))
+				n.(*atomic.Int32).Add(1)
+
+				route := "normal"
+				switch {
+				case strings.Contains(in.Inputs["enrich"], "fire"):
+					route = "urgent"
+				case strings.Contains(in.Inputs["enrich"], "weird"):
+					route = "nowhere"
+				}
+				return workflow.Result{Value: in.Inputs["enrich"] + "+" + in.Inputs["embed"], Route: route}, nil
+			},
+		},
+		workflow.Step{Name: "page", Handler: terminal, Retry: &driftq.RetryPolicy{MaxAttempts: 7}},
+		workflow.Step{Name: "archive", Handler: terminal},
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	store := workflow.NewMemoryJoinStore()
+	eng, err := workflow.NewEngine(wf, workflow.Config{
+		Client: c,
+		Store:  store,
+		OnError: func(err error) {
+			select {
+			case reported <- err:
+			default:
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	if _, err := eng.EnsureTopics(ctx, 2); err != nil {
+		t.Fatalf("EnsureTopics: %v", err)
+	}
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- eng.Run(ctx) }()
+
+	for _, r := range []workflow.StartRequest{
+		{RunID: "r-fire", Value: " fire in dc2 ", TenantID: "acme"},
+		{RunID: "r-calm", Value: "all good"},
+		{RunID: "r-fire", Value: " fire in dc2 ", TenantID: "acme"}, // duplicate start is dropped
+	} {
+		if _, err := eng.Start(ctx, r); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+	}
+
+	got := map[string]finished{}
+	for len(got) < 2 {
+		select {
+		case f := <-done:
+			if _, dup := got[f.in.RunID]; dup {
+				t.Fatalf("run %s finished twice", f.in.RunID)
+			}
+			got[f.in.RunID] = f
+		case <-ctx.Done():
+			t.Fatalf("timed out; finished so far: %v", got)
+		}
+	}
+
+	fire := got["r-fire"]
+	if fire.step != "page" || fire.in.From != "summarize" || fire.in.Value != "E(fire in dc2)+V(fire in dc2)" {
+		t.Fatalf("unexpected r-fire result %+v", fire)
+	}
+	env := fire.in.Message.Envelope
+	if env == nil || env.RunID != "r-fire" || env.TenantID != "acme" || env.StepID != "page" || env.RetryPolicy == nil || env.RetryPolicy.MaxAttempts != 7 {
+		t.Fatalf("unexpected envelope on page message %#v", env)
+	}
+	if calm := got["r-calm"]; calm.step != "archive" || calm.in.Value != "E(all good)+V(all good)" {
+		t.Fatalf("unexpected r-calm result %+v", calm)
+	}
+
+	if n := len(srv.Messages(eng.Topic("ingest"))); n != 2 {
+		t.Fatalf("expected the duplicate start to be deduplicated, got %d ingest messages", n)
+	}
+	for _, run := range []string{"r-fire", "r-calm"} {
+		n, _ := summarized.Load(run)
+		if n.(*atomic.Int32).Load() != 1 {
+			t.Fatalf("summarize ran %d times for %s", n.(*atomic.Int32).Load(), run)
+		}
+	}
+	// Join state is dropped right after summarize's sends, which can trail the terminal step
+	for store.Len() != 0 {
+		select {
+		case <-time.After(5 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatalf("expected join state to be cleaned up, %d left", store.Len())
+		}
+	}
+
+	// An unknown route is a permanent failure: reported, not retried forever
+	if _, err := eng.Start(ctx, workflow.StartRequest{RunID: "r-weird", Value: "weird"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	for {
+		select {
+		case err := <-reported:
+			if errors.Is(err, workflow.ErrUnknownRoute) {
+				cancel()
+				if err := <-runErr; err != nil {
+					t.Fatalf("Run: %v", err)
+				}
+				return
+			}
+		case <-ctx.Done():
+			t.Fatalf("expected ErrUnknownRoute to be reported")
+		}
+	}
+}
+
+func TestNew_Validation(t *testing.T) {
+	h := func(ctx context.Context, in workflow.Input) (workflow.Result, error) { return workflow.Result{}, nil }
+
+	for name, steps := range map[string][]workflow.Step{
+		"no steps":       nil,
+		"no handler":     {{Name: "a"}},
+		"duplicate":      {{Name: "a", Handler: h}, {Name: "a", Handler: h}},
+		"unknown edge":   {{Name: "a", Handler: h, Next: []string{"b"}}},
+		"unknown route":  {{Name: "a", Handler: h, Routes: map[string][]string{"x": {"b"}}}},
+		"join not above": {{Name: "a", Handler: h, Next: []string{"c"}}, {Name: "b", Handler: h}, {Name: "c", Handler: h, Join: []string{"a", "b"}}},
+		"join via route": {{Name: "a", Handler: h, Next: []string{"c"}}, {Name: "b", Handler: h, Routes: map[string][]string{"x": {"c"}}}, {Name: "c", Handler: h, Join: []string{"a", "b"}}},
+		"cycle":          {{Name: "a", Handler: h, Next: []string{"b"}}, {Name: "b", Handler: h, Routes: map[string][]string{"again": {"a"}}}},
+	} {
+		if _, err := workflow.New("wf", steps...); !errors.Is(err, workflow.ErrInvalidWorkflow) {
+			t.Fatalf("%s: expected ErrInvalidWorkflow, got %v", name, err)
+		}
+	}
+
+	wf, err := workflow.New("wf",
+		workflow.Step{Name: "a", Handler: h, Next: []string{"c"}},
+		workflow.Step{Name: "b", Handler: h, Next: []string{"c"}},
+		workflow.Step{Name: "c", Handler: h, Join: []string{"a", "b"}},
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if e := wf.Entries(); len(e) != 2 || e[0] != "a" || e[1] != "b" {
+		t.Fatalf("unexpected entries %v", e)
+	}
+}
+
+func TestFileJoinStore_SurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	key := workflow.JoinKey{Workflow: "wf", Step: "join", RunID: "r1"}
+
+	s1, err := workflow.NewFileJoinStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileJoinStore: %v", err)
+	}
+	if got, err := s1.Arrive(ctx, key, "a", "1"); err != nil || len(got) != 1 {
+		t.Fatalf("Arrive: %v %v", got, err)
+	}
+
+	// A new store on the same dir (a restart) sees the partial join
+	s2, _ := workflow.NewFileJoinStore(dir)
+	got, err := s2.Arrive(ctx, key, "b", "2")
+	if err != nil || got["a"] != "1" || got["b"] != "2" {
+		t.Fatalf("expected both inputs after reopen, got %v (err=%v)", got, err)
+	}
+
+	other, _ := s2.Arrive(ctx, workflow.JoinKey{Workflow: "wf", Step: "join", RunID: "r2"}, "a", "x")
+	if len(other) != 1 {
+		t.Fatalf("runs must not share join state: %v", other)
+	}
+
+	if err := s2.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	// A redelivery after the join ran is ignored, also across a restart
+	if got, err := s2.Arrive(ctx, key, "b", "2"); err != nil || got != nil {
+		t.Fatalf("expected a completed join to ignore arrivals, got %v (err=%v)", got, err)
+	}
+	s3, _ := workflow.NewFileJoinStore(dir)
+	if got, err := s3.Arrive(ctx, key, "a", "1"); err != nil || got != nil {
+		t.Fatalf("expected the done marker to survive reopen, got %v (err=%v)", got, err)
+	}
+}
+
+func TestMemoryJoinStore_IgnoresCompletedJoins(t *testing.T) {
+	ctx := context.Background()
+	key := workflow.JoinKey{Workflow: "wf", Step: "join", RunID: "r1"}
+
+	s := workflow.NewMemoryJoinStore()
+	if got, err := s.Arrive(ctx, key, "a", "1"); err != nil || len(got) != 1 {
+		t.Fatalf("Arrive: %v %v", got, err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if got, err := s.Arrive(ctx, key, "a", "1"); err != nil || got != nil || s.Len() != 0 {
+		t.Fatalf("expected a late arrival to be ignored, got %v len=%d (err=%v)", got, s.Len(), err)
+	}
+}
+
+func TestJoinStores_PruneDoneMarkers(t *testing.T) {
+	ctx := context.Background()
+	old := workflow.JoinKey{Workflow: "wf", Step: "join", RunID: "r1"}
+	fresh := workflow.JoinKey{Workflow: "wf", Step: "join", RunID: "r2"}
+
+	mem := workflow.NewMemoryJoinStore()
+	mem.Delete(ctx, old)
+	cutoff := time.Now().Add(time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	mem.Delete(ctx, fresh)
+	if n := mem.PruneDone(cutoff); n != 1 {
+		t.Fatalf("expected one marker pruned, got %d", n)
+	}
+	// Once its marker is gone a key is a new join; the recent one is still done
+	if got, _ := mem.Arrive(ctx, old, "a", "1"); len(got) != 1 {
+		t.Fatalf("expected the pruned key to start over, got %v", got)
+	}
+	if got, _ := mem.Arrive(ctx, fresh, "a", "1"); got != nil {
+		t.Fatalf("expected the recent marker to be kept, got %v", got)
+	}
+
+	dir := t.TempDir()
+	fs, err := workflow.NewFileJoinStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileJoinStore: %v", err)
+	}
+	fs.Arrive(ctx, fresh, "a", "1") // still open: never pruned
+	if err := fs.Delete(ctx, old); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	n, err := fs.PruneDone(time.Now().Add(time.Millisecond))
+	if err != nil || n != 1 {
+		t.Fatalf("expected one done file pruned, got %d (err=%v)", n, err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("expected only the open join left on disk, got %d files", len(files))
+	}
+	if got, _ := fs.Arrive(ctx, fresh, "b", "2"); len(got) != 2 {
+		t.Fatalf("expected the open join to keep its inputs, got %v", got)
+	}
+
+	// Delete sweeps by itself once markers are older than DoneTTL
+	fs.DoneTTL = time.Millisecond
+	fs.Delete(ctx, old)
+	time.Sleep(2 * time.Millisecond)
+	fs.Delete(ctx, fresh)
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("expected Delete to prune expired markers, got %d files", len(files))
+	}
+}
+
+func TestEngine_SagaCompensatesInReverse(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	srv := driftqtest.NewServer(driftqtest.Options{})
+	defer srv.Close()
+
+	c, err := driftq.Dial(ctx, driftq.Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	var (
+		mu     sync.Mutex
+		undone []workflow.Compensation
+		failed atomic.Bool
+	)
+	undo := func(ctx context.Context, comp workflow.Compensation) error {
+		// the first charge refund fails once; the redelivery must not undo it twice
+		if comp.Step == "charge" && failed.CompareAndSwap(false, true) {
+			return errors.New("payments unavailable")
+		}
+		mu.Lock()
+		undone = append(undone, comp)
+		mu.Unlock()
+		return nil
+	}
+
+	wf, err := workflow.New("order",
+		workflow.Step{Name: "reserve", Next: []string{"charge"}, Compensate: undo, Handler: func(ctx context.Context, in workflow.Input) (workflow.Result, error) {
+			return workflow.Result{Value: "hold-" + in.Value}, nil
+		}},
+		workflow.Step{Name: "charge", Next: []string{"ship"}, Compensate: undo, Handler: func(ctx context.Context, in workflow.Input) (workflow.Result, error) {
+			return workflow.Result{Value: "txn-" + in.Value}, nil
+		}},
+		workflow.Step{Name: "ship", Handler: func(ctx context.Context, in workflow.Input) (workflow.Result, error) {
+			return workflow.Result{}, driftq.Permanent(errors.New("address rejected"))
+		}},
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	sagas := workflow.NewMemorySagaStore()
+	eng, err := workflow.NewEngine(wf, workflow.Config{Client: c, SagaStore: sagas})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	if _, err := eng.EnsureTopics(ctx, 1); err != nil {
+		t.Fatalf("EnsureTopics: %v", err)
+	}
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- eng.Run(ctx) }()
+
+	if _, err := eng.Start(ctx, workflow.StartRequest{RunID: "o-1", Value: "42"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	for {
+		st, err := eng.Saga(ctx, "o-1")
+		if err != nil {
+			t.Fatalf("Saga: %v", err)
+		}
+		if _, pending := st.Pending(wf); st.Aborted && !pending {
+			if st.FailedStep != "ship" || !strings.Contains(st.Reason, "address rejected") || len(st.Steps) != 2 {
+				t.Fatalf("unexpected saga state %+v", st)
+			}
+			break
+		}
+		select {
+		case <-time.After(5 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for compensation; state %+v", st)
+		}
+	}
+
+	mu.Lock()
+	got := slices.Clone(undone)
+	mu.Unlock()
+	if len(got) != 2 || got[0].Step != "charge" || got[1].Step != "reserve" {
+		t.Fatalf("expected charge then reserve to be undone once each, got %+v", got)
+	}
+	if got[0].Result != "txn-hold-42" || got[0].FailedStep != "ship" || got[0].RunID != "o-1" {
+		t.Fatalf("unexpected compensation %+v", got[0])
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+func TestEngine_SagaAbortFollowsSettlement(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cfg     workflow.Config
+		aborted bool
+		status  driftq.StepStatus
+	}{
+		"dead-lettered after retries": {
+			cfg:     workflow.Config{DeadLetter: &driftq.DeadLetterConfig{Topic: "order.dlq", MaxAttempts: 2}},
+			aborted: true,
+			status:  driftq.StepDeadLettered,
+		},
+		"OnExhausted acks": {
+			cfg: workflow.Config{Backoff: &driftq.BackoffConfig{Base: time.Millisecond, MaxAttempts: 2,
+				OnExhausted: func(ctx context.Context, msg driftq.ConsumeMessage, err error) error { return nil }}},
+			status: driftq.StepSucceeded,
+		},
+		"OnExhausted skips": {
+			cfg: workflow.Config{Backoff: &driftq.BackoffConfig{Base: time.Millisecond, MaxAttempts: 2,
+				OnExhausted: func(ctx context.Context, msg driftq.ConsumeMessage, err error) error { return driftq.Skip(err) }}},
+			status: driftq.StepSkipped,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			srv := driftqtest.NewServer(driftqtest.Options{AutoCreateTopics: true})
+			defer srv.Close()
+
+			c, err := driftq.Dial(ctx, driftq.Config{BaseURL: srv.URL})
+			if err != nil {
+				t.Fatalf("Dial: %v", err)
+			}
+
+			noop := func(ctx context.Context, comp workflow.Compensation) error { return nil }
+			wf, err := workflow.New("order",
+				workflow.Step{Name: "reserve", Next: []string{"ship"}, Compensate: noop, Handler: func(ctx context.Context, in workflow.Input) (workflow.Result, error) {
+					return workflow.Result{Value: in.Value}, nil
+				}},
+				workflow.Step{Name: "ship", Handler: func(ctx context.Context, in workflow.Input) (workflow.Result, error) {
+					return workflow.Result{}, errors.New("carrier down")
+				}},
+			)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			settled := make(chan driftq.SettledEvent, 1)
+			cfg := tc.cfg
+			cfg.Client = c
+			cfg.Hooks.OnSettled = func(ctx context.Context, ev driftq.SettledEvent) {
+				if ev.Step.StepID == "ship" && ev.Status != driftq.StepNacked {
+					settled <- ev
+				}
+			}
+			eng, err := workflow.NewEngine(wf, cfg)
+			if err != nil {
+				t.Fatalf("NewEngine: %v", err)
+			}
+
+			runErr := make(chan error, 1)
+			go func() { runErr <- eng.Run(ctx) }()
+			if _, err := eng.Start(ctx, workflow.StartRequest{RunID: "o-1", Value: "42"}); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			// The user hook runs after the engine's, so the saga is up to date here
+			select {
+			case ev := <-settled:
+				if ev.Status != tc.status || ev.Message.Attempts != 2 {
+					t.Fatalf("unexpected settlement %s after %d attempts", ev.Status, ev.Message.Attempts)
+				}
+			case <-ctx.Done():
+				t.Fatalf("timed out waiting for ship to settle")
+			}
+
+			st, err := eng.Saga(ctx, "o-1")
+			if err != nil || st.Aborted != tc.aborted {
+				t.Fatalf("expected aborted=%v, got %+v (err=%v)", tc.aborted, st, err)
+			}
+			if tc.aborted && (st.FailedStep != "ship" || !strings.Contains(st.Reason, "carrier down")) {
+				t.Fatalf("unexpected saga state %+v", st)
+			}
+
+			cancel()
+			if err := <-runErr; err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+		})
+	}
+}
+
+// flakySagaStore fails the first Abort, like a store that is briefly unreachable
+type flakySagaStore struct {
+	*workflow.MemorySagaStore
+	failed atomic.Bool
+}
+
+func (s *flakySagaStore) Abort(ctx context.Context, runID, step, reason string) (workflow.SagaState, error) {
+	if s.failed.CompareAndSwap(false, true) {
+		return workflow.SagaState{}, errors.New("store unavailable")
+	}
+	return s.MemorySagaStore.Abort(ctx, runID, step, reason)
+}
+
+func TestEngine_SagaAbortRetriedWhenStoreFails(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	srv := driftqtest.NewServer(driftqtest.Options{AutoCreateTopics: true})
+	defer srv.Close()
+
+	c, err := driftq.Dial(ctx, driftq.Config{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	undone := make(chan workflow.Compensation, 1)
+	wf, err := workflow.New("order",
+		workflow.Step{Name: "reserve", Next: []string{"ship"}, Handler: func(ctx context.Context, in workflow.Input) (workflow.Result, error) {
+			return workflow.Result{Value: "r-1"}, nil
+		}, Compensate: func(ctx context.Context, comp workflow.Compensation) error {
+			undone <- comp
+			return nil
+		}},
+		workflow.Step{Name: "ship", Handler: func(ctx context.Context, in workflow.Input) (workflow.Result, error) {
+			return workflow.Result{}, driftq.Permanent(errors.New("address rejected"))
+		}},
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	var (
+		mu     sync.Mutex
+		errs   []error
+		shipAt []int
+	)
+	store := &flakySagaStore{MemorySagaStore: workflow.NewMemorySagaStore()}
+	eng, err := workflow.NewEngine(wf, workflow.Config{
+		Client:     c,
+		SagaStore:  store,
+		DeadLetter: &driftq.DeadLetterConfig{Topic: "order.dlq"},
+		OnError: func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		},
+		Hooks: driftq.WorkerHooks{
+			OnSettled: func(ctx context.Context, ev driftq.SettledEvent) {
+				if ev.Step.StepID == "ship" {
+					mu.Lock()
+					shipAt = append(shipAt, ev.Message.Attempts)
+					mu.Unlock()
+				}
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- eng.Run(ctx) }()
+	if _, err := eng.Start(ctx, workflow.StartRequest{RunID: "o-1", Value: "42"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	select {
+	case comp := <-undone:
+		if comp.Step != "reserve" || comp.FailedStep != "ship" || !strings.Contains(comp.Reason, "address rejected") {
+			t.Fatalf("unexpected compensation %+v", comp)
+		}
+	case <-ctx.Done():
+		t.Fatalf("timed out waiting for the compensation")
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// The failed abort kept the message: nacked once, dead-lettered on the redelivery
+	if fmt.Sprint(shipAt) != "[1 2]" {
+		t.Fatalf("expected ship to settle twice, got attempts %v", shipAt)
+	}
+	if len(errs) == 0 || !strings.Contains(errs[0].Error(), "store unavailable") {
+		t.Fatalf("expected the failed abort to be reported, got %v", errs)
+	}
+}
+
+func TestFileSagaStore_SurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s1, err := workflow.NewFileSagaStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileSagaStore: %v", err)
+	}
+	s1.StepDone(ctx, "r1", "a", "1")
+	s1.StepDone(ctx, "r1", "b", "2")
+	s1.Abort(ctx, "r1", "c", "boom")
+	s1.Compensated(ctx, "r1", "b")
+
+	// A restart sees the same progress, and repeated updates change nothing
+	s2, _ := workflow.NewFileSagaStore(dir)
+	st, err := s2.StepDone(ctx, "r1", "a", "other")
+	if err != nil {
+		t.Fatalf("StepDone: %v", err)
+	}
+	if st, err = s2.Abort(ctx, "r1", "d", "later"); err != nil {
+		t.Fatalf("Abort: %v", err)
+	}
+	if len(st.Steps) != 2 || st.Steps[0].Result != "1" || !st.Steps[1].Compensated || st.Steps[0].Compensated {
+		t.Fatalf("unexpected steps after reopen %+v", st.Steps)
+	}
+	if !st.Aborted || st.FailedStep != "c" || st.Reason != "boom" {
+		t.Fatalf("the first abort must win, got %+v", st)
+	}
+
+	if err := s2.Delete(ctx, "r1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if st, _ := s2.Saga(ctx, "r1"); st.Aborted || len(st.Steps) != 0 {
+		t.Fatalf("expected a fresh saga after Delete, got %+v", st)
+	}
+}